@@ -13,7 +13,8 @@ import (
 )
 
 func main() {
-	fmt.Println("=== Многопоточная система Task Manager с сохранением в файлы ===\n")
+	fmt.Println("=== Многопоточная система Task Manager с сохранением в файлы ===")
+	fmt.Println()
 
 	// Создаем контекст с отменой
 	ctx, cancel := context.WithCancel(context.Background())
@@ -29,18 +30,55 @@ func main() {
 	}
 
 	// Инициализация репозитория с указанием файлов
-	storage := repository.NewStorage("data/tasks", "data/notes")
-	defer storage.Cleanup() // Сохраняем данные при завершении
+	// Журналируемый режим дописывает по одной записи на изменение вместо полной перезаписи файлов
+	// Каталог data блокируется, пока хранилище открыто, чтобы второй экземпляр не перезаписал файлы
+	// Если задана TASK_MANAGER_PASSPHRASE, файлы данных хранятся зашифрованными
+	// Раз в минуту делается снимок в data/snapshots, старые снимки удаляются по политике хранения
+	storage, report, err := repository.Open("data/tasks", "data/notes",
+		repository.WithJournal(repository.JournalOptions{}),
+		repository.WithSnapshots(repository.SnapshotOptions{Interval: time.Minute}),
+		repository.WithEncryption(os.Getenv("TASK_MANAGER_PASSPHRASE")))
+	if err != nil {
+		fmt.Printf("Ошибка открытия хранилища: %v\n", err)
+		os.Exit(1)
+	}
+	if report.HasIssues() {
+		fmt.Println("Проблемы при загрузке данных:")
+		fmt.Print(report)
+	}
+	// Данные сохраняются в storage.Close при завершении: в конце main или в обработчике сигнала
+
+	// Изменения сохраняются фоновой горутиной раз в секунду или после 5 изменений
+	if err := storage.StartAutosave(ctx, repository.AutosaveOptions{Interval: time.Second, MaxChanges: 5}); err != nil {
+		fmt.Printf("Ошибка запуска автосохранения: %v\n", err)
+	}
+	if err := storage.StartSnapshots(ctx); err != nil {
+		fmt.Printf("Ошибка запуска снимков: %v\n", err)
+	}
+
+	// Правки файлов data/* из других программ подхватываются без перезапуска
+	if err := storage.StartWatching(ctx, repository.WatchOptions{}); err != nil {
+		fmt.Printf("Ошибка запуска наблюдения за файлами: %v\n", err)
+	}
+	go func() {
+		for ev := range storage.SubscribeReloads(ctx) {
+			if ev.Err != nil {
+				fmt.Printf("Ошибка загрузки внешних изменений: %v\n", ev.Err)
+				continue
+			}
+			fmt.Printf("Внешние изменения %s: добавлено %v, изменено %v, удалено %v\n", ev.File, ev.Added, ev.Updated, ev.Removed)
+		}
+	}()
 
 	modelChan := make(chan interface{}, 10)
 	var wg sync.WaitGroup
 
-	// Запуск логера (работает каждые 200 мс)
+	// Запуск логера (получает изменения хранилища по подписке)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		fmt.Println("Логер: запущен")
-		service.Logger(ctx, storage, 200*time.Millisecond)
+		service.Logger(ctx, storage)
 		fmt.Println("Логер: завершен")
 	}()
 
@@ -98,6 +136,13 @@ func main() {
 			fmt.Println("Время ожидания истекло, принудительное завершение")
 		}
 
+		// os.Exit не выполняет отложенные вызовы, поэтому хранилище закрывается явно.
+		// Close идемпотентен: если main успел закрыть хранилище сам, вернётся тот же результат
+		if err := storage.Close(); err != nil {
+			fmt.Printf("Ошибка закрытия хранилища: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Завершаем программу
 		os.Exit(0)
 	}()
@@ -114,5 +159,20 @@ func main() {
 	fmt.Printf("Всего задач: %d\n", taskCount)
 	fmt.Printf("Всего заметок: %d\n", noteCount)
 	fmt.Printf("Всего моделей: %d\n", taskCount+noteCount)
+	if lastSave := storage.LastSaveTime(); !lastSave.IsZero() {
+		fmt.Printf("Последнее автосохранение: %s\n", lastSave.Format("15:04:05"))
+	}
+	if err := storage.LastSaveError(); err != nil {
+		fmt.Printf("Ошибка последнего сохранения: %v\n", err)
+	}
+	if err := storage.LastSnapshotError(); err != nil {
+		fmt.Printf("Ошибка последнего снимка: %v\n", err)
+	}
+
+	// Сохраняем данные и освобождаем каталог data
+	if err := storage.Close(); err != nil {
+		fmt.Printf("Ошибка закрытия хранилища: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println("\n=== Программа завершена корректно ===")
 }
\ No newline at end of file
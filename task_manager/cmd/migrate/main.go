@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"task-manager/internal/repository"
+)
+
+// Явная миграция файлов данных на текущую версию формата с резервной копией.
+// Парольная фраза зашифрованных файлов берётся из переменной окружения TASK_MANAGER_PASSPHRASE
+func main() {
+	tasksFile := flag.String("tasks", "data/tasks", "файл задач без расширения")
+	notesFile := flag.String("notes", "data/notes", "файл заметок без расширения")
+	flag.Parse()
+
+	fmt.Printf("=== Миграция данных на версию формата %d ===\n", repository.CurrentFormatVersion)
+	for _, m := range repository.Migrations() {
+		fmt.Printf("  v%d -> v%d: %s\n", m.From, m.From+1, m.Description)
+	}
+	fmt.Println()
+
+	results, err := repository.MigrateFiles(*tasksFile, *notesFile,
+		repository.WithEncryption(os.Getenv("TASK_MANAGER_PASSPHRASE")))
+	for _, r := range results {
+		if r.Backup == "" {
+			fmt.Printf("%s: уже в версии %d\n", r.File, r.ToVersion)
+			continue
+		}
+		fmt.Printf("%s: v%d -> v%d, резервная копия %s\n", r.File, r.FromVersion, r.ToVersion, r.Backup)
+	}
+
+	if err != nil {
+		fmt.Printf("Ошибка миграции: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"task-manager/internal/repository"
+)
+
+// Сверка основного формата файлов данных с зеркалом и разрешение расхождений.
+// Если задана TASK_MANAGER_PASSPHRASE, файлы читаются и записываются зашифрованными
+func main() {
+	tasksFile := flag.String("tasks", "data/tasks", "файл задач без расширения")
+	notesFile := flag.String("notes", "data/notes", "файл заметок без расширения")
+	formats := flag.String("formats", "json,csv", "основной формат и зеркала через запятую")
+	policy := flag.String("policy", string(repository.PreferNewest), "правило разрешения: имя формата или newest")
+	dryRun := flag.Bool("dry-run", false, "только показать расхождения, не перезаписывая файлы")
+	flag.Parse()
+
+	names := strings.Split(*formats, ",")
+	fmt.Printf("=== Сверка %s (правило: %s) ===\n", strings.Join(names, " и "), *policy)
+
+	report, err := repository.ReconcileFiles(*tasksFile, *notesFile, repository.ReconcilePolicy(*policy), *dryRun,
+		repository.WithFormats(names[0], names[1:]...),
+		repository.WithEncryption(os.Getenv("TASK_MANAGER_PASSPHRASE")))
+	if report != nil {
+		if report.Load.HasIssues() {
+			fmt.Println("Проблемы при чтении файлов:")
+			fmt.Print(report.Load)
+		}
+
+		if len(report.Diffs) == 0 {
+			fmt.Println("Расхождений не найдено")
+		}
+		for _, d := range report.Diffs {
+			fmt.Printf("  %s\n", d)
+		}
+
+		if report.Written {
+			fmt.Println("Результат записан во все форматы")
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("Ошибка сверки: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"task-manager/internal/repository"
+)
+
+// Смена парольной фразы зашифрованных файлов данных.
+// Фразы берутся из переменных окружения, чтобы не попадать в историю команд и список процессов:
+// TASK_MANAGER_PASSPHRASE - текущая (пусто - файлы не зашифрованы),
+// TASK_MANAGER_NEW_PASSPHRASE - новая (пусто - снять шифрование)
+func main() {
+	tasksFile := flag.String("tasks", "data/tasks", "файл задач без расширения")
+	notesFile := flag.String("notes", "data/notes", "файл заметок без расширения")
+	snapshotDir := flag.String("snapshots", "", "каталог снимков (по умолчанию snapshots рядом с файлом задач)")
+	flag.Parse()
+
+	oldPassphrase := os.Getenv("TASK_MANAGER_PASSPHRASE")
+	newPassphrase := os.Getenv("TASK_MANAGER_NEW_PASSPHRASE")
+	if oldPassphrase == newPassphrase {
+		fmt.Println("Новая парольная фраза совпадает с текущей")
+		os.Exit(1)
+	}
+
+	err := repository.RekeyFiles(*tasksFile, *notesFile, oldPassphrase, newPassphrase,
+		repository.WithSnapshots(repository.SnapshotOptions{Dir: *snapshotDir}))
+	if err != nil {
+		fmt.Printf("Ошибка смены ключа: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case newPassphrase == "":
+		fmt.Println("Шифрование снято")
+	case oldPassphrase == "":
+		fmt.Println("Файлы зашифрованы")
+	default:
+		fmt.Println("Ключ заменён")
+	}
+	fmt.Println("Прежние файлы, если они были, сохранены в снимок pre-rekey; все снимки перешифрованы новым ключом")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"task-manager/internal/repository"
+)
+
+// Снимки файлов данных: список, создание, восстановление и удаление старых
+func main() {
+	tasksFile := flag.String("tasks", "data/tasks", "файл задач без расширения")
+	notesFile := flag.String("notes", "data/notes", "файл заметок без расширения")
+	dir := flag.String("dir", "", "каталог снимков (по умолчанию snapshots рядом с файлом задач)")
+	take := flag.String("take", "", "сделать снимок с указанной причиной")
+	restore := flag.String("restore", "", "восстановить снимок с указанным ID")
+	prune := flag.Bool("prune", false, "удалить снимки по политике хранения")
+	keepLast := flag.Int("keep-last", repository.DefaultRetention.KeepLast, "хранить последние N снимков")
+	keepDaily := flag.Int("keep-daily", repository.DefaultRetention.KeepDaily, "хранить по снимку за последние N дней")
+	keepWeekly := flag.Int("keep-weekly", repository.DefaultRetention.KeepWeekly, "хранить по снимку за последние N недель")
+	flag.Parse()
+
+	if *dir == "" {
+		*dir = filepath.Join(filepath.Dir(*tasksFile), "snapshots")
+	}
+
+	switch {
+	case *take != "":
+		info, err := repository.TakeSnapshot(*tasksFile, *notesFile, *dir, *take)
+		if err != nil {
+			fmt.Printf("Ошибка создания снимка: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Снимок создан: %s\n", info.ID)
+
+	case *restore != "":
+		if err := repository.RestoreSnapshot(*tasksFile, *notesFile, *dir, *restore); err != nil {
+			fmt.Printf("Ошибка восстановления снимка: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Снимок %s восстановлен, прежнее состояние сохранено в снимок pre-restore\n", *restore)
+
+	case *prune:
+		policy := repository.RetentionPolicy{KeepLast: *keepLast, KeepDaily: *keepDaily, KeepWeekly: *keepWeekly}
+		removed, err := repository.PruneSnapshots(*dir, policy)
+		for _, info := range removed {
+			fmt.Printf("  удалён %s (%s)\n", info.ID, info.Reason)
+		}
+		if err != nil {
+			fmt.Printf("Ошибка удаления снимков: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Удалено снимков: %d\n", len(removed))
+
+	default:
+		snapshots, err := repository.ListSnapshots(*dir)
+		if err != nil {
+			fmt.Printf("Ошибка чтения снимков: %v\n", err)
+			os.Exit(1)
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("Снимков нет")
+		}
+		for _, info := range snapshots {
+			fmt.Printf("  %s  %s  %s\n", info.ID, info.Time.Local().Format("2006-01-02 15:04:05"), info.Reason)
+		}
+	}
+}
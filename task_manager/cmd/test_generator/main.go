@@ -1,6 +1,7 @@
 package main
 
 import (
+    "context"
     "fmt"
     "task-manager/internal/repository"
     "task-manager/internal/service"
@@ -8,23 +9,28 @@ import (
 )
 
 func main() {
-    fmt.Println("=== Тестирование генератора моделей ===\n")
+    fmt.Println("=== Тестирование генератора моделей ===")
+    fmt.Println()
     
-    storage := repository.NewStorage()
+    // Хранилище в памяти, чтобы тест не трогал файлы в data/
+    storage := repository.NewMemoryStorage()
+    
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
     
     fmt.Println("Запускаем генератор моделей...")
     
+    modelChan := make(chan interface{}, 10)
+    
     // Запускаем генератор в горутине
     go func() {
-        if err := service.GenerateModels(storage, 10); err != nil {
-            fmt.Printf("Ошибка генерации: %v\n", err)
-        } else {
-            fmt.Println("Генерация завершена!")
-        }
+        defer close(modelChan)
+        service.GenerateModels(ctx, modelChan, 10)
+        fmt.Println("Генерация завершена!")
     }()
     
-    // Ждём, чтобы генератор успел поработать
-    time.Sleep(2 * time.Second)
+    // Приёмник сохраняет модели, пока генератор не закроет канал
+    service.Receiver(ctx, modelChan, storage)
     
     // Получаем результаты
     taskCount, noteCount := storage.Count()
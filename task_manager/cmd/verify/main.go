@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"task-manager/internal/repository"
+)
+
+// Проверка файлов данных по манифестам контрольных сумм
+func main() {
+	tasksFile := flag.String("tasks", "data/tasks", "файл задач без расширения")
+	notesFile := flag.String("notes", "data/notes", "файл заметок без расширения")
+	flag.Parse()
+
+	report := repository.VerifyFiles(*tasksFile, *notesFile)
+	if !report.HasIssues() {
+		fmt.Println("Все файлы данных целы")
+		return
+	}
+
+	fmt.Println("Повреждённые файлы:")
+	fmt.Print(report)
+	fmt.Println("При следующем открытии хранилища они будут восстановлены из второго формата или последнего снимка")
+	os.Exit(1)
+}
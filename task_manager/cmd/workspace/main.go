@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"task-manager/internal/repository"
+)
+
+// Рабочие пространства: список, создание, переименование, удаление и перенос задач и заметок
+func main() {
+	root := flag.String("root", "data/workspaces", "корневой каталог рабочих пространств")
+	create := flag.String("create", "", "создать рабочее пространство")
+	rename := flag.String("rename", "", "переименовать рабочее пространство (новое имя в -to)")
+	remove := flag.String("delete", "", "удалить рабочее пространство")
+	force := flag.Bool("force", false, "удалить рабочее пространство, даже если в нём есть данные")
+	moveTask := flag.Int("move-task", 0, "перенести задачу с указанным ID из -from в -to")
+	moveNote := flag.Int("move-note", 0, "перенести заметку с указанным ID из -from в -to")
+	from := flag.String("from", "", "исходное рабочее пространство")
+	to := flag.String("to", "", "целевое рабочее пространство или новое имя")
+	flag.Parse()
+
+	workspaces, err := repository.NewWorkspaces(*root)
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		os.Exit(1)
+	}
+	switch {
+	case *create != "":
+		if _, err := workspaces.Create(*create); err != nil {
+			fail("Ошибка создания рабочего пространства", err, workspaces)
+		}
+		fmt.Printf("Рабочее пространство %s создано\n", *create)
+
+	case *rename != "":
+		if err := workspaces.Rename(*rename, *to); err != nil {
+			fail("Ошибка переименования рабочего пространства", err, workspaces)
+		}
+		fmt.Printf("Рабочее пространство %s переименовано в %s\n", *rename, *to)
+
+	case *remove != "":
+		if err := workspaces.Delete(*remove, *force); err != nil {
+			fail("Ошибка удаления рабочего пространства", err, workspaces)
+		}
+		fmt.Printf("Рабочее пространство %s удалено\n", *remove)
+
+	case *moveTask != 0:
+		id, err := workspaces.MoveTask(*moveTask, *from, *to)
+		if err != nil {
+			fail("Ошибка переноса задачи", err, workspaces)
+		}
+		fmt.Printf("Задача %d перенесена из %s в %s под ID %d\n", *moveTask, *from, *to, id)
+
+	case *moveNote != 0:
+		id, err := workspaces.MoveNote(*moveNote, *from, *to)
+		if err != nil {
+			fail("Ошибка переноса заметки", err, workspaces)
+		}
+		fmt.Printf("Заметка %d перенесена из %s в %s под ID %d\n", *moveNote, *from, *to, id)
+
+	default:
+		names, err := workspaces.List()
+		if err != nil {
+			fail("Ошибка чтения рабочих пространств", err, workspaces)
+		}
+		if len(names) == 0 {
+			fmt.Println("Рабочих пространств нет")
+		}
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	// Сохраняем данные открытых пространств и освобождаем их каталоги
+	if err := workspaces.Close(); err != nil {
+		fmt.Printf("Ошибка закрытия рабочих пространств: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// fail закрывает рабочие пространства и завершает программу с ошибкой
+func fail(message string, err error, workspaces *repository.Workspaces) {
+	fmt.Printf("%s: %v\n", message, err)
+	if err := workspaces.Close(); err != nil {
+		fmt.Printf("Ошибка закрытия рабочих пространств: %v\n", err)
+	}
+	os.Exit(1)
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"errors"
+	"fmt"
+)
+
 type ValidationError struct {
 	message string
 }
@@ -18,4 +23,81 @@ func (e *ValidationError) Error() string {
 func IsValidationError(err error) bool {
 	_, ok := err.(*ValidationError)
 	return ok
-}
\ No newline at end of file
+}
+
+// Ошибка отсутствия сущности с указанным ID
+type NotFoundError struct {
+	entity string
+	id     int
+}
+
+// Создание новой ошибки отсутствия сущности
+func NewNotFoundError(entity string, id int) *NotFoundError {
+	return &NotFoundError{entity: entity, id: id}
+}
+
+// Возврат текста ошибки
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s with id %d not found", e.entity, e.id)
+}
+
+// Геттер типа сущности
+func (e *NotFoundError) GetEntity() string {
+	return e.entity
+}
+
+// Геттер ID сущности
+func (e *NotFoundError) GetID() int {
+	return e.id
+}
+
+// Проверка на ошибку отсутствия сущности
+func IsNotFoundError(err error) bool {
+	var target *NotFoundError
+	return errors.As(err, &target)
+}
+
+// Ошибка конфликта версий: запись изменили после того, как её прочитал вызывающий
+type ConflictError struct {
+	entity   string
+	id       int
+	expected int
+	actual   int
+}
+
+// Создание новой ошибки конфликта версий
+func NewConflictError(entity string, id, expected, actual int) *ConflictError {
+	return &ConflictError{entity: entity, id: id, expected: expected, actual: actual}
+}
+
+// Возврат текста ошибки
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s with id %d was modified concurrently: expected version %d, stored version %d",
+		e.entity, e.id, e.expected, e.actual)
+}
+
+// Геттер типа сущности
+func (e *ConflictError) GetEntity() string {
+	return e.entity
+}
+
+// Геттер ID сущности
+func (e *ConflictError) GetID() int {
+	return e.id
+}
+
+// Геттер версии, которую ожидал вызывающий
+func (e *ConflictError) GetExpectedVersion() int {
+	return e.expected
+}
+
+// Геттер версии, которая сохранена в хранилище
+func (e *ConflictError) GetActualVersion() int {
+	return e.actual
+}
+
+// Проверка на ошибку конфликта версий
+func IsConflictError(err error) bool {
+	var target *ConflictError
+	return errors.As(err, &target)
+}
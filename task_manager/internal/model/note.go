@@ -10,6 +10,8 @@ type Note struct {
     category  NoteCategory
     createdAt time.Time
     updatedAt time.Time
+    version   int
+    deletedAt *time.Time
 }
 
 // NoteCategory представляет категорию заметки
@@ -81,4 +83,39 @@ func (n *Note) SetCreatedAt(createdAt time.Time) {
 // SetUpdatedAt устанавливает дату последнего обновления заметки
 func (n *Note) SetUpdatedAt(updatedAt time.Time) {
 	n.updatedAt = updatedAt
-}
\ No newline at end of file
+}
+
+// GetVersion возвращает версию заметки; хранилище увеличивает её при каждом изменении
+func (n *Note) GetVersion() int {
+	return n.version
+}
+
+// SetVersion устанавливает версию заметки (только для внутреннего использования)
+func (n *Note) SetVersion(version int) {
+	n.version = version
+}
+
+// GetDeletedAt возвращает время перемещения заметки в корзину или nil
+func (n *Note) GetDeletedAt() *time.Time {
+	return n.deletedAt
+}
+
+// SetDeletedAt помечает заметку удалённой (nil - снимает пометку); только для внутреннего использования
+func (n *Note) SetDeletedAt(deletedAt *time.Time) {
+	n.deletedAt = deletedAt
+}
+
+// IsDeleted сообщает, находится ли заметка в корзине
+func (n *Note) IsDeleted() bool {
+	return n.deletedAt != nil
+}
+
+// Clone возвращает независимую копию заметки
+func (n *Note) Clone() *Note {
+	clone := *n
+	if n.deletedAt != nil {
+		deletedAt := *n.deletedAt
+		clone.deletedAt = &deletedAt
+	}
+	return &clone
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+    "sync/atomic"
     "time"
 )
 
@@ -14,6 +15,8 @@ type Task struct {
     createdAt   time.Time
     updatedAt   time.Time
     dueDate     *time.Time
+    version     int
+    deletedAt   *time.Time
 }
 
 // TaskStatus представляет статус задачи
@@ -63,10 +66,15 @@ func (t *Task) GetID() int {
     return t.id
 }
 
+// idChanges считает вызовы SetID: по нему TaskList узнаёт, что ID задач могли измениться
+// и промах в индексе нужно перепроверить
+var idChanges atomic.Uint64
+
 // SetID устанавливает идентификатор задачи (только для внутреннего использования)
 func (t *Task) SetID(id int) {
     t.id = id
     t.updatedAt = time.Now()
+    idChanges.Add(1)
 }
 
 // GetTitle возвращает заголовок задачи
@@ -217,4 +225,43 @@ func (t *Task) SetCreatedAt(createdAt time.Time) {
 // SetUpdatedAt устанавливает дату последнего обновления задачи
 func (t *Task) SetUpdatedAt(updatedAt time.Time) {
 	t.updatedAt = updatedAt
-}
\ No newline at end of file
+}
+
+// GetVersion возвращает версию задачи; хранилище увеличивает её при каждом изменении
+func (t *Task) GetVersion() int {
+	return t.version
+}
+
+// SetVersion устанавливает версию задачи (только для внутреннего использования)
+func (t *Task) SetVersion(version int) {
+	t.version = version
+}
+
+// GetDeletedAt возвращает время перемещения задачи в корзину или nil
+func (t *Task) GetDeletedAt() *time.Time {
+	return t.deletedAt
+}
+
+// SetDeletedAt помечает задачу удалённой (nil - снимает пометку); только для внутреннего использования
+func (t *Task) SetDeletedAt(deletedAt *time.Time) {
+	t.deletedAt = deletedAt
+}
+
+// IsDeleted сообщает, находится ли задача в корзине
+func (t *Task) IsDeleted() bool {
+	return t.deletedAt != nil
+}
+
+// Clone возвращает независимую копию задачи
+func (t *Task) Clone() *Task {
+	clone := *t
+	if t.dueDate != nil {
+		dueDate := *t.dueDate
+		clone.dueDate = &dueDate
+	}
+	if t.deletedAt != nil {
+		deletedAt := *t.deletedAt
+		clone.deletedAt = &deletedAt
+	}
+	return &clone
+}
@@ -3,71 +3,120 @@ package model
 // Список задач с методами по фильтрации
 type TaskList struct {
 	tasks []*Task
+	// byID - позиция задачи по ID. ID задачи можно поменять через SetID, поэтому
+	// попадание сверяется с задачей, а промах после вызовов SetID перестраивает индекс
+	byID      map[int]int
+	idChanges uint64 // значение счётчика SetID, при котором построен индекс
 }
 
 // Создание списка задач
 func NewTaskList() *TaskList {
 	return &TaskList{
 		tasks: make([]*Task, 0),
+		byID:  make(map[int]int),
 	}
 }
 
 // Добавление задачи в список
 func (tl *TaskList) Add(task *Task) {
 	tl.tasks = append(tl.tasks, task)
+	if tl.byID == nil {
+		tl.reindex()
+		return
+	}
+	if _, exists := tl.byID[task.GetID()]; !exists {
+		tl.byID[task.GetID()] = len(tl.tasks) - 1
+	}
 }
 
 // Удаление задачи из списка по ID
 func (tl *TaskList) Remove(taskID int) bool {
-	for i, task := range tl.tasks {
-		if task.GetID() == taskID {
-			tl.tasks = append(tl.tasks[:i], tl.tasks[i+1:]...)
-			return true
-		}
+	i, ok := tl.find(taskID)
+	if !ok {
+		return false
 	}
-	return false
+	tl.tasks = append(tl.tasks[:i], tl.tasks[i+1:]...)
+	// Задачи после удалённой сдвинулись, а другая задача с тем же ID могла стать первой
+	tl.reindex()
+	return true
 }
 
-// Геттер задачи по ID
+// Геттер задачи по ID; удалённые в корзину и восстановленные задачи остаются на своих местах,
+// поэтому индекс для них не меняется
 func (tl *TaskList) GetByID(taskID int) *Task {
-	for _, task := range tl.tasks {
-		if task.GetID() == taskID {
-			return task
-		}
+	if i, ok := tl.find(taskID); ok {
+		return tl.tasks[i]
 	}
 	return nil
 }
 
-// Геттер всех задач
+// Поиск позиции задачи по индексу; устаревший после SetID индекс перестраивается
+func (tl *TaskList) find(taskID int) (int, bool) {
+	i, ok := tl.byID[taskID]
+	if ok && tl.tasks[i].GetID() == taskID {
+		return i, true
+	}
+	if !ok && tl.byID != nil && tl.idChanges == idChanges.Load() {
+		return 0, false
+	}
+
+	tl.reindex()
+	i, ok = tl.byID[taskID]
+	return i, ok
+}
+
+// Перестроение индекса по текущим ID задач
+func (tl *TaskList) reindex() {
+	tl.idChanges = idChanges.Load()
+	tl.byID = make(map[int]int, len(tl.tasks))
+	for i := len(tl.tasks) - 1; i >= 0; i-- {
+		tl.byID[tl.tasks[i].GetID()] = i
+	}
+}
+
+// Геттер всех задач, кроме удалённых в корзину
 func (tl *TaskList) GetAll() []*Task {
-	return tl.tasks
+	return tl.Filter(nil)
 }
 
-// Геттер на задачи по фильтру
-func (tl *TaskList) Filter(filter *TaskFilter) []*Task {
-	if filter == nil {
-		return tl.tasks
+// Геттер задач в корзине
+func (tl *TaskList) GetDeleted() []*Task {
+	var result []*Task
+	for _, task := range tl.tasks {
+		if task.IsDeleted() {
+			result = append(result, task)
+		}
 	}
+	return result
+}
 
+// Геттер на задачи по фильтру; удалённые в корзину задачи не попадают в результат
+func (tl *TaskList) Filter(filter *TaskFilter) []*Task {
 	var result []*Task
 	for _, task := range tl.tasks {
-		if matchesFilter(task, filter) {
+		if !task.IsDeleted() && (filter == nil || matchesFilter(task, filter)) {
 			result = append(result, task)
 		}
 	}
 	return result
 }
 
-// Геттер числа задач
+// Геттер числа задач без учёта удалённых
 func (tl *TaskList) Count() int {
-	return len(tl.tasks)
+	count := 0
+	for _, task := range tl.tasks {
+		if !task.IsDeleted() {
+			count++
+		}
+	}
+	return count
 }
 
-// Геттер числа задач по статусу
+// Геттер числа задач по статусу без учёта удалённых
 func (tl *TaskList) CountByStatus(status TaskStatus) int {
 	count := 0
 	for _, task := range tl.tasks {
-		if task.GetStatus() == status {
+		if !task.IsDeleted() && task.GetStatus() == status {
 			count++
 		}
 	}
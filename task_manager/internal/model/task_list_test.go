@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// newListTask создаёт задачу с указанным ID
+func newListTask(t *testing.T, id int) *Task {
+	t.Helper()
+	task, err := NewTask("Задача", "Описание", PriorityMedium, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.SetID(id)
+	return task
+}
+
+// Индекс GetByID не устаревает ни после удаления из середины списка, ни после смены ID через SetID
+func TestTaskListGetByID(t *testing.T) {
+	tests := []struct {
+		name string
+		// change меняет список из задач с ID 1-4
+		change func(tl *TaskList, tasks []*Task)
+		want   map[int]int // ID -> номер задачи в tasks, -1 - задачи нет
+	}{
+		{"без изменений", func(tl *TaskList, tasks []*Task) {}, map[int]int{1: 0, 4: 3, 5: -1}},
+		{"удаление из середины", func(tl *TaskList, tasks []*Task) { tl.Remove(2) }, map[int]int{1: 0, 2: -1, 3: 2, 4: 3}},
+		{"смена ID", func(tl *TaskList, tasks []*Task) { tasks[1].SetID(10) }, map[int]int{2: -1, 10: 1, 3: 2}},
+		{"ID добавленной задачи задан позже", func(tl *TaskList, tasks []*Task) {
+			tl.Add(tasks[4])
+			tasks[4].SetID(5)
+		}, map[int]int{5: 4, 0: -1}},
+		{"удаление и восстановление", func(tl *TaskList, tasks []*Task) {
+			now := time.Now()
+			tasks[2].SetDeletedAt(&now)
+			tasks[2].SetDeletedAt(nil)
+		}, map[int]int{3: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := NewTaskList()
+			var tasks []*Task
+			for id := 1; id <= 4; id++ {
+				task := newListTask(t, id)
+				tasks = append(tasks, task)
+				tl.Add(task)
+			}
+			tasks = append(tasks, newListTask(t, 0))
+
+			// Индекс строится до изменения
+			tl.GetByID(1)
+			tt.change(tl, tasks)
+
+			for id, want := range tt.want {
+				got := tl.GetByID(id)
+				if want < 0 && got != nil {
+					t.Errorf("GetByID(%d) нашёл задачу с ID %d", id, got.GetID())
+				}
+				if want >= 0 && got != tasks[want] {
+					t.Errorf("GetByID(%d) вернул не задачу %d", id, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile описывает один файл, который нужно записать атомарно
+type atomicFile struct {
+	path  string
+	write func(w io.Writer) error
+	// last - содержимое зависит от остальных файлов группы (например, манифест с их
+	// контрольными суммами), поэтому временный файл пишется после них. Порядок переименования не меняется
+	last bool
+}
+
+// writeFilesAtomic записывает группу файлов так, чтобы сбой не испортил последние удачные копии.
+// Сначала все файлы пишутся во временные файлы рядом с целевыми и сбрасываются на диск (fsync),
+// и только если все записи удались, временные файлы переименовываются поверх целевых.
+func writeFilesAtomic(files ...atomicFile) error {
+	tmpPaths := make([]string, len(files))
+	cleanup := func() {
+		for _, tmp := range tmpPaths {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}
+	}
+
+	for _, last := range []bool{false, true} {
+		for i, f := range files {
+			if f.last != last {
+				continue
+			}
+			tmp, err := writeTempFile(f)
+			if err != nil {
+				cleanup()
+				return err
+			}
+			tmpPaths[i] = tmp
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for i, f := range files {
+		if err := os.Rename(tmpPaths[i], f.path); err != nil {
+			// Уже переименованные файлы остаются новыми, остальные - прежними целыми копиями
+			cleanup()
+			return fmt.Errorf("ошибка замены файла %s: %w", f.path, err)
+		}
+		dirs[filepath.Dir(f.path)] = true
+	}
+
+	// Сбрасываем на диск записи каталогов, чтобы переименования пережили сбой питания
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic атомарно записывает один файл
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	return writeFilesAtomic(atomicFile{path: path, write: write})
+}
+
+// writeTempFile записывает содержимое во временный файл рядом с целевым и возвращает его путь
+func writeTempFile(f atomicFile) (string, error) {
+	dir, base := filepath.Split(f.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	file, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := file.Name()
+
+	// Форматы пишут записи по одной, поэтому вывод буферизуется, а не уходит в файл мелкими записями
+	buffered := bufio.NewWriter(file)
+	err = f.write(buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("ошибка записи %s: %w", f.path, err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("ошибка сброса на диск %s: %w", f.path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	// os.CreateTemp создаёт файл с правами 0600, возвращаем привычные права
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	return tmp, nil
+}
+
+// syncDir сбрасывает на диск содержимое каталога
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Не все платформы и файловые системы поддерживают fsync каталога,
+	// поэтому ошибка здесь не считается фатальной
+	_ = d.Sync()
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeString возвращает функцию записи строки s
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+// Сбой записи любого файла группы оставляет все файлы прежними и не оставляет временных файлов
+func TestWriteFilesAtomicFailure(t *testing.T) {
+	errWrite := errors.New("запись прервана")
+	tests := []struct {
+		name   string
+		failAt int // номер файла группы, запись которого прерывается
+	}{
+		{"первый файл", 0},
+		{"второй файл", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			paths := []string{filepath.Join(dir, "tasks.json"), filepath.Join(dir, "tasks.csv")}
+			for _, path := range paths {
+				if err := os.WriteFile(path, []byte("прежнее"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files := make([]atomicFile, len(paths))
+			for i, path := range paths {
+				files[i] = atomicFile{path: path, write: writeString("новое")}
+			}
+			files[tt.failAt].write = func(w io.Writer) error {
+				io.WriteString(w, "частично")
+				return errWrite
+			}
+
+			if err := writeFilesAtomic(files...); !errors.Is(err, errWrite) {
+				t.Fatalf("ожидалась ошибка записи, получено %v", err)
+			}
+			for _, path := range paths {
+				if data, _ := os.ReadFile(path); string(data) != "прежнее" {
+					t.Errorf("%s изменён: %q", path, data)
+				}
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != len(paths) {
+				t.Errorf("в каталоге %d файлов, временные файлы не удалены", len(entries))
+			}
+		})
+	}
+}
+
+// Успешная запись заменяет файлы целиком и сохраняет обычные права доступа
+func TestWriteFileAtomicReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.json")
+	if err := os.WriteFile(path, []byte("длинное прежнее содержимое"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, writeString("новое")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "новое" {
+		t.Errorf("содержимое %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("права %v, ожидались 0644", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/model"
+	"time"
+)
+
+// Параметры автосохранения по умолчанию
+const (
+	DefaultAutosaveInterval   = 5 * time.Second
+	DefaultAutosaveMaxChanges = 50
+)
+
+// AutosaveOptions задаёт, как часто фоновое автосохранение сбрасывает изменения в бэкенд;
+// нулевые значения заменяются значениями по умолчанию
+type AutosaveOptions struct {
+	// Interval - период, с которым сохраняются накопленные изменения
+	Interval time.Duration
+	// MaxChanges - число изменений, после которого сохранение запускается не дожидаясь интервала
+	MaxChanges int
+}
+
+// autosaveWorker - состояние фоновой горутины автосохранения
+type autosaveWorker struct {
+	options AutosaveOptions
+	changes int // изменений с последнего сохранения, защищено s.mu
+	trigger chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// StartAutosave переводит хранилище в режим отложенного сохранения:
+// изменения только помечают хранилище как изменённое, а фоновая горутина
+// сохраняет их раз в Interval или после MaxChanges изменений.
+// С журналом (WithJournal) изменения по-прежнему сразу дописываются в журнал, а горутина
+// раз в Interval сворачивает журнал, превысивший порог размера или возраста.
+// Ошибки фоновых сохранений не выводятся, а запоминаются и доступны через LastSaveError.
+// Горутина останавливается с финальным сохранением при отмене ctx или в Close
+func (s *Storage) StartAutosave(ctx context.Context, options AutosaveOptions) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultAutosaveInterval
+	}
+	if options.MaxChanges <= 0 {
+		options.MaxChanges = DefaultAutosaveMaxChanges
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	if s.autosave != nil {
+		return model.NewValidationError("autosave is already running")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &autosaveWorker{
+		options: options,
+		trigger: make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	s.autosave = w
+
+	go s.runAutosave(ctx, w)
+	return nil
+}
+
+// StopAutosave останавливает фоновое автосохранение, дожидаясь финального сохранения,
+// и возвращает хранилище в режим синхронного сохранения при каждом изменении
+func (s *Storage) StopAutosave() {
+	s.mu.RLock()
+	w := s.autosave
+	s.mu.RUnlock()
+
+	if w == nil {
+		return
+	}
+
+	w.cancel()
+	<-w.done
+}
+
+// LastSaveTime возвращает время последнего сохранения в бэкенд
+func (s *Storage) LastSaveTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSaveTime
+}
+
+// LastSaveError возвращает ошибку последнего сохранения или nil, если оно прошло успешно
+func (s *Storage) LastSaveError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSaveErr
+}
+
+// runAutosave - цикл фоновой горутины автосохранения
+func (s *Storage) runAutosave(ctx context.Context, w *autosaveWorker) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Финальное сохранение и возврат к синхронному режиму
+			s.mu.Lock()
+			s.autosave = nil
+			s.mu.Unlock()
+
+			// Ошибки сохранения доступны через LastSaveError
+			s.flush(false)
+			return
+		case <-ticker.C:
+			s.flush(false)
+		case <-w.trigger:
+			s.flush(false)
+		}
+	}
+}
+
+// markDirty помечает коллекцию изменённой и будит горутину автосохранения,
+// если накопилось MaxChanges изменений
+// Вызывается под блокировкой s.mu в режиме автосохранения
+func (s *Storage) markDirty(tasks, notes bool) {
+	s.tasksDirty = s.tasksDirty || tasks
+	s.notesDirty = s.notesDirty || notes
+
+	w := s.autosave
+	w.changes++
+	if w.changes >= w.options.MaxChanges {
+		w.wake()
+	}
+}
+
+// wake будит горутину автосохранения, не дожидаясь интервала
+func (w *autosaveWorker) wake() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+		// Сохранение уже запрошено
+	}
+}
+
+// flush сохраняет изменённые коллекции в бэкенд (при force - обе коллекции)
+// и запоминает результат для LastSaveError. Коллекции, журнал которых превысил порог
+// размера или возраста, тоже сохраняются: так журнал сворачивается и без новых изменений.
+// Снимок коллекций берётся под s.mu, а запись идёт без неё, поэтому чтения под s.mu не ждут
+// окончания ввода-вывода. s.saveMu удерживается всё время: изменения берут его первым, так что
+// между снимком и записью ни одно изменение не попадёт в бэкенд и не будет перезаписано старым снимком
+func (s *Storage) flush(force bool) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	// Внешние правки подхватываются до копирования, чтобы устаревшее состояние их не перезаписало
+	if err := s.syncExternalLocked(); err != nil {
+		s.lastSaveErr = err
+		s.mu.Unlock()
+		return err
+	}
+
+	saveTasks := force || s.tasksDirty
+	saveNotes := force || s.notesDirty
+	if inc, ok := s.backend.(IncrementalBackend); ok {
+		saveTasks = saveTasks || inc.TasksNeedCompaction()
+		saveNotes = saveNotes || inc.NotesNeedCompaction()
+	}
+	if !saveTasks && !saveNotes {
+		s.mu.Unlock()
+		return nil
+	}
+
+	// Опубликованный снимок неизменяем, поэтому его можно записывать без копирования
+	view := s.loadView()
+	tasks, notes := view.tasks, view.notes
+	s.tasksDirty = false
+	s.notesDirty = false
+	if s.autosave != nil {
+		s.autosave.changes = 0
+	}
+	s.mu.Unlock()
+
+	var err error
+	if saveTasks && saveNotes {
+		// Обе коллекции записываются вместе, чтобы изменения транзакций не разошлись между файлами
+		if saveErr := saveCollections(s.backend, tasks, notes); saveErr != nil {
+			err = fmt.Errorf("ошибка сохранения данных: %w", saveErr)
+		}
+	} else if saveTasks {
+		if saveErr := s.backend.SaveTasks(tasks); saveErr != nil {
+			err = fmt.Errorf("ошибка сохранения задач: %w", saveErr)
+		}
+	} else if saveNotes {
+		if saveErr := s.backend.SaveNotes(notes); saveErr != nil {
+			err = fmt.Errorf("ошибка сохранения заметок: %w", saveErr)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		// Не сохранённые изменения попадут в следующую попытку
+		s.tasksDirty = s.tasksDirty || saveTasks
+		s.notesDirty = s.notesDirty || saveNotes
+	} else {
+		s.lastSaveTime = time.Now()
+	}
+	s.lastSaveErr = err
+	return err
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// waitFor ждёт, пока cond не станет истинным
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// fileExists сообщает, есть ли файл на диске
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Изменения откладываются до фонового сохранения, которое срабатывает по числу изменений
+func TestAutosaveMaxChanges(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	if err := storage.StartAutosave(context.Background(), AutosaveOptions{Interval: time.Hour, MaxChanges: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk := func() int {
+		reader, _ := openStorage(t, tasksFile, notesFile, WithReadOnly())
+		tasks, _ := reader.Count()
+		return tasks
+	}
+
+	addTask(t, storage, "Первая")
+	addTask(t, storage, "Вторая")
+	if tasks := onDisk(); tasks != 0 {
+		t.Fatalf("до MaxChanges на диске %d задач", tasks)
+	}
+	addTask(t, storage, "Третья")
+	waitFor(t, "сохранения после MaxChanges", func() bool { return onDisk() == 3 })
+
+	// Остановка автосохранения сохраняет оставшееся и возвращает синхронный режим
+	addTask(t, storage, "Четвёртая")
+	storage.StopAutosave()
+	if tasks := onDisk(); tasks != 4 {
+		t.Fatalf("после StopAutosave на диске %d задач, ожидалось 4", tasks)
+	}
+	addTask(t, storage, "Пятая")
+	if tasks := onDisk(); tasks != 5 {
+		t.Fatalf("в синхронном режиме на диске %d задач, ожидалось 5", tasks)
+	}
+}
+
+// С журналом автосохранение не откладывает изменения: каждое сразу попадает в журнал
+func TestAutosaveWithJournalAppends(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	journal := WithJournal(JournalOptions{})
+	storage, _ := openStorage(t, tasksFile, notesFile, journal)
+	if err := storage.StartAutosave(context.Background(), AutosaveOptions{Interval: time.Hour, MaxChanges: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	addTask(t, storage, "Задача")
+	if !fileExists(tasksFile + ".journal") {
+		t.Fatal("изменение не записано в журнал")
+	}
+	// Транзакция при журнале сохраняется сразу целиком
+	err := storage.Transaction(func(tx *Tx) error { return tx.AddModel(newBenchmarkTask(t, 2)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _ := openStorage(t, tasksFile, notesFile, journal, WithReadOnly())
+	if tasks, _ := reader.Count(); tasks != 2 {
+		t.Fatalf("на диске %d задач, ожидалось 2", tasks)
+	}
+}
+
+// Журнал старше MaxAge сворачивается фоновым автосохранением без новых изменений
+func TestAutosaveCompactsAgedJournal(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithJournal(JournalOptions{MaxAge: 50 * time.Millisecond}))
+	if err := storage.StartAutosave(context.Background(), AutosaveOptions{Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	addTask(t, storage, "Задача")
+	if !fileExists(tasksFile + ".journal") {
+		t.Fatal("изменение не записано в журнал")
+	}
+	waitFor(t, "свёртки журнала", func() bool { return !fileExists(tasksFile + ".journal") })
+}
+
+// Журнал, состарившийся, пока хранилище было закрыто, сворачивается при открытии
+func TestOpenCompactsAgedJournal(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		wantJournal bool
+	}{
+		{"журнал моложе порога", time.Hour, true},
+		{"журнал старше порога", time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasksFile, notesFile := tempDataFiles(t)
+			storage, _ := openStorage(t, tasksFile, notesFile, WithJournal(JournalOptions{}))
+			addTask(t, storage, "Задача")
+
+			// Журнал остаётся на диске, как после аварийного завершения
+			journal, err := os.ReadFile(tasksFile + ".journal")
+			if err != nil {
+				t.Fatal(err)
+			}
+			closeStorage(t, storage)
+			if err := os.WriteFile(tasksFile+".journal", journal, 0644); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			storage, _ = openStorage(t, tasksFile, notesFile, WithJournal(JournalOptions{MaxAge: tt.maxAge}))
+			if got := fileExists(tasksFile + ".journal"); got != tt.wantJournal {
+				t.Errorf("журнал на диске: %v, ожидалось %v", got, tt.wantJournal)
+			}
+			if tasks, _ := storage.Count(); tasks != 1 {
+				t.Errorf("загружено %d задач, ожидалась 1", tasks)
+			}
+		})
+	}
+}
+
+// savedTasks возвращает число задач, сохранённых в бэкенде
+func savedTasks(t *testing.T, backend Backend) int {
+	t.Helper()
+	tasks, err := backend.LoadTasks(&LoadReport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(tasks)
+}
+
+// Без новых изменений накопленное сохраняется по истечении Interval, а при отмене ctx
+// автосохранение останавливается и хранилище возвращается к синхронному сохранению
+func TestAutosaveInterval(t *testing.T) {
+	backend := NewMemoryBackend()
+	storage, _ := NewStorageWithBackend(backend)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := storage.StartAutosave(ctx, AutosaveOptions{Interval: 10 * time.Millisecond, MaxChanges: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StartAutosave(ctx, AutosaveOptions{}); !model.IsValidationError(err) {
+		t.Errorf("повторный запуск: ожидалась ошибка валидации, получено %v", err)
+	}
+
+	addTask(t, storage, "Задача")
+	waitFor(t, "сохранения по интервалу", func() bool { return savedTasks(t, backend) == 1 })
+	if storage.LastSaveTime().IsZero() {
+		t.Error("время сохранения не запомнено")
+	}
+
+	cancel()
+	waitFor(t, "остановки автосохранения", func() bool {
+		storage.mu.RLock()
+		defer storage.mu.RUnlock()
+		return storage.autosave == nil
+	})
+	addTask(t, storage, "Синхронная")
+	if got := savedTasks(t, backend); got != 2 {
+		t.Errorf("после отмены в бэкенде %d задач, ожидалось 2", got)
+	}
+}
+
+// Ошибка фонового сохранения доступна через LastSaveError, а несохранённые изменения
+// не теряются и уходят в следующее сохранение
+func TestAutosaveKeepsChangesAfterFailedSave(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend(), fail: true}
+	storage, _ := NewStorageWithBackend(backend)
+	if err := storage.StartAutosave(context.Background(), AutosaveOptions{Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	addTask(t, storage, "Задача")
+	waitFor(t, "ошибки сохранения", func() bool { return storage.LastSaveError() != nil })
+	if err := storage.LastSaveError(); !errors.Is(err, errBackendFailed) {
+		t.Errorf("LastSaveError: ожидалась ошибка бэкенда, получено %v", err)
+	}
+	storage.StopAutosave()
+
+	backend.fail = false
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := savedTasks(t, backend); got != 1 {
+		t.Errorf("в бэкенде %d задач, ожидалась 1", got)
+	}
+}
+
+func TestAutosaveReadOnly(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	reader, _ := openStorage(t, tasksFile, notesFile, WithReadOnly())
+	if err := reader.StartAutosave(context.Background(), AutosaveOptions{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ожидалась ErrReadOnly, получено %v", err)
+	}
+}
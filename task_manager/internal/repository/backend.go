@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"sync"
+	"task-manager/internal/model"
+)
+
+// Backend - способ постоянного хранения данных, на который опирается Storage
+type Backend interface {
+	// LoadTasks загружает все сохранённые задачи; пропущенные и исправленные записи попадают в report
+	LoadTasks(report *LoadReport) ([]*model.Task, error)
+	// SaveTasks сохраняет полный набор задач
+	SaveTasks(tasks []*model.Task) error
+	// LoadNotes загружает все сохранённые заметки; пропущенные и исправленные записи попадают в report
+	LoadNotes(report *LoadReport) ([]*model.Note, error)
+	// SaveNotes сохраняет полный набор заметок
+	SaveNotes(notes []*model.Note) error
+	// LoadSequence возвращает последнее выданное значение последовательности ID (0, если её нет)
+	LoadSequence(name string) (int, error)
+	// SaveSequence сохраняет последнее выданное значение последовательности ID
+	SaveSequence(name string, value int) error
+}
+
+// BatchBackend - бэкенд, который сохраняет обе коллекции одной записью (см. Storage.Transaction)
+type BatchBackend interface {
+	Backend
+	// SaveCollections сохраняет полные наборы задач и заметок вместе
+	SaveCollections(tasks []*model.Task, notes []*model.Note) error
+}
+
+// Проверка, что встроенные бэкенды реализуют BatchBackend
+var (
+	_ BatchBackend = (*MemoryBackend)(nil)
+	_ BatchBackend = (*FileBackend)(nil)
+	_ BatchBackend = (*JournalBackend)(nil)
+)
+
+// saveCollections сохраняет обе коллекции одной записью, если бэкенд это умеет, иначе по очереди
+func saveCollections(backend Backend, tasks []*model.Task, notes []*model.Note) error {
+	if batch, ok := backend.(BatchBackend); ok {
+		return batch.SaveCollections(tasks, notes)
+	}
+	if err := backend.SaveTasks(tasks); err != nil {
+		return err
+	}
+	return backend.SaveNotes(notes)
+}
+
+// MemoryBackend хранит данные только в памяти процесса
+type MemoryBackend struct {
+	tasks []*model.Task
+	notes []*model.Note
+	seqs  map[string]int
+	mu    sync.Mutex
+}
+
+// NewMemoryBackend создаёт пустой бэкенд в памяти
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{seqs: make(map[string]int)}
+}
+
+// LoadTasks возвращает копию сохранённых задач
+func (b *MemoryBackend) LoadTasks(report *LoadReport) ([]*model.Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tasks := make([]*model.Task, len(b.tasks))
+	copy(tasks, b.tasks)
+	return tasks, nil
+}
+
+// SaveTasks запоминает копию переданных задач
+func (b *MemoryBackend) SaveTasks(tasks []*model.Task) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tasks = make([]*model.Task, len(tasks))
+	copy(b.tasks, tasks)
+	return nil
+}
+
+// SaveCollections запоминает копии задач и заметок под одной блокировкой
+func (b *MemoryBackend) SaveCollections(tasks []*model.Task, notes []*model.Note) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tasks = make([]*model.Task, len(tasks))
+	copy(b.tasks, tasks)
+	b.notes = make([]*model.Note, len(notes))
+	copy(b.notes, notes)
+	return nil
+}
+
+// LoadNotes возвращает копию сохранённых заметок
+func (b *MemoryBackend) LoadNotes(report *LoadReport) ([]*model.Note, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	notes := make([]*model.Note, len(b.notes))
+	copy(notes, b.notes)
+	return notes, nil
+}
+
+// SaveNotes запоминает копию переданных заметок
+func (b *MemoryBackend) SaveNotes(notes []*model.Note) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.notes = make([]*model.Note, len(notes))
+	copy(b.notes, notes)
+	return nil
+}
+
+// LoadSequence возвращает сохранённое значение последовательности
+func (b *MemoryBackend) LoadSequence(name string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seqs[name], nil
+}
+
+// SaveSequence запоминает значение последовательности
+func (b *MemoryBackend) SaveSequence(name string, value int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seqs[name] = value
+	return nil
+}
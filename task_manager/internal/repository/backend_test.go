@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// plainBackend скрывает у бэкенда всё, кроме интерфейса Backend
+type plainBackend struct {
+	Backend
+}
+
+// Хранилище ведёт себя одинаково поверх любого бэкенда: данные и последовательности ID
+// переживают повторное открытие
+func TestStorageOverBackends(t *testing.T) {
+	backends := []struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		{"память", func(t *testing.T) Backend { return NewMemoryBackend() }},
+		{"только Backend", func(t *testing.T) Backend { return plainBackend{NewMemoryBackend()} }},
+		{"файлы", func(t *testing.T) Backend {
+			return NewFileBackend(tempDataFiles(t))
+		}},
+		{"журнал", func(t *testing.T) Backend {
+			dir := t.TempDir()
+			files := NewFileBackend(filepath.Join(dir, "tasks"), filepath.Join(dir, "notes"))
+			return NewJournalBackend(files, filepath.Join(dir, "tasks.journal"), filepath.Join(dir, "notes.journal"), JournalOptions{})
+		}},
+	}
+
+	for _, tt := range backends {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := tt.new(t)
+			storage, report := NewStorageWithBackend(backend)
+			if report.HasIssues() {
+				t.Fatalf("пустой бэкенд загружен с проблемами:\n%s", report)
+			}
+
+			addTask(t, storage, "Первая")
+			second := addTask(t, storage, "Вторая")
+			if err := storage.AddModel(model.NewNote("Заметка", "Содержимое", model.CategoryWork)); err != nil {
+				t.Fatal(err)
+			}
+			if err := storage.DeleteTask(second.GetID()); err != nil {
+				t.Fatal(err)
+			}
+			// Транзакция сохраняет обе коллекции одной записью или по очереди, если бэкенд этого не умеет
+			if err := storage.Transaction(func(tx *Tx) error {
+				return tx.AddModel(model.NewNote("Вторая заметка", "Содержимое", model.CategoryIdea))
+			}); err != nil {
+				t.Fatal(err)
+			}
+			closeStorage(t, storage)
+
+			reopened, _ := NewStorageWithBackend(backend)
+			if tasks, notes := reopened.Count(); tasks != 1 || notes != 2 {
+				t.Errorf("после повторного открытия %d задач и %d заметок, ожидалось 1 и 2", tasks, notes)
+			}
+			if deleted := reopened.GetDeletedTasks(); len(deleted) != 1 || deleted[0].GetID() != second.GetID() {
+				t.Errorf("корзина после повторного открытия: %d задач", len(deleted))
+			}
+			// ID не выдаются повторно
+			if third := addTask(t, reopened, "Третья"); third.GetID() != 3 {
+				t.Errorf("новая задача получила ID %d, ожидался 3", third.GetID())
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"task-manager/internal/model"
+	"time"
+)
+
+// DefaultSubscriptionBuffer - размер буфера подписки по умолчанию
+const DefaultSubscriptionBuffer = 64
+
+// ChangeEvent - изменение одной задачи или заметки.
+// Для задач заполнены BeforeTask и AfterTask, для заметок - BeforeNote и AfterNote;
+// у created нет состояния до изменения, у purged - после. У deleted (перемещение в корзину)
+// состояние после - запись с пометкой удаления, а если запись удалена извне целиком - nil.
+// Модели в событии - копии
+type ChangeEvent struct {
+	Op         ChangeOp
+	Collection string // tasks или notes
+	ID         int
+	Time       time.Time
+	External   bool // изменение подхвачено из отредактированного извне файла или снимка
+
+	BeforeTask *model.Task
+	AfterTask  *model.Task
+	BeforeNote *model.Note
+	AfterNote  *model.Note
+
+	// Missed - сколько событий подписчик пропустил перед этим, потому что не успевал читать.
+	// Если Missed > 0, состояние, собранное по событиям, стоит перечитать (например, через ListTasks)
+	Missed int
+}
+
+// SubscribeOptions - настройки подписки на изменения
+type SubscribeOptions struct {
+	Buffer int // размер буфера канала; 0 - DefaultSubscriptionBuffer
+}
+
+// changeSubscriber - состояние одной подписки, защищено s.subsMu
+type changeSubscriber struct {
+	ch     chan ChangeEvent
+	missed int
+}
+
+// Subscribe возвращает канал событий об изменениях задач и заметок: локальных, из транзакций
+// и подхваченных из файлов. События приходят в порядке изменений.
+// Хранилище никогда не ждёт подписчика: если буфер заполнен, событие отбрасывается,
+// а число пропущенных событий приходит в поле Missed следующего доставленного события.
+// Подписка снимается, а канал закрывается при отмене ctx или в Close; события, уже попавшие в буфер,
+// можно дочитать
+func (s *Storage) Subscribe(ctx context.Context, options SubscribeOptions) <-chan ChangeEvent {
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultSubscriptionBuffer
+	}
+	sub := &changeSubscriber{ch: make(chan ChangeEvent, options.Buffer)}
+
+	s.subsMu.Lock()
+	if s.changeSubs == nil {
+		s.changeSubs = make(map[*changeSubscriber]struct{})
+	}
+	s.changeSubs[sub] = struct{}{}
+	s.subsMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+		s.subsMu.Lock()
+		// После Close канал уже закрыт в closeSubscribers
+		if _, ok := s.changeSubs[sub]; ok {
+			delete(s.changeSubs, sub)
+			close(sub.ch)
+		}
+		s.subsMu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+// hasSubscribers сообщает, есть ли подписчики, чтобы не собирать события впустую
+func (s *Storage) hasSubscribers() bool {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	return len(s.changeSubs) > 0
+}
+
+// publish рассылает события подписчикам без ожидания
+// Вызывается под блокировкой s.mu, поэтому порядок событий совпадает с порядком изменений
+func (s *Storage) publish(events ...ChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for sub := range s.changeSubs {
+		for _, event := range events {
+			event.Missed = sub.missed
+			select {
+			case sub.ch <- event:
+				sub.missed = 0
+			default:
+				// Подписчик не успевает читать - событие отбрасывается и учитывается в Missed
+				sub.missed++
+			}
+		}
+	}
+}
+
+// publishTask рассылает изменение одной задачи
+// Вызывается под блокировкой s.mu
+func (s *Storage) publishTask(op ChangeOp, before, after *model.Task) {
+	if s.hasSubscribers() {
+		s.publish(taskEvent(op, before, after, time.Now()))
+	}
+}
+
+// publishNote рассылает изменение одной заметки
+// Вызывается под блокировкой s.mu
+func (s *Storage) publishNote(op ChangeOp, before, after *model.Note) {
+	if s.hasSubscribers() {
+		s.publish(noteEvent(op, before, after, time.Now()))
+	}
+}
+
+// publishReplaced рассылает разницу между прежними и новыми коллекциями после их замены целиком
+// (транзакция, внешняя правка, восстановление снимка)
+// Вызывается под блокировкой s.mu
+func (s *Storage) publishReplaced(oldTasks []*model.Task, oldNotes []*model.Note, external bool) {
+	if !s.hasSubscribers() {
+		return
+	}
+
+	now := time.Now()
+	events := collectionChanges(oldTasks, s.tasks, taskAccessors, func(op ChangeOp, before, after *model.Task) ChangeEvent {
+		return taskEvent(op, before, after, now)
+	})
+	events = append(events, collectionChanges(oldNotes, s.notes, noteAccessors, func(op ChangeOp, before, after *model.Note) ChangeEvent {
+		return noteEvent(op, before, after, now)
+	})...)
+
+	for i := range events {
+		events[i].External = external
+	}
+	s.publish(events...)
+}
+
+// taskEvent собирает событие изменения задачи с копиями состояний
+func taskEvent(op ChangeOp, before, after *model.Task, now time.Time) ChangeEvent {
+	event := ChangeEvent{Op: op, Collection: kindTasks, Time: now}
+	if before != nil {
+		event.ID, event.BeforeTask = before.GetID(), before.Clone()
+	}
+	if after != nil {
+		event.ID, event.AfterTask = after.GetID(), after.Clone()
+	}
+	return event
+}
+
+// noteEvent собирает событие изменения заметки с копиями состояний
+func noteEvent(op ChangeOp, before, after *model.Note, now time.Time) ChangeEvent {
+	event := ChangeEvent{Op: op, Collection: kindNotes, Time: now}
+	if before != nil {
+		event.ID, event.BeforeNote = before.GetID(), before.Clone()
+	}
+	if after != nil {
+		event.ID, event.AfterNote = after.GetID(), after.Clone()
+	}
+	return event
+}
+
+// collectionChanges сравнивает два состояния коллекции по ID и содержимому записей:
+// сначала изменённые и удалённые записи в прежнем порядке, затем добавленные
+func collectionChanges[T any](before, after []T, acc recordAccessors[T], event func(op ChangeOp, before, after T) ChangeEvent) []ChangeEvent {
+	afterByID := make(map[int]T, len(after))
+	for _, rec := range after {
+		afterByID[acc.id(rec)] = rec
+	}
+
+	var zero T
+	var events []ChangeEvent
+	seen := make(map[int]bool, len(before))
+	for _, old := range before {
+		id := acc.id(old)
+		seen[id] = true
+
+		rec, ok := afterByID[id]
+		switch {
+		case !ok && acc.deleted(old):
+			events = append(events, event(ChangePurged, old, zero))
+		case !ok:
+			events = append(events, event(ChangeDeleted, old, zero))
+		case acc.key(old) == acc.key(rec):
+		case !acc.deleted(old) && acc.deleted(rec):
+			events = append(events, event(ChangeDeleted, old, rec))
+		case acc.deleted(old) && !acc.deleted(rec):
+			events = append(events, event(ChangeRestored, old, rec))
+		default:
+			events = append(events, event(ChangeUpdated, old, rec))
+		}
+	}
+
+	for _, rec := range after {
+		if !seen[acc.id(rec)] {
+			events = append(events, event(ChangeCreated, zero, rec))
+		}
+	}
+	return events
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// nextChange читает следующее событие подписки
+func nextChange(t *testing.T, events <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	default:
+		t.Fatal("событие не доставлено")
+		return ChangeEvent{}
+	}
+}
+
+// События несут состояние записи до и после изменения на всём её пути через корзину
+func TestSubscribeEventStates(t *testing.T) {
+	storage := NewMemoryStorage()
+	events := storage.Subscribe(context.Background(), SubscribeOptions{})
+
+	task := addTask(t, storage, "Исходная")
+	editTask(t, storage, 1, func(task *model.Task) error { return task.SetTitle("Изменённая") })
+	if err := storage.DeleteTask(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.RestoreTask(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DeleteTask(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := storage.PurgeDeleted(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := nextChange(t, events); event.Op != ChangeCreated || event.BeforeTask != nil || event.AfterTask.GetTitle() != "Исходная" {
+		t.Errorf("created: %+v", event)
+	}
+	if event := nextChange(t, events); event.Op != ChangeUpdated || event.BeforeTask.GetTitle() != "Исходная" || event.AfterTask.GetTitle() != "Изменённая" {
+		t.Errorf("updated: %+v", event)
+	}
+	if event := nextChange(t, events); event.Op != ChangeDeleted || !event.AfterTask.IsDeleted() {
+		t.Errorf("deleted: %+v", event)
+	}
+	if event := nextChange(t, events); event.Op != ChangeRestored || event.AfterTask.IsDeleted() {
+		t.Errorf("restored: %+v", event)
+	}
+	nextChange(t, events)
+	if event := nextChange(t, events); event.Op != ChangePurged || event.AfterTask != nil || event.ID != task.GetID() {
+		t.Errorf("purged: %+v", event)
+	}
+}
+
+// Подписчик, не успевающий читать, теряет события сверх буфера и узнаёт об этом из Missed
+// следующего доставленного события; другие подписчики получают всё
+func TestSlowSubscriberMissed(t *testing.T) {
+	storage := NewMemoryStorage()
+	slow := storage.Subscribe(context.Background(), SubscribeOptions{Buffer: 2})
+	fast := storage.Subscribe(context.Background(), SubscribeOptions{Buffer: 10})
+	for i := 0; i < 5; i++ {
+		addTask(t, storage, "Задача")
+	}
+
+	for id := 1; id <= 2; id++ {
+		if event := nextChange(t, slow); event.ID != id || event.Missed != 0 {
+			t.Errorf("из буфера: задача %d, Missed %d, ожидалась задача %d", event.ID, event.Missed, id)
+		}
+	}
+	addTask(t, storage, "Задача")
+	if event := nextChange(t, slow); event.ID != 6 || event.Missed != 3 {
+		t.Errorf("после пропуска: задача %d, Missed %d, ожидались задача 6 и Missed 3", event.ID, event.Missed)
+	}
+	addTask(t, storage, "Задача")
+	if event := nextChange(t, slow); event.Missed != 0 {
+		t.Errorf("Missed не сброшен: %d", event.Missed)
+	}
+
+	for id := 1; id <= 7; id++ {
+		if event := nextChange(t, fast); event.ID != id || event.Missed != 0 {
+			t.Errorf("быстрый подписчик: задача %d, Missed %d, ожидалась задача %d", event.ID, event.Missed, id)
+		}
+	}
+}
+
+// События транзакции рассылаются только после фиксации, а откаченная транзакция событий не даёт
+func TestSubscribeTransactionEvents(t *testing.T) {
+	storage := NewMemoryStorage()
+	events := storage.Subscribe(context.Background(), SubscribeOptions{})
+	errAbort := errors.New("отмена")
+
+	err := storage.Transaction(func(tx *Tx) error {
+		if err := tx.AddModel(newBenchmarkTask(t, 1)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ожидалась ошибка транзакции, получено %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("откаченная транзакция разослала %d событий", len(events))
+	}
+
+	err = storage.Transaction(func(tx *Tx) error {
+		for i := 0; i < 3; i++ {
+			if err := tx.AddModel(newBenchmarkTask(t, i)); err != nil {
+				return err
+			}
+			if len(events) != 0 {
+				return errors.New("событие разослано до фиксации")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Errorf("после фиксации %d событий, ожидалось 3", len(events))
+	}
+}
+
+// Отменённая подписка закрывает канал и больше не получает событий
+func TestSubscribeCancel(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := storage.Subscribe(ctx, SubscribeOptions{})
+
+	addTask(t, storage, "Задача")
+	cancel()
+
+	if event, ok := <-events; !ok || event.ID != 1 {
+		t.Fatalf("событие до отмены не доставлено: %+v, %v", event, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("канал не закрыт после отмены подписки")
+	}
+	addTask(t, storage, "Задача")
+	if storage.hasSubscribers() {
+		t.Error("подписка осталась после отмены")
+	}
+}
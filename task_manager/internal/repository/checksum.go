@@ -0,0 +1,445 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Расширение манифеста контрольных сумм коллекции (tasks.manifest, notes.manifest)
+const manifestSuffix = ".manifest"
+
+// ChecksumError - файл данных не совпадает с контрольной суммой из манифеста
+type ChecksumError struct {
+	File     string
+	Expected string
+	Actual   string // пусто, если файл отсутствует
+}
+
+// Error возвращает описание повреждения
+func (e *ChecksumError) Error() string {
+	return e.File + ": " + e.reason()
+}
+
+// reason возвращает описание повреждения без имени файла (для LoadIssue, где файл указан отдельно)
+func (e *ChecksumError) reason() string {
+	if e.Actual == "" {
+		return "файл указан в манифесте, но отсутствует"
+	}
+	return fmt.Sprintf("контрольная сумма не совпадает с манифестом (ожидалась %.12s…, получена %.12s…), файл повреждён",
+		e.Expected, e.Actual)
+}
+
+// issueReason возвращает причину для отчёта о загрузке
+func issueReason(err error) string {
+	var checksumErr *ChecksumError
+	if errors.As(err, &checksumErr) {
+		return checksumErr.reason()
+	}
+	return err.Error()
+}
+
+// manifest - контрольные суммы файлов одной коллекции во всех форматах
+type manifest struct {
+	SavedAt time.Time                `json:"saved_at"`
+	Files   map[string]manifestEntry `json:"files"` // по имени файла без каталога
+}
+
+// manifestEntry - контрольная сумма одного файла.
+// Манифест переименовывается первым в группе файлов, поэтому после сбоя посреди замены
+// файл может оказаться как новым (SHA256), так и прежним (Previous) - оба варианта целые
+type manifestEntry struct {
+	SHA256   string `json:"sha256"`
+	Size     int    `json:"size"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// checksum возвращает SHA-256 данных в hex
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileSum - контрольная сумма и размер содержимого файла
+type fileSum struct {
+	sha256 string
+	size   int
+}
+
+// hashingWriter считает контрольную сумму и размер всего, что через него записано
+type hashingWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int
+}
+
+// newHashingWriter оборачивает w
+func newHashingWriter(w io.Writer) *hashingWriter {
+	return &hashingWriter{w: w, hash: sha256.New()}
+}
+
+// Write записывает p в w и добавляет его к контрольной сумме
+func (h *hashingWriter) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	h.hash.Write(p[:n])
+	h.size += n
+	return n, err
+}
+
+// sum возвращает контрольную сумму записанного
+func (h *hashingWriter) sum() fileSum {
+	return fileSum{sha256: hex.EncodeToString(h.hash.Sum(nil)), size: h.size}
+}
+
+// checksumFile считает SHA-256 файла, читая его по частям
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readManifest читает манифест коллекции base; отсутствующий манифест - не ошибка (nil)
+func readManifest(base string) (*manifest, error) {
+	data, err := os.ReadFile(base + manifestSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("повреждён манифест %s: %w", base+manifestSuffix, err)
+	}
+	return &m, nil
+}
+
+// verify проверяет файл по манифесту. Файлы, которых нет в манифесте (или манифеста нет вовсе),
+// считаются целыми: они записаны до появления манифестов
+func (m *manifest) verify(path string) error {
+	if m == nil {
+		return nil
+	}
+	entry, ok := m.Files[filepath.Base(path)]
+	if !ok {
+		return nil
+	}
+
+	actual, err := checksumFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ChecksumError{File: path, Expected: entry.SHA256}
+		}
+		return err
+	}
+
+	if actual != entry.SHA256 && actual != entry.Previous {
+		return &ChecksumError{File: path, Expected: entry.SHA256, Actual: actual}
+	}
+	return nil
+}
+
+// manifestFile возвращает запись манифеста коллекции base с новыми контрольными суммами файлов sums
+// (по полным путям). Записи остальных файлов переносятся из текущего манифеста.
+// Манифест нужно передавать в writeFilesAtomic первым
+func manifestFile(base string, sums map[string]fileSum) atomicFile {
+	return atomicFile{path: base + manifestSuffix, write: func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(newManifest(base, sums))
+	}}
+}
+
+// newManifest собирает манифест коллекции base из текущего и новых контрольных сумм sums
+func newManifest(base string, sums map[string]fileSum) manifest {
+	m := manifest{SavedAt: time.Now(), Files: make(map[string]manifestEntry)}
+	if old, err := readManifest(base); err == nil && old != nil {
+		for name, entry := range old.Files {
+			m.Files[name] = entry
+		}
+	}
+
+	for path, sum := range sums {
+		name := filepath.Base(path)
+		m.Files[name] = manifestEntry{
+			SHA256:   sum.sha256,
+			Size:     sum.size,
+			Previous: m.Files[name].SHA256,
+		}
+	}
+	return m
+}
+
+// writeCollectionAtomic записывает файлы коллекции base вместе с обновлённым манифестом одной атомарной группой
+func writeCollectionAtomic(base string, files ...atomicFile) error {
+	return writeFilesAtomic(collectionGroup(base, files...)...)
+}
+
+// collectionGroup готовит файлы коллекции base к записи. Контрольные суммы считаются по ходу записи
+// файлов, поэтому содержимое не собирается в памяти целиком; манифест пишется после файлов данных,
+// но стоит первым в группе и переименовывается раньше них
+func collectionGroup(base string, files ...atomicFile) []atomicFile {
+	sums := make(map[string]fileSum, len(files))
+	group := make([]atomicFile, 0, len(files)+1)
+	group = append(group, atomicFile{}) // место для манифеста
+
+	for _, f := range files {
+		group = append(group, atomicFile{path: f.path, write: func(w io.Writer) error {
+			hw := newHashingWriter(w)
+			if err := f.write(hw); err != nil {
+				return err
+			}
+			sums[f.path] = hw.sum()
+			return nil
+		}})
+	}
+
+	group[0] = manifestFile(base, sums)
+	group[0].last = true
+	return group
+}
+
+// loadVerified загружает коллекцию base, выбирая источник по манифесту: сначала целый файл основного
+// формата, затем целые зеркала, затем файлы, изменённые в обход хранилища, затем последний целый снимок.
+// Файл, который не совпадает с манифестом, но читается без пропущенных записей, считается правкой
+// (например, CSV, открытый в редакторе таблиц): он попадает в report как LoadDiverged и не
+// перезаписывается до сверки через ReconcileFiles. Повреждённые файлы попадают в report как LoadFailed,
+// а восстановление - как LoadRecovered, после чего Open делает снимок и перезаписывает файлы.
+// Отсутствующий файл формата пропускается, если есть файлы других форматов: так данные
+// подхватываются после смены основного формата
+func loadVerified[T any](b *FileBackend, base func(*FileBackend) string, load func(*FileBackend, Codec, *LoadReport) ([]T, error), report *LoadReport) ([]T, error) {
+	m, err := readManifest(base(b))
+	if err != nil {
+		report.add(base(b)+manifestSuffix, 0, LoadFailed, fmt.Sprintf("%v; контрольные суммы не проверяются", err))
+	}
+
+	paths := b.collectionFiles(base(b))
+	errs := make([]error, len(b.codecs))
+	missing := make([]bool, len(b.codecs))
+	edited := make([]bool, len(b.codecs))
+	for i, path := range paths {
+		errs[i] = m.verify(path)
+		switch {
+		case errs[i] == nil:
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				missing[i] = true
+			}
+		case isEdited(b, b.codecs[i], errs[i], load):
+			edited[i] = true
+		default:
+			report.add(path, 0, LoadFailed, issueReason(errs[i]))
+		}
+	}
+	if !slices.Contains(missing, false) {
+		// Файлов настроенных форматов нет: данные могут лежать в формате, который был основным раньше
+		return loadOtherFormat(b, base, m, load, report)
+	}
+
+	// loaded отмечает в report судьбу остальных файлов, когда данные загружены из файла i:
+	// повреждённые восстанавливаются из него, а изменённые в обход хранилища остаются как есть
+	loaded := func(i int) {
+		name := b.codecs[i].Name()
+		for j, path := range paths {
+			switch {
+			case j == i:
+			case edited[j]:
+				b.hold(path)
+				report.add(path, 0, LoadDiverged, fmt.Sprintf("файл изменён в обход хранилища и расходится с %s; "+
+					"он не перезаписывается, пока расхождение не разрешено через ReconcileFiles", name))
+			case errs[j] != nil:
+				report.add(path, 0, LoadRecovered, fmt.Sprintf("файл будет восстановлен из %s", name))
+			case missing[j] && j < i:
+				report.add(path, 0, LoadRecovered, fmt.Sprintf("файл отсутствует и будет создан из %s", name))
+			}
+		}
+	}
+
+	// Загружаем из первого целого формата; остальные восстановятся из него при перезаписи
+	for i, c := range b.codecs {
+		if errs[i] != nil || missing[i] {
+			continue
+		}
+		items, err := load(b, c, report)
+		if err == nil {
+			loaded(i)
+			return items, nil
+		}
+		errs[i] = err
+		if i < len(b.codecs)-1 {
+			report.add(paths[i], 0, LoadFailed, fmt.Sprintf("%v; данные загружаются из следующего формата", err))
+		}
+	}
+
+	// Целых файлов нет - правка в обход хранилища новее любого снимка, поэтому данные берутся из неё
+	for i, c := range b.codecs {
+		if !edited[i] {
+			continue
+		}
+		items, err := load(b, c, report)
+		if err != nil {
+			continue
+		}
+		report.add(paths[i], 0, LoadDiverged, "файл изменён в обход хранилища; других целых копий нет, данные загружены из него")
+		edited[i] = false
+		loaded(i)
+		return items, nil
+	}
+
+	// Все форматы повреждены - ищем последний целый снимок
+	if items, id, ok := loadFromSnapshot(b, base, load); ok {
+		report.add(base(b), 0, LoadRecovered, fmt.Sprintf("файлы всех форматов повреждены, данные восстановлены из снимка %s", id))
+		return items, nil
+	}
+
+	// Целых копий нет: читаем повреждённые файлы как есть, чтобы сохранить хотя бы уцелевшие записи
+	var lastErr error
+	for i, c := range b.codecs {
+		var checksumErr *ChecksumError
+		if errors.As(errs[i], &checksumErr) && checksumErr.Actual != "" {
+			if items, err := load(b, c, report); err == nil {
+				return items, nil
+			}
+		}
+		if errs[i] != nil {
+			lastErr = errs[i]
+		}
+	}
+	return nil, lastErr
+}
+
+// isEdited сообщает, похож ли файл формата c, не совпавший с манифестом (verifyErr), на правку
+// в обход хранилища: файл есть и читается без ошибок и пропущенных записей. Обрыв при сбое
+// или порча на диске обычно ломают разбор, и такой файл восстанавливается из целой копии
+func isEdited[T any](b *FileBackend, c Codec, verifyErr error, load func(*FileBackend, Codec, *LoadReport) ([]T, error)) bool {
+	var checksumErr *ChecksumError
+	if !errors.As(verifyErr, &checksumErr) || checksumErr.Actual == "" {
+		return false
+	}
+
+	probe := &LoadReport{}
+	if _, err := load(b, c, probe); err != nil {
+		return false
+	}
+	for _, issue := range probe.Issues() {
+		if issue.Action == LoadSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// loadOtherFormat загружает коллекцию из целого файла зарегистрированного формата, которого нет
+// среди форматов бэкенда, а файлы настроенных форматов отмечает в report как создаваемые из него.
+// Если таких файлов нет, коллекция пуста - это нормально при первом запуске
+func loadOtherFormat[T any](b *FileBackend, base func(*FileBackend) string, m *manifest, load func(*FileBackend, Codec, *LoadReport) ([]T, error), report *LoadReport) ([]T, error) {
+	// Сначала форматы по умолчанию: скорее всего, данные были записаны ими
+	candidates, _ := lookupCodecs(defaultFormats)
+	for _, c := range Codecs() {
+		if !slices.Contains(candidates, c) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	for _, c := range candidates {
+		path := base(b) + c.Extension()
+		if slices.Contains(b.codecs, c) {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil || m.verify(path) != nil {
+			continue
+		}
+
+		items, err := load(b, c, report)
+		if err != nil {
+			continue
+		}
+		for _, target := range b.collectionFiles(base(b)) {
+			report.add(target, 0, LoadRecovered, fmt.Sprintf("файл отсутствует и будет создан из %s", c.Name()))
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+// loadFromSnapshot загружает коллекцию из самого нового снимка, файлы которого проходят проверку
+func loadFromSnapshot[T any](b *FileBackend, base func(*FileBackend) string, load func(*FileBackend, Codec, *LoadReport) ([]T, error)) ([]T, string, bool) {
+	if b.snapshotDir == "" {
+		return nil, "", false
+	}
+
+	snapshots, err := ListSnapshots(b.snapshotDir)
+	if err != nil {
+		return nil, "", false
+	}
+
+	for _, snap := range snapshots {
+		sb := &FileBackend{
+			tasksFile: filepath.Join(snap.Dir, filepath.Base(b.tasksFile)),
+			notesFile: filepath.Join(snap.Dir, filepath.Base(b.notesFile)),
+			cipher:    b.cipher,
+			codecs:    b.codecs,
+		}
+
+		m, err := readManifest(base(sb))
+		if err != nil {
+			continue
+		}
+		for _, c := range sb.codecs {
+			path := base(sb) + c.Extension()
+			if _, err := os.Stat(path); err != nil || m.verify(path) != nil {
+				continue
+			}
+			if items, err := load(sb, c, nil); err == nil {
+				return items, snap.ID, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// VerifyFiles проверяет файлы задач и заметок по манифестам и возвращает
+// отчёт с повреждёнными файлами; пустой отчёт означает, что все файлы целы
+func VerifyFiles(tasksFile, notesFile string) *LoadReport {
+	report := &LoadReport{}
+	for _, base := range []string{tasksFile, notesFile} {
+		m, err := readManifest(base)
+		if err != nil {
+			report.add(base+manifestSuffix, 0, LoadFailed, err.Error())
+			continue
+		}
+		if m == nil {
+			continue
+		}
+
+		// Проверяются все файлы из манифеста, в каком бы формате они ни были записаны
+		names := make([]string, 0, len(m.Files))
+		for name := range m.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(filepath.Dir(base), name)
+			if err := m.verify(path); err != nil {
+				report.add(path, 0, LoadFailed, issueReason(err))
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempDataFiles возвращает пути файлов задач и заметок во временном каталоге теста
+func tempDataFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	return filepath.Join(dir, "tasks"), filepath.Join(dir, "notes")
+}
+
+// openStorage открывает файловое хранилище и закрывает его по окончании теста
+func openStorage(t *testing.T, tasksFile, notesFile string, opts ...Option) (*Storage, *LoadReport) {
+	t.Helper()
+	storage, report, err := Open(tasksFile, notesFile, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage, report
+}
+
+// closeStorage закрывает хранилище, проверяя финальное сохранение
+func closeStorage(t *testing.T, s *Storage) {
+	t.Helper()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editFile заменяет в файле old на new, как это сделал бы человек в редакторе
+func editFile(t *testing.T, path, old, new string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(old)) {
+		t.Fatalf("в %s нет %q", path, old)
+	}
+	if err := os.WriteFile(path, bytes.ReplaceAll(data, []byte(old), []byte(new)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// issuesWith возвращает проблемы отчёта с действием action
+func issuesWith(report *LoadReport, action LoadAction) []LoadIssue {
+	var issues []LoadIssue
+	for _, issue := range report.Issues() {
+		if issue.Action == action {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Зеркало, исправленное вручную и по-прежнему читаемое, - расхождение, а не повреждение:
+// Open не перезаписывает его, а ReconcileFiles видит правку
+func TestHandEditedMirrorIsKept(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "original")
+	closeStorage(t, storage)
+
+	editFile(t, tasksFile+".csv", "original", "edited")
+
+	storage, report := openStorage(t, tasksFile, notesFile)
+	if diverged := issuesWith(report, LoadDiverged); len(diverged) != 1 || diverged[0].File != tasksFile+".csv" {
+		t.Fatalf("ожидалось расхождение tasks.csv, отчёт:\n%s", report)
+	}
+	if recovered := issuesWith(report, LoadRecovered); len(recovered) != 0 {
+		t.Fatalf("правка принята за повреждение:\n%s", report)
+	}
+	if task, _ := storage.GetTask(1); task.GetTitle() != "original" {
+		t.Errorf("загружено %q из изменённого зеркала, а не из JSON", task.GetTitle())
+	}
+
+	// Сохранения не трогают изменённое зеркало
+	if err := storage.SaveAll(); err != nil {
+		t.Fatal(err)
+	}
+	closeStorage(t, storage)
+	if data, _ := os.ReadFile(tasksFile + ".csv"); !bytes.Contains(data, []byte("edited")) {
+		t.Fatal("правка в tasks.csv перезаписана")
+	}
+
+	result, err := ReconcileFiles(tasksFile, notesFile, PreferCSV, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diffs) != 1 || result.Diffs[0].Kind != DiffChanged || result.Diffs[0].Resolution != FormatCSV {
+		t.Fatalf("сверка нашла %v, ожидалось изменение задачи 1 в пользу csv", result.Diffs)
+	}
+
+	storage, report = openStorage(t, tasksFile, notesFile)
+	if report.HasIssues() {
+		t.Errorf("после сверки остались проблемы:\n%s", report)
+	}
+	if task, _ := storage.GetTask(1); task.GetTitle() != "edited" {
+		t.Errorf("после сверки задача %q, ожидалась правка из CSV", task.GetTitle())
+	}
+}
+
+// Если других целых копий нет, данные берутся из изменённого файла, и он остаётся основным
+func TestHandEditedOnlyFormatIsLoaded(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithFormats(FormatJSON))
+	addTask(t, storage, "original")
+	closeStorage(t, storage)
+
+	editFile(t, tasksFile+".json", "original", "edited")
+
+	storage, report := openStorage(t, tasksFile, notesFile, WithFormats(FormatJSON))
+	if diverged := issuesWith(report, LoadDiverged); len(diverged) != 1 {
+		t.Fatalf("ожидалось одно расхождение, отчёт:\n%s", report)
+	}
+	if task, _ := storage.GetTask(1); task.GetTitle() != "edited" {
+		t.Errorf("загружено %q, ожидалась правка", task.GetTitle())
+	}
+
+	// Файл - единственный источник, поэтому сохранение обновляет и его, и манифест
+	addTask(t, storage, "second")
+	closeStorage(t, storage)
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		t.Errorf("после сохранения файлы не совпадают с манифестом:\n%s", report)
+	}
+}
+
+// Нечитаемое зеркало восстанавливается из основного формата, но сначала попадает в снимок
+func TestCorruptedMirrorIsSnapshottedBeforeRecovery(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "original")
+	closeStorage(t, storage)
+
+	corrupted := []byte("ID,Title\n1,\"оборванная строка\n")
+	if err := os.WriteFile(tasksFile+".csv", corrupted, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, report := openStorage(t, tasksFile, notesFile)
+	if recovered := issuesWith(report, LoadRecovered); len(recovered) != 1 || recovered[0].File != tasksFile+".csv" {
+		t.Fatalf("ожидалось восстановление tasks.csv, отчёт:\n%s", report)
+	}
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		t.Errorf("восстановленный файл не совпадает с манифестом:\n%s", report)
+	}
+
+	snapshots, err := ListSnapshots(defaultSnapshotDir(tasksFile))
+	if err != nil || len(snapshots) != 1 || snapshots[0].Reason != "pre-recovery" {
+		t.Fatalf("ожидался снимок pre-recovery, найдено %v: %v", snapshots, err)
+	}
+	saved, err := os.ReadFile(filepath.Join(snapshots[0].Dir, "tasks.csv"))
+	if err != nil || !bytes.Equal(saved, corrupted) {
+		t.Errorf("в снимке нет исходного повреждённого файла: %v", err)
+	}
+}
+
+// Если повреждены файлы всех форматов, данные берутся из последнего целого снимка
+func TestAllFormatsCorruptedRecoverFromSnapshot(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "original")
+	if _, err := storage.Snapshot("manual"); err != nil {
+		t.Fatal(err)
+	}
+	closeStorage(t, storage)
+
+	// Обрыв записи посреди файла: обе копии не читаются
+	for _, path := range []string{tasksFile + ".json", tasksFile + ".csv"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	issues := VerifyFiles(tasksFile, notesFile).Issues()
+	if len(issues) != 2 {
+		t.Fatalf("VerifyFiles нашёл %d проблем, ожидалось 2", len(issues))
+	}
+	for _, issue := range issues {
+		if !strings.Contains(issue.Reason, "контрольная сумма не совпадает") {
+			t.Errorf("проблема не связана с контрольной суммой: %s", issue)
+		}
+	}
+
+	storage, report := openStorage(t, tasksFile, notesFile)
+	recovered := issuesWith(report, LoadRecovered)
+	if len(recovered) != 1 || !strings.Contains(recovered[0].Reason, "снимка") {
+		t.Fatalf("ожидалось восстановление из снимка, отчёт:\n%s", report)
+	}
+	if task, err := storage.GetTask(1); err != nil || task.GetTitle() != "original" {
+		t.Errorf("задача из снимка: %v", err)
+	}
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		t.Errorf("файлы не перезаписаны после восстановления:\n%s", report)
+	}
+}
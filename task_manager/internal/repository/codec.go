@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"task-manager/internal/model"
+)
+
+// Имена встроенных форматов файлов
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatGob    = "gob"
+	FormatXML    = "xml"
+	FormatNDJSON = "ndjson"
+)
+
+// defaultFormats - форматы файлового хранилища по умолчанию: основной JSON и зеркало в CSV
+var defaultFormats = []string{FormatJSON, FormatCSV}
+
+// Codec - формат файлов коллекций: кодирует задачи и заметки и разбирает их обратно.
+// Decode* читают уже расшифрованное содержимое файла path из r и должны разбирать записи по одной,
+// не загружая файл в память целиком; Encode* так же пишут записи по одной. Пропущенные и исправленные
+// записи добавляются в report (см. LoadReport.Add), а ошибка означает, что файл не читается целиком
+type Codec interface {
+	Name() string      // имя формата для WithFormats
+	Extension() string // расширение файлов с точкой, например ".json"
+
+	EncodeTasks(w io.Writer, tasks []*model.Task) error
+	DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error)
+	EncodeNotes(w io.Writer, notes []*model.Note) error
+	DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error)
+}
+
+// codecs - реестр форматов по имени, защищён codecsMu
+var (
+	codecs   = make(map[string]Codec)
+	codecsMu sync.RWMutex
+)
+
+// RegisterCodec регистрирует формат; имена и расширения форматов не должны повторяться.
+// Регистрировать форматы можно и во время работы, параллельно с открытием хранилищ
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if _, exists := codecs[c.Name()]; exists {
+		panic(fmt.Sprintf("codec %q is already registered", c.Name()))
+	}
+	for _, other := range codecs {
+		if other.Extension() == c.Extension() {
+			panic(fmt.Sprintf("codec %q uses extension %q of codec %q", c.Name(), c.Extension(), other.Name()))
+		}
+	}
+	codecs[c.Name()] = c
+}
+
+// Codecs возвращает зарегистрированные форматы по имени
+func Codecs() []Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	result := make([]Codec, 0, len(codecs))
+	for _, c := range codecs {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result
+}
+
+func init() {
+	RegisterCodec(jsonCodec{})
+	RegisterCodec(csvCodec{})
+	RegisterCodec(gobCodec{})
+	RegisterCodec(xmlCodec{})
+	RegisterCodec(ndjsonCodec{})
+}
+
+// lookupCodecs возвращает форматы по именам: первый - основной, остальные - зеркала
+func lookupCodecs(names []string) ([]Codec, error) {
+	if len(names) == 0 {
+		return nil, model.NewValidationError("at least one storage format is required")
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	result := make([]Codec, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		c, ok := codecs[name]
+		if !ok {
+			return nil, model.NewValidationError(fmt.Sprintf("unknown storage format %q", name))
+		}
+		if seen[name] {
+			return nil, model.NewValidationError(fmt.Sprintf("storage format %q is listed twice", name))
+		}
+		seen[name] = true
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// codecExtensions возвращает расширения всех зарегистрированных форматов: файлы любого
+// из них могут лежать в каталоге данных, даже если хранилище сейчас их не пишет
+func codecExtensions() []string {
+	var result []string
+	for _, c := range Codecs() {
+		result = append(result, c.Extension())
+	}
+	return result
+}
+
+// duplicateIDs отмечает в отчёте повторяющиеся ID записей одного файла;
+// такие записи получают новый ID при загрузке (см. restoreSequences)
+type duplicateIDs map[int]bool
+
+// check проверяет ID записи row файла path
+func (d duplicateIDs) check(report *LoadReport, path string, row, id int) {
+	if id > 0 && d[id] {
+		report.add(path, row, LoadRepaired, fmt.Sprintf("повторяющийся ID %d, будет выдан новый", id))
+	}
+	d[id] = true
+}
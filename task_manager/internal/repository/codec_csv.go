@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"task-manager/internal/model"
+	"time"
+)
+
+// csvCodec - CSV с заголовком; колонки версии и пометки удаления необязательны,
+// поэтому читаются и файлы, записанные до их появления
+type csvCodec struct{}
+
+// Name возвращает имя формата
+func (csvCodec) Name() string { return FormatCSV }
+
+// Extension возвращает расширение файлов формата
+func (csvCodec) Extension() string { return ".csv" }
+
+// EncodeTasks записывает задачи в формате CSV
+func (csvCodec) EncodeTasks(w io.Writer, tasks []*model.Task) error {
+	writer := csv.NewWriter(w)
+
+	// Записываем заголовки
+	headers := []string{"ID", "Title", "Description", "Status", "Priority", "CreatedAt", "UpdatedAt", "DueDate", "Version", "DeletedAt"}
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	// Записываем данные задач
+	for _, task := range tasks {
+		if err := writer.Write(taskCSVRecord(task)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// taskCSVRecord возвращает строку CSV для задачи
+func taskCSVRecord(task *model.Task) []string {
+	var dueDateStr string
+	if dueDate := task.GetDueDate(); dueDate != nil {
+		dueDateStr = dueDate.Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.Itoa(task.GetID()),
+		task.GetTitle(),
+		task.GetDescription(),
+		string(task.GetStatus()),
+		string(task.GetPriority()),
+		task.GetCreatedAt().Format(time.RFC3339),
+		task.GetUpdatedAt().Format(time.RFC3339),
+		dueDateStr,
+		strconv.Itoa(task.GetVersion()),
+		formatCSVDeletedAt(task.GetDeletedAt()),
+	}
+}
+
+// DecodeTasks разбирает задачи из CSV
+func (csvCodec) DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error) {
+	reader, err := newCSVReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*model.Task
+	ids := make(duplicateIDs)
+	for row := 2; ; row++ { // строка 1 - заголовок
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 8 {
+			report.add(path, row, LoadSkipped, fmt.Sprintf("ожидалось 8 полей, найдено %d", len(record)))
+			continue
+		}
+
+		title := record[1]
+		description := record[2]
+		status := model.TaskStatus(record[3])
+		priority := model.TaskPriority(record[4])
+
+		var dueDate *time.Time
+		if record[7] != "" {
+			parsedDate, err := time.Parse(time.RFC3339, record[7])
+			if err == nil {
+				dueDate = &parsedDate
+			} else {
+				report.add(path, row, LoadRepaired, fmt.Sprintf("некорректный срок %q, срок удалён", record[7]))
+			}
+		}
+
+		task, err := model.NewTask(title, description, priority, dueDate)
+		if err != nil {
+			report.add(path, row, LoadSkipped, err.Error())
+			continue
+		}
+
+		task.SetID(parseCSVID(record[0], path, row, ids, report))
+
+		if err := task.SetStatus(status); err != nil {
+			report.add(path, row, LoadRepaired, fmt.Sprintf("некорректный статус %q, установлен %q", status, model.StatusTodo))
+		}
+
+		// Устанавливаем даты из файла
+		if createdAt, err := time.Parse(time.RFC3339, record[5]); err == nil {
+			task.SetCreatedAt(createdAt)
+		} else {
+			report.add(path, row, LoadRepaired, fmt.Sprintf("некорректная дата создания %q, установлено текущее время", record[5]))
+		}
+
+		if updatedAt, err := time.Parse(time.RFC3339, record[6]); err == nil {
+			task.SetUpdatedAt(updatedAt)
+		} else {
+			report.add(path, row, LoadRepaired, fmt.Sprintf("некорректная дата изменения %q, установлено текущее время", record[6]))
+		}
+
+		version, err := parseCSVVersion(record, 8)
+		if err != nil {
+			report.add(path, row, LoadRepaired, err.Error())
+		}
+		task.SetVersion(version)
+
+		deletedAt, err := parseCSVDeletedAt(record, 9)
+		if err != nil {
+			report.add(path, row, LoadRepaired, err.Error())
+		}
+		task.SetDeletedAt(deletedAt)
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// EncodeNotes записывает заметки в формате CSV
+func (csvCodec) EncodeNotes(w io.Writer, notes []*model.Note) error {
+	writer := csv.NewWriter(w)
+
+	// Записываем заголовки
+	headers := []string{"ID", "Title", "Content", "Category", "CreatedAt", "UpdatedAt", "Version", "DeletedAt"}
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	// Записываем данные заметок
+	for _, note := range notes {
+		if err := writer.Write(noteCSVRecord(note)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// noteCSVRecord возвращает строку CSV для заметки
+func noteCSVRecord(note *model.Note) []string {
+	return []string{
+		strconv.Itoa(note.GetID()),
+		note.GetTitle(),
+		note.GetContent(),
+		note.GetCategory(),
+		note.GetCreatedAt().Format(time.RFC3339),
+		note.GetUpdatedAt().Format(time.RFC3339),
+		strconv.Itoa(note.GetVersion()),
+		formatCSVDeletedAt(note.GetDeletedAt()),
+	}
+}
+
+// DecodeNotes разбирает заметки из CSV
+func (csvCodec) DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error) {
+	reader, err := newCSVReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*model.Note
+	ids := make(duplicateIDs)
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 6 {
+			report.add(path, row, LoadSkipped, fmt.Sprintf("ожидалось 6 полей, найдено %d", len(record)))
+			continue
+		}
+
+		title := record[1]
+		content := record[2]
+		category := model.NoteCategory(record[3])
+
+		note := model.NewNote(title, content, category)
+		note.SetID(parseCSVID(record[0], path, row, ids, report))
+
+		// Устанавливаем даты из файла
+		if createdAt, err := time.Parse(time.RFC3339, record[4]); err == nil {
+			note.SetCreatedAt(createdAt)
+		} else {
+			report.add(path, row, LoadRepaired, fmt.Sprintf("некорректная дата создания %q, установлено текущее время", record[4]))
+		}
+
+		if updatedAt, err := time.Parse(time.RFC3339, record[5]); err == nil {
+			note.SetUpdatedAt(updatedAt)
+		} else {
+			report.add(path, row, LoadRepaired, fmt.Sprintf("некорректная дата изменения %q, установлено текущее время", record[5]))
+		}
+
+		version, err := parseCSVVersion(record, 6)
+		if err != nil {
+			report.add(path, row, LoadRepaired, err.Error())
+		}
+		note.SetVersion(version)
+
+		deletedAt, err := parseCSVDeletedAt(record, 7)
+		if err != nil {
+			report.add(path, row, LoadRepaired, err.Error())
+		}
+		note.SetDeletedAt(deletedAt)
+
+		notes = append(notes, note)
+	}
+
+	return notes, nil
+}
+
+// newCSVReader возвращает построчное чтение CSV с уже пропущенным заголовком
+func newCSVReader(r io.Reader) (*csv.Reader, error) {
+	reader := csv.NewReader(r)
+	// Записи с неверным числом полей не должны ронять чтение всего файла - они попадают в отчёт
+	reader.FieldsPerRecord = -1
+	// Строки разбираются по одной, поэтому буфер полей можно переиспользовать
+	reader.ReuseRecord = true
+
+	// Пропускаем заголовок
+	if _, err := reader.Read(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// parseCSVID разбирает колонку ID; некорректный ID заменяется нулём, и запись получит новый ID
+func parseCSVID(value, path string, row int, ids duplicateIDs, report *LoadReport) int {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		report.add(path, row, LoadRepaired, fmt.Sprintf("некорректный ID %q, будет выдан новый", value))
+		id = 0
+	}
+	ids.check(report, path, row, id)
+	return id
+}
+
+// parseCSVVersion разбирает необязательную колонку версии CSV: в файлах старого формата её нет
+func parseCSVVersion(record []string, column int) (int, error) {
+	if len(record) <= column || record[column] == "" {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(record[column])
+	if err != nil || version < 1 {
+		return 1, fmt.Errorf("некорректная версия %q, установлена 1", record[column])
+	}
+	return version, nil
+}
+
+// formatCSVDeletedAt возвращает значение колонки пометки удаления; пусто - запись не удалена
+func formatCSVDeletedAt(deletedAt *time.Time) string {
+	if deletedAt == nil {
+		return ""
+	}
+	return deletedAt.Format(time.RFC3339)
+}
+
+// parseCSVDeletedAt разбирает необязательную колонку пометки удаления.
+// Некорректное значение заменяется текущим временем: запись остаётся в корзине, а не возвращается в списки
+func parseCSVDeletedAt(record []string, column int) (*time.Time, error) {
+	if len(record) <= column || record[column] == "" {
+		return nil, nil
+	}
+	deletedAt, err := time.Parse(time.RFC3339, record[column])
+	if err != nil {
+		now := time.Now()
+		return &now, fmt.Errorf("некорректная дата удаления %q, установлено текущее время", record[column])
+	}
+	return &deletedAt, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"task-manager/internal/model"
+	"time"
+)
+
+// gobCodec - компактный двоичный формат encoding/gob. Записи те же, что в JSON,
+// а версия формата пишется в заголовок файла: файлы новее программы не читаются.
+// За заголовком записи идут по одной, поэтому файл читается и пишется потоком
+type gobCodec struct{}
+
+// gobHeader - заголовок gob файла
+type gobHeader struct {
+	Version int
+	Kind    string
+	SavedAt time.Time
+	// PerRecord - записи идут по одной до конца файла; в файлах без него все записи
+	// закодированы одним срезом
+	PerRecord bool
+}
+
+// Name возвращает имя формата
+func (gobCodec) Name() string { return FormatGob }
+
+// Extension возвращает расширение файлов формата
+func (gobCodec) Extension() string { return ".gob" }
+
+// EncodeTasks записывает заголовок и задачи
+func (gobCodec) EncodeTasks(w io.Writer, tasks []*model.Task) error {
+	return encodeGob(w, kindTasks, tasks, newTaskRecord)
+}
+
+// DecodeTasks разбирает задачи
+func (gobCodec) DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error) {
+	var tasks []*model.Task
+	ids := make(duplicateIDs)
+	err := decodeGob(r, kindTasks, func(row int, record taskRecord) {
+		if task := recordTask(record, path, row, ids, report); task != nil {
+			tasks = append(tasks, task)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// EncodeNotes записывает заголовок и заметки
+func (gobCodec) EncodeNotes(w io.Writer, notes []*model.Note) error {
+	return encodeGob(w, kindNotes, notes, newNoteRecord)
+}
+
+// DecodeNotes разбирает заметки
+func (gobCodec) DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error) {
+	var notes []*model.Note
+	ids := make(duplicateIDs)
+	err := decodeGob(r, kindNotes, func(row int, record noteRecord) {
+		notes = append(notes, recordNote(record, path, row, ids, report))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// encodeGob записывает заголовок текущей версии и записи коллекции kind по одной
+func encodeGob[T, R any](w io.Writer, kind string, items []T, record func(T) R) error {
+	encoder := gob.NewEncoder(w)
+	header := gobHeader{Version: CurrentFormatVersion, Kind: kind, SavedAt: time.Now(), PerRecord: true}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := encoder.Encode(record(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeGob проверяет заголовок и передаёт записи коллекции kind в each по одной, с номера 1
+func decodeGob[R any](r io.Reader, kind string, each func(row int, record R)) error {
+	decoder := gob.NewDecoder(r)
+
+	var header gobHeader
+	if err := decoder.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version > CurrentFormatVersion {
+		return fmt.Errorf("версия формата %d новее поддерживаемой %d", header.Version, CurrentFormatVersion)
+	}
+	if header.Kind != kind {
+		return fmt.Errorf("файл содержит %q, ожидалось %q", header.Kind, kind)
+	}
+
+	if !header.PerRecord {
+		var records []R
+		if err := decoder.Decode(&records); err != nil {
+			return err
+		}
+		for i, record := range records {
+			each(i+1, record)
+		}
+		return nil
+	}
+
+	for row := 1; ; row++ {
+		var record R
+		if err := decoder.Decode(&record); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		each(row, record)
+	}
+}
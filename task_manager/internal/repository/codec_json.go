@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"task-manager/internal/model"
+	"time"
+)
+
+// taskRecord - представление задачи для JSON, gob и XML файлов и журнала
+type taskRecord struct {
+	ID          int        `json:"id" xml:"id"`
+	Title       string     `json:"title" xml:"title"`
+	Description string     `json:"description" xml:"description"`
+	Status      string     `json:"status" xml:"status"`
+	Priority    string     `json:"priority" xml:"priority"`
+	CreatedAt   time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" xml:"updated_at"`
+	DueDate     *time.Time `json:"due_date,omitempty" xml:"due_date,omitempty"`
+	Version     int        `json:"version" xml:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+}
+
+// newTaskRecord переводит задачу в представление для сериализации
+func newTaskRecord(task *model.Task) taskRecord {
+	return taskRecord{
+		ID:          task.GetID(),
+		Title:       task.GetTitle(),
+		Description: task.GetDescription(),
+		Status:      string(task.GetStatus()),
+		Priority:    string(task.GetPriority()),
+		CreatedAt:   task.GetCreatedAt(),
+		UpdatedAt:   task.GetUpdatedAt(),
+		DueDate:     task.GetDueDate(),
+		Version:     task.GetVersion(),
+		DeletedAt:   task.GetDeletedAt(),
+	}
+}
+
+// toTask восстанавливает задачу из представления для сериализации.
+// Вместе с задачей возвращается список исправленных полей
+func (tr taskRecord) toTask() (*model.Task, []string, error) {
+	task, err := model.NewTask(tr.Title, tr.Description, model.TaskPriority(tr.Priority), tr.DueDate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var repairs []string
+	if tr.ID <= 0 {
+		repairs = append(repairs, fmt.Sprintf("некорректный ID %d, будет выдан новый", tr.ID))
+	}
+	task.SetID(tr.ID)
+	if err := task.SetStatus(model.TaskStatus(tr.Status)); err != nil {
+		repairs = append(repairs, fmt.Sprintf("некорректный статус %q, установлен %q", tr.Status, model.StatusTodo))
+	}
+	task.SetCreatedAt(tr.CreatedAt)
+	task.SetUpdatedAt(tr.UpdatedAt)
+	task.SetVersion(recordVersion(tr.Version))
+	task.SetDeletedAt(tr.DeletedAt)
+	return task, repairs, nil
+}
+
+// noteRecord - представление заметки для JSON, gob и XML файлов и журнала
+type noteRecord struct {
+	ID        int        `json:"id" xml:"id"`
+	Title     string     `json:"title" xml:"title"`
+	Content   string     `json:"content" xml:"content"`
+	Category  string     `json:"category" xml:"category"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" xml:"updated_at"`
+	Version   int        `json:"version" xml:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+}
+
+// newNoteRecord переводит заметку в представление для сериализации
+func newNoteRecord(note *model.Note) noteRecord {
+	return noteRecord{
+		ID:        note.GetID(),
+		Title:     note.GetTitle(),
+		Content:   note.GetContent(),
+		Category:  note.GetCategory(),
+		CreatedAt: note.GetCreatedAt(),
+		UpdatedAt: note.GetUpdatedAt(),
+		Version:   note.GetVersion(),
+		DeletedAt: note.GetDeletedAt(),
+	}
+}
+
+// toNote восстанавливает заметку из представления для сериализации.
+// Вместе с заметкой возвращается список исправленных полей
+func (nr noteRecord) toNote() (*model.Note, []string) {
+	note := model.NewNote(nr.Title, nr.Content, model.NoteCategory(nr.Category))
+
+	var repairs []string
+	if nr.ID <= 0 {
+		repairs = append(repairs, fmt.Sprintf("некорректный ID %d, будет выдан новый", nr.ID))
+	}
+	note.SetID(nr.ID)
+	note.SetCreatedAt(nr.CreatedAt)
+	note.SetUpdatedAt(nr.UpdatedAt)
+	note.SetVersion(recordVersion(nr.Version))
+	note.SetDeletedAt(nr.DeletedAt)
+	return note, repairs
+}
+
+// recordVersion возвращает версию записи; записи без версии (журналы, записанные до появления версий)
+// считаются первой версией
+func recordVersion(version int) int {
+	return max(version, 1)
+}
+
+// recordTask восстанавливает задачу из записи row файла path; nil - запись пропущена
+func recordTask(record taskRecord, path string, row int, ids duplicateIDs, report *LoadReport) *model.Task {
+	task, repairs, err := record.toTask()
+	if err != nil {
+		report.add(path, row, LoadSkipped, err.Error())
+		return nil
+	}
+	for _, repair := range repairs {
+		report.add(path, row, LoadRepaired, repair)
+	}
+	ids.check(report, path, row, task.GetID())
+	return task
+}
+
+// recordNote восстанавливает заметку из записи row файла path
+func recordNote(record noteRecord, path string, row int, ids duplicateIDs, report *LoadReport) *model.Note {
+	note, repairs := record.toNote()
+	for _, repair := range repairs {
+		report.add(path, row, LoadRepaired, repair)
+	}
+	ids.check(report, path, row, note.GetID())
+	return note
+}
+
+// jsonCodec - JSON в версионированном конверте; файлы старых версий приводятся
+// к текущему формату цепочкой миграций (см. migrate.go)
+type jsonCodec struct{}
+
+// Name возвращает имя формата
+func (jsonCodec) Name() string { return FormatJSON }
+
+// Extension возвращает расширение файлов формата
+func (jsonCodec) Extension() string { return ".json" }
+
+// EncodeTasks записывает задачи в версионированном конверте
+func (jsonCodec) EncodeTasks(w io.Writer, tasks []*model.Task) error {
+	return encodeJSONItems(w, kindTasks, tasks, newTaskRecord)
+}
+
+// DecodeTasks разбирает задачи. Записи разбираются по одной,
+// чтобы одна испорченная запись не отбрасывала весь файл
+func (jsonCodec) DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error) {
+	var tasks []*model.Task
+	ids := make(duplicateIDs)
+	err := decodeJSONItems(r, kindTasks, func(row int, raw json.RawMessage) {
+		var record taskRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			report.add(path, row, LoadSkipped, err.Error())
+			return
+		}
+		if task := recordTask(record, path, row, ids, report); task != nil {
+			tasks = append(tasks, task)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// EncodeNotes записывает заметки в версионированном конверте
+func (jsonCodec) EncodeNotes(w io.Writer, notes []*model.Note) error {
+	return encodeJSONItems(w, kindNotes, notes, newNoteRecord)
+}
+
+// DecodeNotes разбирает заметки по одной записи (см. DecodeTasks)
+func (jsonCodec) DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error) {
+	var notes []*model.Note
+	ids := make(duplicateIDs)
+	err := decodeJSONItems(r, kindNotes, func(row int, raw json.RawMessage) {
+		var record noteRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			report.add(path, row, LoadSkipped, err.Error())
+			return
+		}
+		notes = append(notes, recordNote(record, path, row, ids, report))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// encodeJSONItems записывает конверт текущей версии, переводя элементы items в записи по одной.
+// Вывод совпадает с encodeEnvelope, но весь массив записей не собирается в памяти
+func encodeJSONItems[T, R any](w io.Writer, kind string, items []T, record func(T) R) error {
+	kindJSON, err := json.Marshal(kind)
+	if err != nil {
+		return err
+	}
+	savedAt, err := json.Marshal(time.Now())
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "{\n  \"version\": %d,\n  \"kind\": %s,\n  \"saved_at\": %s,\n  \"items\": [",
+		CurrentFormatVersion, kindJSON, savedAt); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		data, err := json.MarshalIndent(record(item), "    ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := ",\n    "
+		if i == 0 {
+			separator = "\n    "
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	closing := "]\n}\n"
+	if len(items) > 0 {
+		closing = "\n  ]\n}\n"
+	}
+	_, err = io.WriteString(w, closing)
+	return err
+}
+
+// decodeJSONItems читает конверт и передаёт записи в each по одной, с номера 1.
+// Конверт текущей версии разбирается потоком; файлы старых версий читаются целиком
+// и проходят цепочку миграций (см. decodeEnvelope) - после первого сохранения они уже в текущем формате
+func decodeJSONItems(r io.Reader, kind string, each func(row int, raw json.RawMessage)) error {
+	head := &headCapture{}
+	decoder := json.NewDecoder(io.TeeReader(r, head))
+
+	// Документ старой версии (или непонятный) целиком отдаём миграциям
+	legacy := func() error {
+		rest, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		items, err := decodeEnvelope(bytes.NewReader(append(head.buf.Bytes(), rest...)), kind)
+		if err != nil {
+			return err
+		}
+		var raws []json.RawMessage
+		if err := json.Unmarshal(items, &raws); err != nil {
+			return err
+		}
+		for i, raw := range raws {
+			each(i+1, raw)
+		}
+		return nil
+	}
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return legacy()
+	}
+
+	version := 0
+	fileKind := ""
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case "version":
+			if err := decoder.Decode(&version); err != nil {
+				return err
+			}
+			if version > CurrentFormatVersion {
+				return fmt.Errorf("версия формата %d новее поддерживаемой %d", version, CurrentFormatVersion)
+			}
+		case "kind":
+			if err := decoder.Decode(&fileKind); err != nil {
+				return err
+			}
+		case "items":
+			if version != CurrentFormatVersion {
+				return legacy()
+			}
+			if fileKind != "" && fileKind != kind {
+				return fmt.Errorf("файл содержит %q, ожидалось %q", fileKind, kind)
+			}
+			head.stop()
+			if err := decodeJSONArray(decoder, each); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	if version != CurrentFormatVersion {
+		return legacy()
+	}
+	if fileKind != kind {
+		return fmt.Errorf("файл содержит %q, ожидалось %q", fileKind, kind)
+	}
+	return nil
+}
+
+// decodeJSONArray читает массив записей по одному элементу; null - пустой массив
+func decodeJSONArray(decoder *json.Decoder, each func(row int, raw json.RawMessage)) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("ожидался массив записей, найдено %v", token)
+	}
+
+	for row := 1; decoder.More(); row++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+		each(row, raw)
+	}
+	_, err = decoder.Token() // закрывающая скобка
+	return err
+}
+
+// headCapture запоминает начало документа, пока не станет ясно, что файл текущей версии
+// и его можно разбирать потоком; после stop данные больше не копятся
+type headCapture struct {
+	buf     bytes.Buffer
+	stopped bool
+}
+
+// Write запоминает прочитанные данные
+func (h *headCapture) Write(p []byte) (int, error) {
+	if !h.stopped {
+		h.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// stop прекращает запоминание и освобождает буфер
+func (h *headCapture) stop() {
+	h.stopped = true
+	h.buf = bytes.Buffer{}
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"task-manager/internal/model"
+	"time"
+)
+
+// ndjsonCodec - JSON по записи в строке (NDJSON). Первая строка - заголовок с версией формата
+// и видом коллекции, остальные - записи в том же виде, что в JSON файлах. Файл читается построчно,
+// повреждённая строка пропускается и не мешает остальным
+type ndjsonCodec struct{}
+
+// ndjsonHeader - первая строка NDJSON файла
+type ndjsonHeader struct {
+	Version int       `json:"version"`
+	Kind    string    `json:"kind"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Name возвращает имя формата
+func (ndjsonCodec) Name() string { return FormatNDJSON }
+
+// Extension возвращает расширение файлов формата
+func (ndjsonCodec) Extension() string { return ".ndjson" }
+
+// EncodeTasks записывает заголовок и задачи по одной в строке
+func (ndjsonCodec) EncodeTasks(w io.Writer, tasks []*model.Task) error {
+	return encodeNDJSON(w, kindTasks, tasks, newTaskRecord)
+}
+
+// DecodeTasks разбирает задачи; номер записи в отчёте - номер строки файла
+func (ndjsonCodec) DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error) {
+	var tasks []*model.Task
+	ids := make(duplicateIDs)
+	err := decodeNDJSON(r, kindTasks, func(line int, data []byte) {
+		var record taskRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			report.add(path, line, LoadSkipped, err.Error())
+			return
+		}
+		if task := recordTask(record, path, line, ids, report); task != nil {
+			tasks = append(tasks, task)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// EncodeNotes записывает заголовок и заметки по одной в строке
+func (ndjsonCodec) EncodeNotes(w io.Writer, notes []*model.Note) error {
+	return encodeNDJSON(w, kindNotes, notes, newNoteRecord)
+}
+
+// DecodeNotes разбирает заметки (см. DecodeTasks)
+func (ndjsonCodec) DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error) {
+	var notes []*model.Note
+	ids := make(duplicateIDs)
+	err := decodeNDJSON(r, kindNotes, func(line int, data []byte) {
+		var record noteRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			report.add(path, line, LoadSkipped, err.Error())
+			return
+		}
+		notes = append(notes, recordNote(record, path, line, ids, report))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// encodeNDJSON записывает заголовок текущей версии и записи коллекции kind по одной в строке
+func encodeNDJSON[T, R any](w io.Writer, kind string, items []T, record func(T) R) error {
+	// json.Encoder завершает каждое значение переводом строки
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(ndjsonHeader{Version: CurrentFormatVersion, Kind: kind, SavedAt: time.Now()}); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := encoder.Encode(record(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeNDJSON проверяет заголовок и передаёт непустые строки записей в each вместе с номерами строк.
+// Строки читаются по одной без ограничения длины
+func decodeNDJSON(r io.Reader, kind string, each func(line int, data []byte)) error {
+	reader := bufio.NewReader(r)
+
+	header := true
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if header {
+				if err := checkNDJSONHeader(data, kind); err != nil {
+					return err
+				}
+				header = false
+			} else {
+				each(line, data)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if header {
+		return fmt.Errorf("в файле нет заголовка с версией формата")
+	}
+	return nil
+}
+
+// checkNDJSONHeader проверяет строку заголовка
+func checkNDJSONHeader(data []byte, kind string) error {
+	var header ndjsonHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("повреждён заголовок: %w", err)
+	}
+	if header.Version < 1 {
+		return fmt.Errorf("в файле не указана версия формата")
+	}
+	if header.Version > CurrentFormatVersion {
+		return fmt.Errorf("версия формата %d новее поддерживаемой %d", header.Version, CurrentFormatVersion)
+	}
+	if header.Kind != kind {
+		return fmt.Errorf("файл содержит %q, ожидалось %q", header.Kind, kind)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// codecSamples возвращает задачи и заметки, в которых заполнены все сохраняемые поля
+func codecSamples(t *testing.T) ([]*model.Task, []*model.Note) {
+	t.Helper()
+	due := time.Date(2026, time.May, 1, 18, 30, 0, 0, time.UTC)
+	deletedAt := due.Add(time.Hour)
+
+	full, err := model.NewTask("Задача, с \"кавычками\"\nи переносом", "<описание> & прочее", model.PriorityHigh, &due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full.SetID(1)
+	if err := full.SetStatus(model.StatusInProgress); err != nil {
+		t.Fatal(err)
+	}
+	full.SetVersion(3)
+	deleted := newBenchmarkTask(t, 2)
+	deleted.SetID(2)
+	deleted.SetVersion(1)
+	deleted.SetDeletedAt(&deletedAt)
+
+	note := model.NewNote("Заметка", "Содержимое\tс табуляцией", model.CategoryPersonal)
+	note.SetID(1)
+	note.SetVersion(2)
+	note.SetDeletedAt(&deletedAt)
+	return []*model.Task{full, deleted}, []*model.Note{note}
+}
+
+// Каждый встроенный формат сохраняет все поля записей
+func TestCodecRoundTrip(t *testing.T) {
+	tasks, notes := codecSamples(t)
+
+	for _, c := range []Codec{jsonCodec{}, csvCodec{}, gobCodec{}, xmlCodec{}, ndjsonCodec{}} {
+		t.Run(c.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.EncodeTasks(&buf, tasks); err != nil {
+				t.Fatal(err)
+			}
+			report := &LoadReport{}
+			decoded, err := c.DecodeTasks(&buf, "tasks"+c.Extension(), report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != len(tasks) {
+				t.Fatalf("прочитано %d задач из %d", len(decoded), len(tasks))
+			}
+			for i := range tasks {
+				if fields := diffTasks(tasks[i], decoded[i]); len(fields) > 0 {
+					t.Errorf("задача %d: различаются поля %v", tasks[i].GetID(), fields)
+				}
+			}
+
+			buf.Reset()
+			if err := c.EncodeNotes(&buf, notes); err != nil {
+				t.Fatal(err)
+			}
+			decodedNotes, err := c.DecodeNotes(&buf, "notes"+c.Extension(), report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decodedNotes) != 1 || len(diffNotes(notes[0], decodedNotes[0])) > 0 {
+				t.Errorf("заметка прочитана с расхождениями: %v", decodedNotes)
+			}
+			if report.HasIssues() {
+				t.Errorf("чтение с проблемами:\n%s", report)
+			}
+		})
+	}
+}
+
+// Хранилище пишет только настроенные форматы и при порче основного читает зеркало
+func TestStorageWithFormats(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	formats := WithFormats(FormatNDJSON, FormatGob, FormatXML)
+	storage, _ := openStorage(t, tasksFile, notesFile, formats)
+	addTask(t, storage, "Задача")
+	closeStorage(t, storage)
+
+	for ext, want := range map[string]bool{".ndjson": true, ".gob": true, ".xml": true, ".json": false, ".csv": false} {
+		if got := fileExists(tasksFile + ext); got != want {
+			t.Errorf("файл %s: есть %v, ожидалось %v", ext, got, want)
+		}
+	}
+
+	if err := os.WriteFile(tasksFile+".ndjson", []byte("{оборвано"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage, report := openStorage(t, tasksFile, notesFile, formats)
+	if recovered := issuesWith(report, LoadRecovered); len(recovered) != 1 || recovered[0].File != tasksFile+".ndjson" {
+		t.Errorf("ожидалось восстановление tasks.ndjson, отчёт:\n%s", report)
+	}
+	if task, err := storage.GetTask(1); err != nil || task.GetTitle() != "Задача" {
+		t.Errorf("задача из зеркала: %v", err)
+	}
+
+	otherTasks, otherNotes := tempDataFiles(t)
+	for _, bad := range [][]string{{"yaml"}, {FormatJSON, FormatJSON}} {
+		if _, _, err := Open(otherTasks, otherNotes, WithFormats(bad[0], bad[1:]...)); !model.IsValidationError(err) {
+			t.Errorf("WithFormats%v: ожидалась ошибка валидации, получено %v", bad, err)
+		}
+	}
+}
+
+// copyCodec - JSON под другим именем и расширением, как сторонний формат
+type copyCodec struct {
+	jsonCodec
+}
+
+func (copyCodec) Name() string      { return "json-copy" }
+func (copyCodec) Extension() string { return ".jcopy" }
+
+var registerCopyCodec sync.Once
+
+// Зарегистрированный формат доступен хранилищу по имени, а повтор имени или расширения отклоняется
+func TestRegisterCodec(t *testing.T) {
+	registerCopyCodec.Do(func() { RegisterCodec(copyCodec{}) })
+
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithFormats("json-copy"))
+	addTask(t, storage, "Задача")
+	closeStorage(t, storage)
+	if !fileExists(tasksFile + ".jcopy") {
+		t.Error("файл зарегистрированного формата не записан")
+	}
+
+	for _, c := range []Codec{copyCodec{}, struct{ csvCodec }{}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("повторная регистрация %s (%s) не отклонена", c.Name(), c.Extension())
+				}
+			}()
+			RegisterCodec(c)
+		}()
+	}
+}
+
+// Файлы читаются по записи прямо из потока: большой набор, закодированный в канал,
+// разбирается полностью, хотя его байты ни разу не лежат в памяти целиком
+func TestDecodeStreamedTasks(t *testing.T) {
+	tasks := make([]*model.Task, 20000)
+	for i := range tasks {
+		tasks[i] = newBenchmarkTask(t, i+1)
+		tasks[i].SetID(i + 1)
+		tasks[i].SetVersion(1)
+	}
+
+	for _, c := range []Codec{jsonCodec{}, csvCodec{}, ndjsonCodec{}, xmlCodec{}, gobCodec{}} {
+		t.Run(c.Name(), func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() { w.CloseWithError(c.EncodeTasks(w, tasks)) }()
+
+			report := &LoadReport{}
+			decoded, err := c.DecodeTasks(r, "tasks"+c.Extension(), report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != len(tasks) || decoded[len(decoded)-1].GetID() != len(tasks) || report.HasIssues() {
+				t.Fatalf("прочитано %d задач из %d, отчёт:\n%s", len(decoded), len(tasks), report)
+			}
+		})
+	}
+}
+
+// Оборванный файл документных форматов не читается целиком, а в построчных теряется
+// только оборванная запись, и отчёт указывает её строку
+func TestDecodeTruncatedTasks(t *testing.T) {
+	tasks := make([]*model.Task, 3)
+	for i := range tasks {
+		tasks[i] = newBenchmarkTask(t, i+1)
+		tasks[i].SetID(i + 1)
+		tasks[i].SetVersion(1)
+	}
+
+	tests := []struct {
+		codec Codec
+		// wantRow - строка оборванной записи в отчёте; 0 - файл должен не читаться
+		wantRow int
+	}{
+		{jsonCodec{}, 0},
+		{xmlCodec{}, 0},
+		{gobCodec{}, 0},
+		{ndjsonCodec{}, 4},
+		{csvCodec{}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.codec.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.codec.EncodeTasks(&buf, tasks); err != nil {
+				t.Fatal(err)
+			}
+			// Обрываем запись последней задачи посередине
+			last := bytes.LastIndex(buf.Bytes(), []byte("Задача 3"))
+			if last < 0 {
+				t.Fatal("в файле нет заголовка последней задачи")
+			}
+			truncated := bytes.NewReader(buf.Bytes()[:last+len("Задача")])
+
+			report := &LoadReport{}
+			decoded, err := tt.codec.DecodeTasks(truncated, "tasks"+tt.codec.Extension(), report)
+			if tt.wantRow == 0 {
+				if err == nil {
+					t.Errorf("оборванный файл прочитан без ошибки: %d задач", len(decoded))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			skipped := issuesWith(report, LoadSkipped)
+			if len(decoded) != 2 || len(skipped) != 1 || skipped[0].Row != tt.wantRow {
+				t.Errorf("прочитано %d задач, отчёт:\n%s", len(decoded), report)
+			}
+		})
+	}
+}
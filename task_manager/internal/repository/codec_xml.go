@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"task-manager/internal/model"
+	"time"
+)
+
+// xmlCodec - XML документ <tasks> или <notes> с версией формата в атрибуте корневого элемента.
+// Элементы <task> и <note> читаются и пишутся по одному
+type xmlCodec struct{}
+
+// Name возвращает имя формата
+func (xmlCodec) Name() string { return FormatXML }
+
+// Extension возвращает расширение файлов формата
+func (xmlCodec) Extension() string { return ".xml" }
+
+// EncodeTasks записывает задачи
+func (xmlCodec) EncodeTasks(w io.Writer, tasks []*model.Task) error {
+	return encodeXML(w, kindTasks, "task", tasks, newTaskRecord)
+}
+
+// DecodeTasks разбирает задачи
+func (xmlCodec) DecodeTasks(r io.Reader, path string, report *LoadReport) ([]*model.Task, error) {
+	var tasks []*model.Task
+	ids := make(duplicateIDs)
+	err := decodeXML(r, kindTasks, "task", func(row int, record taskRecord) {
+		if task := recordTask(record, path, row, ids, report); task != nil {
+			tasks = append(tasks, task)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// EncodeNotes записывает заметки
+func (xmlCodec) EncodeNotes(w io.Writer, notes []*model.Note) error {
+	return encodeXML(w, kindNotes, "note", notes, newNoteRecord)
+}
+
+// DecodeNotes разбирает заметки
+func (xmlCodec) DecodeNotes(r io.Reader, path string, report *LoadReport) ([]*model.Note, error) {
+	var notes []*model.Note
+	ids := make(duplicateIDs)
+	err := decodeXML(r, kindNotes, "note", func(row int, record noteRecord) {
+		notes = append(notes, recordNote(record, path, row, ids, report))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// encodeXML записывает документ с XML заголовком и отступами: корневой элемент root
+// с версией формата и элементы element по одному на запись
+func encodeXML[T, R any](w io.Writer, root, element string, items []T, record func(T) R) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	start := xml.StartElement{Name: xml.Name{Local: root}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "version"}, Value: strconv.Itoa(CurrentFormatVersion)},
+		{Name: xml.Name{Local: "saved_at"}, Value: time.Now().Format(time.RFC3339Nano)},
+	}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := encoder.EncodeElement(record(item), xml.StartElement{Name: xml.Name{Local: element}}); err != nil {
+			return err
+		}
+	}
+	if err := encoder.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// decodeXML проверяет корневой элемент root и передаёт элементы element в each по одному, с номера 1.
+// Прочие элементы пропускаются
+func decodeXML[R any](r io.Reader, root, element string, each func(row int, record R)) error {
+	decoder := xml.NewDecoder(r)
+
+	found := false
+	depth := 0
+	row := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			if !found {
+				return fmt.Errorf("нет корневого элемента <%s>", root)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if t.Name.Local != root {
+					return fmt.Errorf("файл содержит %q, ожидалось %q", t.Name.Local, root)
+				}
+				if err := checkXMLVersion(t); err != nil {
+					return err
+				}
+				found = true
+				depth++
+				continue
+			}
+			if t.Name.Local != element {
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var record R
+			if err := decoder.DecodeElement(&record, &t); err != nil {
+				return err
+			}
+			row++
+			each(row, record)
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// checkXMLVersion проверяет, что документ записан поддерживаемой версией программы
+func checkXMLVersion(root xml.StartElement) error {
+	version := 0
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "version" {
+			version, _ = strconv.Atoi(attr.Value)
+		}
+	}
+	if version < 1 {
+		return fmt.Errorf("в файле не указана версия формата")
+	}
+	if version > CurrentFormatVersion {
+		return fmt.Errorf("версия формата %d новее поддерживаемой %d", version, CurrentFormatVersion)
+	}
+	return nil
+}
@@ -0,0 +1,502 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Формат зашифрованного файла: encryptedMagic, соль, nonce и шифртекст AES-256-GCM.
+// Ключ выводится из парольной фразы и соли через PBKDF2-SHA256
+const (
+	encryptedMagic    = "TMENC1\n"
+	saltSize          = 16
+	keySize           = 32
+	pbkdf2Iterations  = 600000
+	encryptedLineMark = "enc:" // префикс зашифрованной строки журнала
+)
+
+var (
+	// ErrWrongPassphrase возвращается, если файл не удалось расшифровать: парольная фраза неверна или файл повреждён
+	ErrWrongPassphrase = errors.New("неверная парольная фраза или повреждённый зашифрованный файл")
+	// ErrEncrypted возвращается при чтении зашифрованного файла без парольной фразы
+	ErrEncrypted = errors.New("файл зашифрован, нужна парольная фраза")
+	// ErrNotEncrypted возвращается при чтении незашифрованного файла, когда шифрование включено:
+	// иначе файл можно было бы подменить открытым текстом. Существующие данные шифруются через RekeyFiles
+	ErrNotEncrypted = errors.New("файл не зашифрован, хотя шифрование включено")
+)
+
+// fileCipher шифрует и расшифровывает файлы данных.
+// Ключ выводится один раз на соль и кэшируется, поэтому сохранения не платят за PBKDF2.
+// nil *fileCipher означает хранение без шифрования
+type fileCipher struct {
+	passphrase string
+
+	mu   sync.Mutex
+	salt []byte                 // соль для новых записей
+	keys map[string]cipher.AEAD // выведенные ключи по соли
+}
+
+// newFileCipher создаёт шифр для парольной фразы; пустая фраза отключает шифрование
+func newFileCipher(passphrase string) *fileCipher {
+	if passphrase == "" {
+		return nil
+	}
+	return &fileCipher{passphrase: passphrase, keys: make(map[string]cipher.AEAD)}
+}
+
+// aead возвращает AEAD для соли, выводя ключ при первом обращении
+func (c *fileCipher) aead(salt []byte) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if aead, ok := c.keys[string(salt)]; ok {
+		return aead, nil
+	}
+
+	key, err := pbkdf2.Key(sha256.New, c.passphrase, salt, pbkdf2Iterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keys[string(salt)] = aead
+	return aead, nil
+}
+
+// writeSalt возвращает соль для новых записей; она выбирается один раз
+// (или берётся из первого прочитанного файла), чтобы не выводить ключ при каждом сохранении
+func (c *fileCipher) writeSalt() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.salt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		c.salt = salt
+	}
+	return c.salt, nil
+}
+
+// seal шифрует данные; без шифра возвращает их как есть
+func (c *fileCipher) seal(plain []byte) ([]byte, error) {
+	if c == nil {
+		return plain, nil
+	}
+
+	salt, err := c.writeSalt()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+saltSize+len(nonce)+len(plain)+aead.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	// Заголовок входит в дополнительные данные, чтобы его нельзя было подменить
+	return aead.Seal(out, nonce, plain, out), nil
+}
+
+// open расшифровывает данные файла path. Без шифра незашифрованные данные возвращаются как есть,
+// а с шифром отклоняются ошибкой ErrNotEncrypted
+func (c *fileCipher) open(path string, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		if c != nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotEncrypted)
+		}
+		return data, nil
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrEncrypted)
+	}
+
+	header := len(encryptedMagic) + saltSize
+	if len(data) < header {
+		return nil, fmt.Errorf("%s: %w", path, ErrWrongPassphrase)
+	}
+	salt := data[len(encryptedMagic):header]
+
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < header+aead.NonceSize() {
+		return nil, fmt.Errorf("%s: %w", path, ErrWrongPassphrase)
+	}
+	nonce := data[header : header+aead.NonceSize()]
+
+	plain, err := aead.Open(nil, nonce, data[header+aead.NonceSize():], data[:header+aead.NonceSize()])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrWrongPassphrase)
+	}
+
+	// Дальнейшие записи используют ту же соль, ключ для неё уже выведен
+	c.mu.Lock()
+	if c.salt == nil {
+		c.salt = append([]byte(nil), salt...)
+	}
+	c.mu.Unlock()
+
+	return plain, nil
+}
+
+// sealWriter оборачивает функцию записи: содержимое собирается в буфер и записывается зашифрованным
+func (c *fileCipher) sealWriter(write func(w io.Writer) error) func(w io.Writer) error {
+	if c == nil {
+		return write
+	}
+
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return err
+		}
+		sealed, err := c.seal(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(sealed)
+		return err
+	}
+}
+
+// sealLine шифрует одну строку журнала в текстовый вид
+func (c *fileCipher) sealLine(line []byte) ([]byte, error) {
+	if c == nil {
+		return line, nil
+	}
+
+	sealed, err := c.seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encryptedLineMark + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// openLine расшифровывает строку журнала; незашифрованные строки возвращаются как есть
+// только без шифра (см. open)
+func (c *fileCipher) openLine(path string, line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, []byte(encryptedLineMark)) {
+		if c != nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotEncrypted)
+		}
+		return line, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(line[len(encryptedLineMark):]))
+	if err != nil {
+		return nil, err
+	}
+	return c.open(path, sealed)
+}
+
+// isEncrypted сообщает, начинаются ли данные с заголовка зашифрованного файла
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// openDataFile открывает файл данных для последовательного чтения. Открытый файл читается
+// по частям; зашифрованный файл целиком расшифровывается в память, потому что весь файл
+// закрыт одной меткой AES-GCM и до проверки метки данным нельзя доверять.
+// При включённом шифровании незашифрованный файл отклоняется ошибкой ErrNotEncrypted
+func openDataFile(path string, c *fileCipher) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(file)
+	if prefix, _ := r.Peek(len(encryptedMagic)); !isEncrypted(prefix) {
+		if c != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, ErrNotEncrypted)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{r, file}, nil
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := c.open(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(plain)), nil
+}
+
+// dataFiles возвращает файлы коллекций, которые хранятся зашифрованными:
+// файлы всех зарегистрированных форматов и журналы
+func dataFiles(tasksFile, notesFile string) []string {
+	var paths []string
+	for _, base := range []string{tasksFile, notesFile} {
+		for _, ext := range codecExtensions() {
+			paths = append(paths, base+ext)
+		}
+		paths = append(paths, base+".journal")
+	}
+	return paths
+}
+
+// checkPassphrase проверяет парольную фразу на существующих файлах данных до загрузки,
+// чтобы неверная фраза не превратилась в пустое хранилище, которое затем перезапишет данные.
+// Фраза считается верной, если расшифровался хотя бы один файл или строка журнала:
+// отдельный повреждённый файл восстанавливается при загрузке из второго формата
+func checkPassphrase(c *fileCipher, paths ...string) error {
+	var lastErr error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		// Журнал шифруется построчно, остальные файлы - целиком
+		chunks := [][]byte{data}
+		if isJournalFile(path) {
+			chunks = bytes.Split(data, []byte("\n"))
+		}
+
+		for _, chunk := range chunks {
+			if !isEncrypted(chunk) && !bytes.HasPrefix(chunk, []byte(encryptedLineMark)) {
+				continue
+			}
+			if c == nil {
+				return fmt.Errorf("%s: %w", path, ErrEncrypted)
+			}
+
+			if isEncrypted(chunk) {
+				_, err = c.open(path, chunk)
+			} else {
+				_, err = c.openLine(path, chunk)
+			}
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// checkEncrypted проверяет, что при включённом шифровании все файлы данных и строки журналов
+// зашифрованы: открытый текст в зашифрованном хранилище - подмена или данные, записанные до
+// включения шифрования, и их нужно зашифровать через RekeyFiles
+func checkEncrypted(c *fileCipher, paths ...string) error {
+	if c == nil {
+		return nil
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		chunks := [][]byte{data}
+		if isJournalFile(path) {
+			chunks = bytes.Split(data, []byte("\n"))
+		}
+		for _, chunk := range chunks {
+			if isJournalFile(path) && len(chunk) == 0 {
+				continue
+			}
+			if !isEncrypted(chunk) && !bytes.HasPrefix(chunk, []byte(encryptedLineMark)) {
+				return fmt.Errorf("%s: %w; зашифруйте данные через RekeyFiles", path, ErrNotEncrypted)
+			}
+		}
+	}
+	return nil
+}
+
+// RekeyFiles перешифровывает файлы данных новой парольной фразой.
+// Пустая oldPassphrase означает, что файлы сейчас не зашифрованы, пустая newPassphrase - что
+// шифрование нужно снять. Незашифрованные файлы, оставшиеся с тех пор, когда шифрование было
+// выключено, тоже шифруются. Перед перезаписью делается снимок "pre-rekey"; все снимки из каталога
+// снимков (по умолчанию или заданного через WithSnapshots) перешифровываются вместе с файлами,
+// чтобы после включения шифрования на диске не оставалось открытых копий данных, а снимки
+// оставались читаемыми с новым ключом. Снимки, которые не открываются старым ключом (сделаны
+// под ещё более старым), не трогаются. Все файлы заменяются одной атомарной группой;
+// каталог данных блокируется на время операции
+func RekeyFiles(tasksFile, notesFile, oldPassphrase, newPassphrase string, opts ...Option) error {
+	options := newStorageOptions(tasksFile, opts)
+
+	locks, err := lockDirs(tasksFile, notesFile)
+	if err != nil {
+		return err
+	}
+	defer unlockDirs(locks)
+
+	oldCipher := newFileCipher(oldPassphrase)
+	newCipher := newFileCipher(newPassphrase)
+
+	if err := checkPassphrase(oldCipher, dataFiles(tasksFile, notesFile)...); err != nil {
+		return err
+	}
+	// Перешифровка повреждённого файла закрепила бы повреждение под новым манифестом
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		return fmt.Errorf("файлы данных повреждены, откройте хранилище для восстановления: %s", report.Issues()[0])
+	}
+
+	files, err := rekeyCollections(tasksFile, notesFile, oldCipher, newCipher)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	if _, err := takeSnapshot(tasksFile, notesFile, options.snapshots.Dir, "pre-rekey"); err != nil {
+		return fmt.Errorf("ошибка снимка перед сменой ключа: %w", err)
+	}
+
+	snapshots, err := ListSnapshots(options.snapshots.Dir)
+	if err != nil {
+		return err
+	}
+	for _, snap := range snapshots {
+		snapFiles, err := rekeyCollections(
+			filepath.Join(snap.Dir, filepath.Base(tasksFile)),
+			filepath.Join(snap.Dir, filepath.Base(notesFile)),
+			oldCipher, newCipher)
+		if err != nil {
+			continue // снимок под другим ключом остаётся как есть
+		}
+		files = append(files, snapFiles...)
+	}
+
+	return writeFilesAtomic(files...)
+}
+
+// rekeyCollections готовит перешифрованные файлы коллекций tasksFile и notesFile вместе
+// с обновлёнными манифестами. Контрольные суммы обновляются только у файлов, которые совпадали
+// с манифестом: перешифровка не должна скрыть повреждение
+func rekeyCollections(tasksFile, notesFile string, oldCipher, newCipher *fileCipher) ([]atomicFile, error) {
+	var files []atomicFile
+	for _, base := range []string{tasksFile, notesFile} {
+		m, err := readManifest(base)
+		if err != nil {
+			return nil, err
+		}
+
+		var group []atomicFile
+		sums := make(map[string]fileSum)
+		for _, suffix := range append(codecExtensions(), ".journal") {
+			path := base + suffix
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			var out []byte
+			if isJournalFile(path) {
+				out, err = rekeyJournal(path, data, oldCipher, newCipher)
+			} else {
+				var plain []byte
+				if plain, err = rekeyOpen(path, data, oldCipher); err == nil {
+					out, err = newCipher.seal(plain)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if !isJournalFile(path) && m.verify(path) == nil {
+				sums[path] = fileSum{sha256: checksum(out), size: len(out)}
+			}
+			group = append(group, atomicFile{path: path, write: func(w io.Writer) error {
+				_, err := w.Write(out)
+				return err
+			}})
+		}
+
+		// Контрольные суммы считаются по байтам на диске, поэтому манифест обновляется вместе с файлами
+		if len(sums) > 0 {
+			group = append([]atomicFile{manifestFile(base, sums)}, group...)
+		}
+		files = append(files, group...)
+	}
+	return files, nil
+}
+
+// rekeyOpen расшифровывает данные старым шифром. Незашифрованные данные принимаются как есть:
+// перешифровка - способ зашифровать файлы, записанные до включения шифрования
+func rekeyOpen(path string, data []byte, oldCipher *fileCipher) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	return oldCipher.open(path, data)
+}
+
+// rekeyJournal перешифровывает журнал построчно
+func rekeyJournal(path string, data []byte, oldCipher, newCipher *fileCipher) ([]byte, error) {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		plain := line
+		if bytes.HasPrefix(line, []byte(encryptedLineMark)) {
+			var err error
+			if plain, err = oldCipher.openLine(path, line); err != nil {
+				// Нерасшифровываемая строка (например, оборванная при сбое) пропускается и при загрузке
+				continue
+			}
+		}
+		sealed, err := newCipher.sealLine(plain)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(sealed)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// isJournalFile сообщает, является ли путь журналом изменений
+func isJournalFile(path string) bool {
+	return strings.HasSuffix(path, ".journal")
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// snapshotFiles возвращает все файлы в каталоге снимков
+func snapshotFiles(t *testing.T, tasksFile string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(defaultSnapshotDir(tasksFile), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Base(path) != snapshotMetaFile {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithEncryption("secret"))
+	addTask(t, storage, "Секретная задача")
+	closeStorage(t, storage)
+
+	for _, path := range dataFiles(tasksFile, notesFile) {
+		if data, err := os.ReadFile(path); err == nil && bytes.Contains(data, []byte("Секретная")) {
+			t.Errorf("%s содержит открытый текст", path)
+		}
+	}
+
+	storage, _ = openStorage(t, tasksFile, notesFile, WithEncryption("secret"))
+	if task, err := storage.GetTask(1); err != nil || task.GetTitle() != "Секретная задача" {
+		t.Fatalf("после повторного открытия: %v", err)
+	}
+	closeStorage(t, storage)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"неверная фраза", []Option{WithEncryption("wrong")}, ErrWrongPassphrase},
+		{"без фразы", nil, ErrEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if storage, _, err := Open(tasksFile, notesFile, tt.opts...); !errors.Is(err, tt.wantErr) {
+				if err == nil {
+					storage.Close()
+				}
+				t.Fatalf("ожидалась %v, получено %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Записи журнала шифруются построчно и воспроизводятся при повторном открытии
+func TestEncryptedJournal(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	opts := []Option{WithEncryption("secret"), WithJournal(JournalOptions{})}
+	storage, _ := openStorage(t, tasksFile, notesFile, opts...)
+	addTask(t, storage, "Первая")
+	closeStorage(t, storage)
+	snapshotDir := t.TempDir()
+	saved, err := TakeSnapshot(tasksFile, notesFile, snapshotDir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage, _ = openStorage(t, tasksFile, notesFile, opts...)
+	addTask(t, storage, "Секретная задача")
+	journal, err := os.ReadFile(tasksFile + ".journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(journal, []byte("Секретная")) {
+		t.Error("журнал содержит открытый текст")
+	}
+	closeStorage(t, storage)
+
+	// Файлы данных без второй задачи и журнал с ней, как после аварийного завершения
+	if err := restoreFiles(tasksFile, notesFile, snapshotDir, saved.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tasksFile+".journal", journal, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Open(tasksFile, notesFile, WithJournal(JournalOptions{}), WithEncryption("wrong")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("неверная фраза: ожидалась ErrWrongPassphrase, получено %v", err)
+	}
+	storage, report := openStorage(t, tasksFile, notesFile, opts...)
+	if report.HasIssues() {
+		t.Errorf("журнал прочитан с проблемами:\n%s", report)
+	}
+	if task, err := storage.GetTask(2); err != nil || task.GetTitle() != "Секретная задача" {
+		t.Errorf("задача из журнала не восстановлена: %v", err)
+	}
+}
+
+// Открытый файл, подложенный в зашифрованное хранилище, не читается
+func TestEncryptedStorageRejectsPlaintext(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain func(t *testing.T, tasksFile string)
+	}{
+		{"файл данных", func(t *testing.T, tasksFile string) {
+			data := `[{"id":1,"title":"Подмена","version":1}]`
+			if err := os.WriteFile(tasksFile+".json", []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"строка журнала", func(t *testing.T, tasksFile string) {
+			file, err := os.OpenFile(tasksFile+".journal", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if _, err := file.WriteString(`{"op":"delete","id":1}` + "\n"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasksFile, notesFile := tempDataFiles(t)
+			storage, _ := openStorage(t, tasksFile, notesFile, WithEncryption("secret"))
+			addTask(t, storage, "Задача")
+			closeStorage(t, storage)
+
+			tt.plain(t, tasksFile)
+
+			if storage, _, err := Open(tasksFile, notesFile, WithEncryption("secret")); !errors.Is(err, ErrNotEncrypted) {
+				if err == nil {
+					storage.Close()
+				}
+				t.Fatalf("ожидалась ErrNotEncrypted, получено %v", err)
+			}
+		})
+	}
+}
+
+// Включение шифрования не оставляет открытых копий данных в снимках, а старые снимки
+// остаются читаемыми с новым ключом
+func TestRekeyEncryptsSnapshots(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "Открытая задача")
+	old, err := storage.Snapshot("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeStorage(t, storage)
+
+	if err := RekeyFiles(tasksFile, notesFile, "", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range append(dataFiles(tasksFile, notesFile), snapshotFiles(t, tasksFile)...) {
+		if data, err := os.ReadFile(path); err == nil && bytes.Contains(data, []byte("Открытая")) {
+			t.Errorf("%s содержит открытый текст", path)
+		}
+	}
+	snapshots, err := ListSnapshots(defaultSnapshotDir(tasksFile))
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("ожидались снимки manual и pre-rekey, найдено %v: %v", snapshots, err)
+	}
+
+	storage, _ = openStorage(t, tasksFile, notesFile, WithEncryption("secret"))
+	addTask(t, storage, "После шифрования")
+	if _, err := storage.Restore(old.ID); err != nil {
+		t.Fatalf("старый снимок не восстанавливается с новым ключом: %v", err)
+	}
+	if tasks, _ := storage.Count(); tasks != 1 {
+		t.Errorf("после восстановления %d задач, ожидалась 1", tasks)
+	}
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		t.Errorf("после восстановления файлы не совпадают с манифестом:\n%s", report)
+	}
+}
+
+// Смена ключа перешифровывает снимки, и старая фраза к ним больше не подходит
+func TestRekeyChangesSnapshotKey(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithEncryption("old"))
+	addTask(t, storage, "Задача")
+	if _, err := storage.Snapshot("manual"); err != nil {
+		t.Fatal(err)
+	}
+	closeStorage(t, storage)
+
+	if err := RekeyFiles(tasksFile, notesFile, "wrong", "new"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("неверная текущая фраза: ожидалась ErrWrongPassphrase, получено %v", err)
+	}
+	if err := RekeyFiles(tasksFile, notesFile, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListSnapshots(defaultSnapshotDir(tasksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, snap := range snapshots {
+		files := dataFiles(filepath.Join(snap.Dir, "tasks"), filepath.Join(snap.Dir, "notes"))
+		if err := checkPassphrase(newFileCipher("new"), files...); err != nil {
+			t.Errorf("снимок %s (%s) не открывается новой фразой: %v", snap.ID, snap.Reason, err)
+		}
+		if err := checkPassphrase(newFileCipher("old"), files...); !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("снимок %s (%s) открывается старой фразой: %v", snap.ID, snap.Reason, err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"task-manager/internal/model"
+)
+
+// FileBackend хранит задачи и заметки в файлах одного или нескольких форматов (см. Codec):
+// основной формат читается первым, зеркала - если он повреждён. По умолчанию это JSON и CSV
+type FileBackend struct {
+	tasksFile string
+	notesFile string
+	cipher    *fileCipher // nil - файлы не шифруются
+	codecs    []Codec     // первый - основной формат, остальные - зеркала
+
+	// Каталог снимков, из которых восстанавливаются повреждённые коллекции; пусто - не восстанавливать
+	snapshotDir string
+
+	// Файлы, изменённые в обход хранилища (см. loadVerified): при сохранении они пропускаются,
+	// чтобы правка не потерялась до сверки через ReconcileFiles
+	heldMu sync.Mutex
+	held   map[string]bool
+
+	// Отпечатки файлов и базы коллекций для наблюдения за внешними изменениями (см. watcher.go)
+	watchMu  sync.Mutex
+	watching bool
+	states   map[string]fileState
+	bases    map[string]map[int]string
+}
+
+// NewFileBackend создаёт файловый бэкенд с форматами по умолчанию (JSON и зеркало в CSV);
+// tasksFile и notesFile задаются без расширения
+func NewFileBackend(tasksFile, notesFile string) *FileBackend {
+	codecs, _ := lookupCodecs(defaultFormats)
+	return &FileBackend{
+		tasksFile: tasksFile,
+		notesFile: notesFile,
+		codecs:    codecs,
+	}
+}
+
+// NewEncryptedFileBackend создаёт файловый бэкенд, который шифрует файлы данных
+// ключом, выведенным из passphrase. Незашифрованные файлы не читаются (ErrNotEncrypted):
+// существующие данные шифруются через RekeyFiles
+func NewEncryptedFileBackend(tasksFile, notesFile, passphrase string) *FileBackend {
+	b := NewFileBackend(tasksFile, notesFile)
+	b.cipher = newFileCipher(passphrase)
+	return b
+}
+
+// NewFileBackendWithFormats создаёт файловый бэкенд с основным форматом primary
+// и зеркалами mirrors (имена зарегистрированных форматов, см. RegisterCodec)
+func NewFileBackendWithFormats(tasksFile, notesFile, primary string, mirrors ...string) (*FileBackend, error) {
+	codecs, err := lookupCodecs(append([]string{primary}, mirrors...))
+	if err != nil {
+		return nil, err
+	}
+	b := NewFileBackend(tasksFile, notesFile)
+	b.codecs = codecs
+	return b, nil
+}
+
+// collectionFiles возвращает файлы коллекции base во всех форматах бэкенда, начиная с основного
+func (b *FileBackend) collectionFiles(base string) []string {
+	paths := make([]string, 0, len(b.codecs))
+	for _, c := range b.codecs {
+		paths = append(paths, base+c.Extension())
+	}
+	return paths
+}
+
+// codecFor возвращает формат файла path коллекции base
+func (b *FileBackend) codecFor(base, path string) (Codec, bool) {
+	for _, c := range b.codecs {
+		if base+c.Extension() == path {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// hold исключает файл path из сохранений
+func (b *FileBackend) hold(path string) {
+	b.heldMu.Lock()
+	defer b.heldMu.Unlock()
+
+	if b.held == nil {
+		b.held = make(map[string]bool)
+	}
+	b.held[path] = true
+}
+
+// release снова включает файл path в сохранения: его содержимое подхвачено в память
+func (b *FileBackend) release(path string) {
+	b.heldMu.Lock()
+	defer b.heldMu.Unlock()
+	delete(b.held, path)
+}
+
+// isHeld сообщает, исключён ли файл path из сохранений
+func (b *FileBackend) isHeld(path string) bool {
+	b.heldMu.Lock()
+	defer b.heldMu.Unlock()
+	return b.held[path]
+}
+
+// ========== Методы для работы с задачами ==========
+
+// SaveTasks атомарно сохраняет задачи во все форматы:
+// файлы заменяются только после успешной записи каждого из них
+func (b *FileBackend) SaveTasks(tasks []*model.Task) error {
+	if err := writeFilesAtomic(b.tasksGroup(tasks)...); err != nil {
+		return err
+	}
+
+	b.tasksSaved(tasks)
+	return nil
+}
+
+// tasksGroup готовит файлы задач во всех форматах и манифест к атомарной записи
+func (b *FileBackend) tasksGroup(tasks []*model.Task) []atomicFile {
+	files := make([]atomicFile, 0, len(b.codecs))
+	for _, c := range b.codecs {
+		if b.isHeld(b.tasksFile + c.Extension()) {
+			continue
+		}
+		files = append(files, atomicFile{path: b.tasksFile + c.Extension(), write: b.cipher.sealWriter(func(w io.Writer) error {
+			return c.EncodeTasks(w, tasks)
+		})})
+	}
+	return collectionGroup(b.tasksFile, files...)
+}
+
+// tasksSaved запоминает записанное состояние: собственная запись не должна выглядеть
+// для наблюдения как внешняя правка
+func (b *FileBackend) tasksSaved(tasks []*model.Task) {
+	b.rememberBase(kindTasks, recordKeys(tasks, taskAccessors))
+	b.rememberFiles(b.collectionFiles(b.tasksFile)...)
+}
+
+// LoadTasks загружает задачи из файлов, проверяя их по манифесту (см. loadVerified);
+// все пропущенные и исправленные записи попадают в report
+func (b *FileBackend) LoadTasks(report *LoadReport) ([]*model.Task, error) {
+	tasks, err := loadVerified(b, func(fb *FileBackend) string { return fb.tasksFile }, (*FileBackend).loadTasksFile, report)
+	if err != nil {
+		return nil, err
+	}
+
+	b.tasksSaved(tasks)
+	return tasks, nil
+}
+
+// loadTasksFile загружает задачи из файла формата c; отсутствующий файл - пустая коллекция
+func (b *FileBackend) loadTasksFile(c Codec, report *LoadReport) ([]*model.Task, error) {
+	path := b.tasksFile + c.Extension()
+	r, err := openDataFile(path, b.cipher)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Файл не существует - это нормально при первом запуске
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return c.DecodeTasks(r, path, report)
+}
+
+// ========== Методы для работы с заметками ==========
+
+// SaveNotes атомарно сохраняет заметки во все форматы:
+// файлы заменяются только после успешной записи каждого из них
+func (b *FileBackend) SaveNotes(notes []*model.Note) error {
+	if err := writeFilesAtomic(b.notesGroup(notes)...); err != nil {
+		return err
+	}
+
+	b.notesSaved(notes)
+	return nil
+}
+
+// notesGroup готовит файлы заметок во всех форматах и манифест к атомарной записи
+func (b *FileBackend) notesGroup(notes []*model.Note) []atomicFile {
+	files := make([]atomicFile, 0, len(b.codecs))
+	for _, c := range b.codecs {
+		if b.isHeld(b.notesFile + c.Extension()) {
+			continue
+		}
+		files = append(files, atomicFile{path: b.notesFile + c.Extension(), write: b.cipher.sealWriter(func(w io.Writer) error {
+			return c.EncodeNotes(w, notes)
+		})})
+	}
+	return collectionGroup(b.notesFile, files...)
+}
+
+// notesSaved запоминает записанное состояние: собственная запись не должна выглядеть
+// для наблюдения как внешняя правка
+func (b *FileBackend) notesSaved(notes []*model.Note) {
+	b.rememberBase(kindNotes, recordKeys(notes, noteAccessors))
+	b.rememberFiles(b.collectionFiles(b.notesFile)...)
+}
+
+// LoadNotes загружает заметки из файлов, проверяя их по манифесту (см. loadVerified);
+// все пропущенные и исправленные записи попадают в report
+func (b *FileBackend) LoadNotes(report *LoadReport) ([]*model.Note, error) {
+	notes, err := loadVerified(b, func(fb *FileBackend) string { return fb.notesFile }, (*FileBackend).loadNotesFile, report)
+	if err != nil {
+		return nil, err
+	}
+
+	b.notesSaved(notes)
+	return notes, nil
+}
+
+// loadNotesFile загружает заметки из файла формата c; отсутствующий файл - пустая коллекция
+func (b *FileBackend) loadNotesFile(c Codec, report *LoadReport) ([]*model.Note, error) {
+	path := b.notesFile + c.Extension()
+	r, err := openDataFile(path, b.cipher)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return c.DecodeNotes(r, path, report)
+}
+
+// ========== Обе коллекции ==========
+
+// SaveCollections сохраняет задачи и заметки одной группой файлов:
+// ни один файл не заменяется, пока не записаны все
+func (b *FileBackend) SaveCollections(tasks []*model.Task, notes []*model.Note) error {
+	if err := writeFilesAtomic(append(b.tasksGroup(tasks), b.notesGroup(notes)...)...); err != nil {
+		return err
+	}
+
+	b.tasksSaved(tasks)
+	b.notesSaved(notes)
+	return nil
+}
+
+// ========== Последовательности ID ==========
+
+// sequenceFile возвращает путь к файлу последовательности; он лежит рядом с файлами сущности
+func (b *FileBackend) sequenceFile(name string) string {
+	if name == noteSequence {
+		return b.notesFile + ".seq"
+	}
+	return b.tasksFile + ".seq"
+}
+
+// LoadSequence читает последнее выданное значение последовательности из файла
+func (b *FileBackend) LoadSequence(name string) (int, error) {
+	data, err := os.ReadFile(b.sequenceFile(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("повреждён файл последовательности %s: %w", b.sequenceFile(name), err)
+	}
+	return value, nil
+}
+
+// SaveSequence записывает последнее выданное значение последовательности в файл
+func (b *FileBackend) SaveSequence(name string, value int) error {
+	return writeFileAtomic(b.sequenceFile(name), func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, value)
+		return err
+	})
+}
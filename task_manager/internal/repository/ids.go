@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"fmt"
+	"task-manager/internal/model"
+)
+
+// Имена последовательностей идентификаторов для бэкенда
+const (
+	taskSequence = "task"
+	noteSequence = "note"
+)
+
+// assignTaskID выдаёт новой задаче ID из последовательности или проверяет уже заданный:
+// отрицательные и занятые ID отклоняются
+// Вызывается под блокировками s.saveMu и s.mu
+func (s *Storage) assignTaskID(task *model.Task) error {
+	id := task.GetID()
+	if id < 0 {
+		return model.NewValidationError(fmt.Sprintf("invalid task id %d", id))
+	}
+	if id == 0 {
+		return s.advanceSequence(taskSequence, &s.taskSeq, s.taskSeq+1, task.SetID)
+	}
+
+	if s.findTask(id) >= 0 {
+		return model.NewValidationError(fmt.Sprintf("task with id %d already exists", id))
+	}
+	if id > s.taskSeq {
+		return s.advanceSequence(taskSequence, &s.taskSeq, id, nil)
+	}
+	return nil
+}
+
+// assignNoteID выдаёт новой заметке ID из последовательности или проверяет уже заданный
+// Вызывается под блокировками s.saveMu и s.mu
+func (s *Storage) assignNoteID(note *model.Note) error {
+	id := note.GetID()
+	if id < 0 {
+		return model.NewValidationError(fmt.Sprintf("invalid note id %d", id))
+	}
+	if id == 0 {
+		return s.advanceSequence(noteSequence, &s.noteSeq, s.noteSeq+1, note.SetID)
+	}
+
+	if s.findNote(id) >= 0 {
+		return model.NewValidationError(fmt.Sprintf("note with id %d already exists", id))
+	}
+	if id > s.noteSeq {
+		return s.advanceSequence(noteSequence, &s.noteSeq, id, nil)
+	}
+	return nil
+}
+
+// advanceSequence сохраняет новое значение последовательности и только потом применяет его,
+// чтобы после сбоя один и тот же ID не был выдан повторно
+func (s *Storage) advanceSequence(name string, seq *int, value int, apply func(int)) error {
+	if err := s.backend.SaveSequence(name, value); err != nil {
+		return fmt.Errorf("ошибка сохранения последовательности %s: %w", name, err)
+	}
+
+	*seq = value
+	if apply != nil {
+		apply(value)
+	}
+	return nil
+}
+
+// restoreSequences восстанавливает последовательности после загрузки
+// и выдаёт новые ID задачам и заметкам с некорректными или повторяющимися идентификаторами;
+// поднятые при этом последовательности сохраняются. Сами такие записи отмечает в отчёте бэкенд, который знает файл и строку
+func (s *Storage) restoreSequences(report *LoadReport) {
+	storedTaskSeq, err := s.backend.LoadSequence(taskSequence)
+	if err != nil {
+		report.add(taskSequence, 0, LoadRepaired, fmt.Sprintf("%v; последовательность восстановлена по максимальному ID", err))
+	}
+	storedNoteSeq, err := s.backend.LoadSequence(noteSequence)
+	if err != nil {
+		report.add(noteSequence, 0, LoadRepaired, fmt.Sprintf("%v; последовательность восстановлена по максимальному ID", err))
+	}
+
+	// Последовательность не может отставать от уже выданных ID
+	taskSeq, noteSeq := storedTaskSeq, storedNoteSeq
+	for _, task := range s.tasks {
+		taskSeq = max(taskSeq, task.GetID())
+	}
+	for _, note := range s.notes {
+		noteSeq = max(noteSeq, note.GetID())
+	}
+
+	taskIDs := make(map[int]bool, len(s.tasks))
+	for _, task := range s.tasks {
+		if id := task.GetID(); id <= 0 || taskIDs[id] {
+			taskSeq++
+			task.SetID(taskSeq)
+		}
+		taskIDs[task.GetID()] = true
+	}
+
+	noteIDs := make(map[int]bool, len(s.notes))
+	for _, note := range s.notes {
+		if id := note.GetID(); id <= 0 || noteIDs[id] {
+			noteSeq++
+			note.SetID(noteSeq)
+		}
+		noteIDs[note.GetID()] = true
+	}
+
+	s.taskSeq, s.noteSeq = storedTaskSeq, storedNoteSeq
+	s.restoreSequence(report, taskSequence, &s.taskSeq, taskSeq)
+	s.restoreSequence(report, noteSequence, &s.noteSeq, noteSeq)
+}
+
+// restoreSequence поднимает последовательность до value и сразу сохраняет её, чтобы ID,
+// выданные при загрузке, не были выданы повторно после перезапуска. Хранилище только для чтения
+// и ошибка сохранения (она попадает в отчёт) оставляют новое значение только в памяти
+func (s *Storage) restoreSequence(report *LoadReport, name string, seq *int, value int) {
+	if value == *seq {
+		return
+	}
+	if !s.readOnly {
+		err := s.advanceSequence(name, seq, value, nil)
+		if err == nil {
+			return
+		}
+		report.add(name, 0, LoadFailed, err.Error())
+	}
+	*seq = value
+}
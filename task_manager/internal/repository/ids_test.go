@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// ID окончательно удалённой задачи с наибольшим ID не выдаётся снова и после перезапуска
+func TestIDsNotReusedAfterPurge(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	for _, title := range []string{"Первая", "Вторая", "Третья"} {
+		addTask(t, storage, title)
+	}
+	if err := storage.DeleteTask(3); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := storage.PurgeDeleted(0); err != nil {
+		t.Fatal(err)
+	}
+	closeStorage(t, storage)
+
+	storage, _ = openStorage(t, tasksFile, notesFile)
+	if task := addTask(t, storage, "Четвёртая"); task.GetID() != 4 {
+		t.Errorf("новая задача получила ID %d, ожидался 4", task.GetID())
+	}
+}
+
+func TestExplicitIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		wantErr bool
+		// wantNext - ID, который получит следующая задача без ID
+		wantNext int
+	}{
+		{"больше последовательности", 10, false, 11},
+		{"свободный меньше последовательности", 1, false, 3},
+		{"занятый", 2, true, 3},
+		{"отрицательный", -1, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMemoryStorage()
+			addTask(t, storage, "Первая")
+			if err := storage.DeleteTask(1); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := storage.PurgeDeleted(0); err != nil {
+				t.Fatal(err)
+			}
+			addTask(t, storage, "Вторая")
+
+			task := newBenchmarkTask(t, tt.id)
+			task.SetID(tt.id)
+			if err := storage.AddModel(task); model.IsValidationError(err) != tt.wantErr || !tt.wantErr && err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if next := addTask(t, storage, "Следующая"); next.GetID() != tt.wantNext {
+				t.Errorf("следующая задача получила ID %d, ожидался %d", next.GetID(), tt.wantNext)
+			}
+		})
+	}
+}
+
+// Повторяющиеся и некорректные ID в загруженных данных заменяются новыми,
+// а поднятая последовательность сразу сохраняется
+func TestLoadRepairsDuplicateIDs(t *testing.T) {
+	var tasks []*model.Task
+	for _, id := range []int{5, 5, 0} {
+		task := newBenchmarkTask(t, id)
+		task.SetID(id)
+		task.SetVersion(1)
+		tasks = append(tasks, task)
+	}
+	backend := NewMemoryBackend()
+	if err := backend.SaveTasks(tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, _ := NewStorageWithBackend(backend)
+	if got := taskIDs(storage.GetTasks()); len(got) != 3 || got[0] != 5 || got[1] != 6 || got[2] != 7 {
+		t.Errorf("после загрузки ID %v, ожидались [5 6 7]", got)
+	}
+	if seq, _ := backend.LoadSequence(taskSequence); seq != 7 {
+		t.Errorf("сохранена последовательность %d, ожидалось 7", seq)
+	}
+	if task := addTask(t, storage, "Новая"); task.GetID() != 8 {
+		t.Errorf("новая задача получила ID %d, ожидался 8", task.GetID())
+	}
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"task-manager/internal/model"
+	"time"
+)
+
+// taskIndex - вторичные индексы задач хранилища, чтобы поиск не сканировал весь слайс.
+// Индексы ссылаются на задачи по ID, а byID хранит позицию задачи в s.tasks.
+// Задачи в корзине есть только в byID и учитываются в deleted.
+// Индекс публикуется в снимке для чтения без блокировок (см. view.go), поэтому опубликованный
+// индекс не меняется: изменения делаются в копии (clone)
+type taskIndex struct {
+	byID       map[int]int
+	active     []int // ID задач вне корзины по возрастанию, для постраничных списков
+	byStatus   map[model.TaskStatus]map[int]struct{}
+	byPriority map[model.TaskPriority]map[int]struct{}
+	byDue      []dueEntry // задачи со сроком по возрастанию срока, затем ID
+	deleted    int
+}
+
+// dueEntry - запись индекса сроков
+type dueEntry struct {
+	due time.Time
+	id  int
+}
+
+// before задаёт порядок записей индекса сроков
+func (e dueEntry) before(other dueEntry) bool {
+	if !e.due.Equal(other.due) {
+		return e.due.Before(other.due)
+	}
+	return e.id < other.id
+}
+
+// newTaskIndex строит индексы по задачам целиком
+func newTaskIndex(tasks []*model.Task) *taskIndex {
+	x := &taskIndex{
+		byID:       make(map[int]int, len(tasks)),
+		byStatus:   make(map[model.TaskStatus]map[int]struct{}),
+		byPriority: make(map[model.TaskPriority]map[int]struct{}),
+	}
+	for i, task := range tasks {
+		x.index(task, i)
+	}
+	x.active = activeIDs(tasks, (*model.Task).GetID, (*model.Task).IsDeleted)
+	return x
+}
+
+// clone возвращает независимую копию индекса
+func (x *taskIndex) clone() *taskIndex {
+	return &taskIndex{
+		byID:       maps.Clone(x.byID),
+		byStatus:   cloneSets(x.byStatus),
+		byPriority: cloneSets(x.byPriority),
+		active:     slices.Clone(x.active),
+		byDue:      slices.Clone(x.byDue),
+		deleted:    x.deleted,
+	}
+}
+
+// add добавляет задачу, стоящую на позиции pos
+func (x *taskIndex) add(task *model.Task, pos int) {
+	x.index(task, pos)
+	if !task.IsDeleted() {
+		x.active = insertID(x.active, task.GetID())
+	}
+}
+
+// index добавляет задачу во все индексы, кроме порядка active
+func (x *taskIndex) index(task *model.Task, pos int) {
+	id := task.GetID()
+	x.byID[id] = pos
+	if task.IsDeleted() {
+		x.deleted++
+		return
+	}
+	addToSet(x.byStatus, task.GetStatus(), id)
+	addToSet(x.byPriority, task.GetPriority(), id)
+
+	if due := task.GetDueDate(); due != nil {
+		entry := dueEntry{due: *due, id: id}
+		i := sort.Search(len(x.byDue), func(i int) bool { return !x.byDue[i].before(entry) })
+		x.byDue = slices.Insert(x.byDue, i, entry)
+	}
+}
+
+// remove убирает задачу из индексов; task должна быть той же версией, что была добавлена
+func (x *taskIndex) remove(task *model.Task) {
+	id := task.GetID()
+	delete(x.byID, id)
+	if task.IsDeleted() {
+		x.deleted--
+		return
+	}
+	x.active = removeID(x.active, id)
+	removeFromSet(x.byStatus, task.GetStatus(), id)
+	removeFromSet(x.byPriority, task.GetPriority(), id)
+
+	if due := task.GetDueDate(); due != nil {
+		entry := dueEntry{due: *due, id: id}
+		i := sort.Search(len(x.byDue), func(i int) bool { return !x.byDue[i].before(entry) })
+		if i < len(x.byDue) && x.byDue[i] == entry {
+			x.byDue = slices.Delete(x.byDue, i, i+1)
+		}
+	}
+}
+
+// positions возвращает позиции задач из множества ids в порядке хранения
+func (x *taskIndex) positions(ids map[int]struct{}) []int {
+	result := make([]int, 0, len(ids))
+	for id := range ids {
+		result = append(result, x.byID[id])
+	}
+	sort.Ints(result)
+	return result
+}
+
+// dueBetween возвращает позиции задач со сроком в [from, to] по возрастанию срока
+func (x *taskIndex) dueBetween(from, to time.Time) []int {
+	start := sort.Search(len(x.byDue), func(i int) bool { return !x.byDue[i].due.Before(from) })
+	var result []int
+	for _, entry := range x.byDue[start:] {
+		if entry.due.After(to) {
+			break
+		}
+		result = append(result, x.byID[entry.id])
+	}
+	return result
+}
+
+// addToSet добавляет id в множество ключа key
+func addToSet[K comparable](sets map[K]map[int]struct{}, key K, id int) {
+	set, ok := sets[key]
+	if !ok {
+		set = make(map[int]struct{})
+		sets[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+// cloneSets копирует множества вместе с вложенными
+func cloneSets[K comparable](sets map[K]map[int]struct{}) map[K]map[int]struct{} {
+	result := make(map[K]map[int]struct{}, len(sets))
+	for key, set := range sets {
+		result[key] = maps.Clone(set)
+	}
+	return result
+}
+
+// activeIDs возвращает ID записей вне корзины по возрастанию
+func activeIDs[T any](items []T, id func(T) int, deleted func(T) bool) []int {
+	ids := make([]int, 0, len(items))
+	for _, item := range items {
+		if !deleted(item) {
+			ids = append(ids, id(item))
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// insertID вставляет id в упорядоченный слайс; новые записи получают наибольший ID,
+// поэтому обычно это дописывание в конец
+func insertID(ids []int, id int) []int {
+	if i, found := slices.BinarySearch(ids, id); !found {
+		ids = slices.Insert(ids, i, id)
+	}
+	return ids
+}
+
+// removeID убирает id из упорядоченного слайса
+func removeID(ids []int, id int) []int {
+	if i, found := slices.BinarySearch(ids, id); found {
+		ids = slices.Delete(ids, i, i+1)
+	}
+	return ids
+}
+
+// removeFromSet убирает id из множества ключа key
+func removeFromSet[K comparable](sets map[K]map[int]struct{}, key K, id int) {
+	if set, ok := sets[key]; ok {
+		delete(set, id)
+		if len(set) == 0 {
+			delete(sets, key)
+		}
+	}
+}
+
+// noteIndex - индекс заметок хранилища по ID (позиция в s.notes) и число заметок в корзине.
+// Как и taskIndex, опубликованный индекс не меняется
+type noteIndex struct {
+	byID    map[int]int
+	active  []int // ID заметок вне корзины по возрастанию
+	deleted int
+}
+
+// newNoteIndex строит индекс по заметкам целиком
+func newNoteIndex(notes []*model.Note) *noteIndex {
+	x := &noteIndex{byID: make(map[int]int, len(notes))}
+	for i, note := range notes {
+		x.byID[note.GetID()] = i
+		if note.IsDeleted() {
+			x.deleted++
+		}
+	}
+	x.active = activeIDs(notes, (*model.Note).GetID, (*model.Note).IsDeleted)
+	return x
+}
+
+// clone возвращает независимую копию индекса
+func (x *noteIndex) clone() *noteIndex {
+	return &noteIndex{byID: maps.Clone(x.byID), active: slices.Clone(x.active), deleted: x.deleted}
+}
+
+// add добавляет заметку, стоящую на позиции pos
+func (x *noteIndex) add(note *model.Note, pos int) {
+	x.byID[note.GetID()] = pos
+	if note.IsDeleted() {
+		x.deleted++
+	} else {
+		x.active = insertID(x.active, note.GetID())
+	}
+}
+
+// remove убирает заметку из индекса
+func (x *noteIndex) remove(note *model.Note) {
+	delete(x.byID, note.GetID())
+	if note.IsDeleted() {
+		x.deleted--
+	} else {
+		x.active = removeID(x.active, note.GetID())
+	}
+}
+
+// ========== Изменения коллекций с поддержкой индексов ==========
+// Все методы вызываются под блокировкой s.mu. Опубликованные снимки (см. view.go) разделяют массив
+// с s.tasks и s.notes и индексы, поэтому ни записи массива, ни опубликованные индексы на месте
+// не меняются: замена работает с копией слайса, а вставка и замена - с копией индекса.
+// Вставка и замена не публикуют снимок: изменение сначала сохраняется в бэкенд, и только потом
+// вызывающий публикует его через storeViewLocked, а при ошибке откатывает возвращённой функцией undo
+
+// reindexLocked перестраивает индексы после замены коллекций целиком
+// (загрузка, восстановление снимка, транзакция, внешние правки)
+func (s *Storage) reindexLocked() {
+	s.taskIndex = newTaskIndex(s.tasks)
+	s.noteIndex = newNoteIndex(s.notes)
+	s.storeViewLocked()
+}
+
+// insertTaskLocked добавляет задачу в конец коллекции
+func (s *Storage) insertTaskLocked(task *model.Task) (undo func()) {
+	prev, prevIndex := s.tasks, s.taskIndex
+	s.tasks = append(s.tasks, task)
+	s.taskIndex = prevIndex.clone()
+	s.taskIndex.add(task, len(s.tasks)-1)
+	return func() {
+		s.tasks, s.taskIndex = prev, prevIndex
+	}
+}
+
+// replaceTaskLocked заменяет задачу на позиции i
+func (s *Storage) replaceTaskLocked(i int, task *model.Task) (undo func()) {
+	prev, prevIndex := s.tasks, s.taskIndex
+	s.tasks = slices.Clone(prev)
+	s.tasks[i] = task
+	s.taskIndex = prevIndex.clone()
+	s.taskIndex.remove(prev[i])
+	s.taskIndex.add(task, i)
+	return func() {
+		s.tasks, s.taskIndex = prev, prevIndex
+	}
+}
+
+// insertNoteLocked добавляет заметку в конец коллекции
+func (s *Storage) insertNoteLocked(note *model.Note) (undo func()) {
+	prev, prevIndex := s.notes, s.noteIndex
+	s.notes = append(s.notes, note)
+	s.noteIndex = prevIndex.clone()
+	s.noteIndex.add(note, len(s.notes)-1)
+	return func() {
+		s.notes, s.noteIndex = prev, prevIndex
+	}
+}
+
+// replaceNoteLocked заменяет заметку на позиции i
+func (s *Storage) replaceNoteLocked(i int, note *model.Note) (undo func()) {
+	prev, prevIndex := s.notes, s.noteIndex
+	s.notes = slices.Clone(prev)
+	s.notes[i] = note
+	s.noteIndex = prevIndex.clone()
+	s.noteIndex.remove(prev[i])
+	s.noteIndex.add(note, i)
+	return func() {
+		s.notes, s.noteIndex = prev, prevIndex
+	}
+}
+
+// ========== Поиск по индексам ==========
+
+// GetTasksByStatus возвращает копии задач с указанным статусом в порядке хранения.
+// Читает последний опубликованный снимок и не ждёт писателей
+func (s *Storage) GetTasksByStatus(status model.TaskStatus) []*model.Task {
+	view := s.loadView()
+	return view.tasksAt(view.taskIndex.positions(view.taskIndex.byStatus[status]))
+}
+
+// GetTasksByPriority возвращает копии задач с указанным приоритетом в порядке хранения.
+// Читает последний опубликованный снимок и не ждёт писателей
+func (s *Storage) GetTasksByPriority(priority model.TaskPriority) []*model.Task {
+	view := s.loadView()
+	return view.tasksAt(view.taskIndex.positions(view.taskIndex.byPriority[priority]))
+}
+
+// GetTasksDueBetween возвращает копии задач со сроком в интервале [from, to] по возрастанию срока.
+// Например, задачи на ближайшую неделю: GetTasksDueBetween(now, now.AddDate(0, 0, 7))
+func (s *Storage) GetTasksDueBetween(from, to time.Time) []*model.Task {
+	view := s.loadView()
+	return view.tasksAt(view.taskIndex.dueBetween(from, to))
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// indexBase - начало отсчёта сроков задач в тестах индексов
+var indexBase = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// dueIn возвращает срок через days дней после indexBase
+func dueIn(days int) *time.Time {
+	due := indexBase.AddDate(0, 0, days)
+	return &due
+}
+
+// addIndexedTask добавляет задачу с приоритетом priority и сроком due
+func addIndexedTask(t *testing.T, s *Storage, title string, priority model.TaskPriority, due *time.Time) *model.Task {
+	t.Helper()
+	task, err := model.NewTask(title, "Описание задачи", priority, due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddModel(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// editTask читает задачу id, меняет её через edit и сохраняет
+func editTask(t *testing.T, s *Storage, id int, edit func(task *model.Task) error) {
+	t.Helper()
+	task, err := s.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := edit(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTask(task); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// taskIDs возвращает ID задач по порядку
+func taskIDs(tasks []*model.Task) []int {
+	var ids []int
+	for _, task := range tasks {
+		ids = append(ids, task.GetID())
+	}
+	return ids
+}
+
+// Задача переходит между выборками по статусу и приоритету вместе со своими изменениями
+func TestIndexFollowsStatusAndPriority(t *testing.T) {
+	storage := NewMemoryStorage()
+	addIndexedTask(t, storage, "Первая", model.PriorityLow, nil)
+	addIndexedTask(t, storage, "Вторая", model.PriorityHigh, nil)
+	addIndexedTask(t, storage, "Третья", model.PriorityLow, nil)
+
+	editTask(t, storage, 1, func(task *model.Task) error { return task.SetStatus(model.StatusDone) })
+	editTask(t, storage, 3, func(task *model.Task) error { return task.SetPriority(model.PriorityHigh) })
+
+	if got := taskIDs(storage.GetTasksByStatus(model.StatusTodo)); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("todo: %v, ожидалось [2 3]", got)
+	}
+	if got := storage.GetTasksByStatus(model.StatusDone); len(got) != 1 || got[0].GetTitle() != "Первая" {
+		t.Errorf("done: %v, ожидалась задача 1", taskIDs(got))
+	}
+	if got := storage.GetTasksByPriority(model.PriorityLow); len(got) != 1 || got[0].GetID() != 1 {
+		t.Errorf("low: %v, ожидалась задача 1", taskIDs(got))
+	}
+	// Порядок выборки - порядок хранения, а не порядок попадания в индекс
+	if got := taskIDs(storage.GetTasksByPriority(model.PriorityHigh)); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("high: %v, ожидалось [2 3]", got)
+	}
+}
+
+// Интервал сроков включает обе границы, упорядочен по сроку и не содержит задач без срока
+func TestIndexDueBetween(t *testing.T) {
+	storage := NewMemoryStorage()
+	addIndexedTask(t, storage, "Через пять дней", model.PriorityMedium, dueIn(5))
+	addIndexedTask(t, storage, "Без срока", model.PriorityMedium, nil)
+	addIndexedTask(t, storage, "Через два дня", model.PriorityMedium, dueIn(2))
+	addIndexedTask(t, storage, "Через десять дней", model.PriorityMedium, dueIn(10))
+
+	if got := taskIDs(storage.GetTasksDueBetween(*dueIn(2), *dueIn(5))); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("[2, 5]: %v, ожидалось [3 1]", got)
+	}
+
+	// Перенос срока и снятие срока перестраивают индекс
+	editTask(t, storage, 4, func(task *model.Task) error { task.SetDueDate(dueIn(1)); return nil })
+	editTask(t, storage, 3, func(task *model.Task) error { task.SetDueDate(nil); return nil })
+	editTask(t, storage, 2, func(task *model.Task) error { task.SetDueDate(dueIn(3)); return nil })
+	if got := taskIDs(storage.GetTasksDueBetween(*dueIn(0), *dueIn(30))); !reflect.DeepEqual(got, []int{4, 2, 1}) {
+		t.Errorf("после переносов: %v, ожидалось [4 2 1]", got)
+	}
+	if got := storage.GetTasksDueBetween(*dueIn(6), *dueIn(9)); len(got) != 0 {
+		t.Errorf("пустой интервал вернул %v", taskIDs(got))
+	}
+}
+
+// Задачи в корзине не попадают в выборки, а восстановленные возвращаются
+func TestIndexSkipsTrash(t *testing.T) {
+	storage := NewMemoryStorage()
+	addIndexedTask(t, storage, "Первая", model.PriorityHigh, dueIn(1))
+	addIndexedTask(t, storage, "Вторая", model.PriorityHigh, dueIn(2))
+	if err := storage.DeleteTask(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := taskIDs(storage.GetTasksByPriority(model.PriorityHigh)); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("по приоритету с задачей в корзине: %v", got)
+	}
+	if got := taskIDs(storage.GetTasksDueBetween(*dueIn(0), *dueIn(5))); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("по сроку с задачей в корзине: %v", got)
+	}
+
+	if err := storage.RestoreTask(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := taskIDs(storage.GetTasksDueBetween(*dueIn(0), *dueIn(5))); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("после восстановления: %v", got)
+	}
+}
+
+// После окончательного удаления задачи сдвигаются в хранилище, и индекс не должен указывать на чужие позиции
+func TestIndexAfterPurge(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, title := range []string{"Первая", "Вторая", "Третья", "Четвёртая"} {
+		addIndexedTask(t, storage, title, model.PriorityLow, nil)
+	}
+	for _, id := range []int{1, 2} {
+		if err := storage.DeleteTask(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := storage.PurgeDeleted(0); err != nil {
+		t.Fatal(err)
+	}
+	addIndexedTask(t, storage, "Пятая", model.PriorityLow, nil)
+
+	var titles []string
+	for _, task := range storage.GetTasksByPriority(model.PriorityLow) {
+		titles = append(titles, task.GetTitle())
+	}
+	if want := []string{"Третья", "Четвёртая", "Пятая"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("после очистки корзины %v, ожидалось %v", titles, want)
+	}
+}
+
+// Индекс меняется только вместе с успешно сохранёнными данными
+func TestIndexUnchangedByFailedWrites(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	storage, _ := NewStorageWithBackend(backend)
+	addIndexedTask(t, storage, "Задача", model.PriorityLow, dueIn(1))
+
+	errAbort := errors.New("отмена")
+	err := storage.Transaction(func(tx *Tx) error {
+		task, err := tx.GetTask(1)
+		if err != nil {
+			return err
+		}
+		if err := task.SetPriority(model.PriorityHigh); err != nil {
+			return err
+		}
+		if err := tx.UpdateTask(task); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ожидалась ошибка транзакции, получено %v", err)
+	}
+
+	backend.fail = true
+	task, _ := storage.GetTask(1)
+	task.SetDueDate(nil)
+	if err := storage.UpdateTask(task); !errors.Is(err, errBackendFailed) {
+		t.Fatalf("ожидалась ошибка бэкенда, получено %v", err)
+	}
+
+	if got := taskIDs(storage.GetTasksByPriority(model.PriorityLow)); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("приоритет после отката: %v", got)
+	}
+	if got := taskIDs(storage.GetTasksDueBetween(*dueIn(0), *dueIn(2))); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("срок после неудачного сохранения: %v", got)
+	}
+}
+
+// Выборки возвращают копии: их изменение не меняет ни данные, ни индекс
+func TestIndexReturnsCopies(t *testing.T) {
+	storage := NewMemoryStorage()
+	addIndexedTask(t, storage, "Задача", model.PriorityLow, nil)
+
+	copies := storage.GetTasksByPriority(model.PriorityLow)
+	if err := copies[0].SetPriority(model.PriorityHigh); err != nil {
+		t.Fatal(err)
+	}
+	if got := storage.GetTasksByPriority(model.PriorityHigh); len(got) != 0 {
+		t.Errorf("изменение копии попало в индекс: %v", taskIDs(got))
+	}
+	if task, _ := storage.GetTask(1); task.GetPriority() != model.PriorityLow {
+		t.Errorf("изменение копии попало в хранилище: %s", task.GetPriority())
+	}
+}
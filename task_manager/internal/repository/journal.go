@@ -0,0 +1,420 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"task-manager/internal/model"
+	"time"
+)
+
+// ChangeOp - вид изменения задачи или заметки
+type ChangeOp string
+
+const (
+	ChangeCreated ChangeOp = "created"
+	ChangeUpdated ChangeOp = "updated"
+	ChangeDeleted ChangeOp = "deleted"
+
+	// Только для событий подписки (см. Subscribe): возврат из корзины и окончательное удаление.
+	// В журнал перемещение в корзину и обратно пишется как updated
+	ChangeRestored ChangeOp = "restored"
+	ChangePurged   ChangeOp = "purged"
+)
+
+// Пороги компактизации журнала по умолчанию
+const (
+	DefaultJournalMaxSize int64         = 1 << 20
+	DefaultJournalMaxAge  time.Duration = 10 * time.Minute
+)
+
+// IncrementalBackend - бэкенд, который умеет дописывать отдельные изменения
+// вместо полной перезаписи коллекции
+type IncrementalBackend interface {
+	Backend
+	// AppendTask дописывает одно изменение задачи
+	AppendTask(op ChangeOp, task *model.Task) error
+	// AppendNote дописывает одно изменение заметки
+	AppendNote(op ChangeOp, note *model.Note) error
+	// TasksNeedCompaction сообщает, что журнал задач пора свернуть в снимок через SaveTasks
+	TasksNeedCompaction() bool
+	// NotesNeedCompaction сообщает, что журнал заметок пора свернуть в снимок через SaveNotes
+	NotesNeedCompaction() bool
+}
+
+// JournalOptions задаёт пороги компактизации журнала; нулевые значения заменяются значениями по умолчанию
+type JournalOptions struct {
+	MaxSize int64
+	MaxAge  time.Duration
+}
+
+// journalRecord - одна строка журнала (формат JSON Lines)
+type journalRecord struct {
+	Op   ChangeOp    `json:"op"`
+	ID   int         `json:"id"`
+	Time time.Time   `json:"time"`
+	Task *taskRecord `json:"task,omitempty"`
+	Note *noteRecord `json:"note,omitempty"`
+}
+
+// journalFile - журнал изменений одной коллекции
+type journalFile struct {
+	path   string
+	size   int64
+	since  time.Time   // время первой записи после последней компактизации
+	cipher *fileCipher // nil - строки журнала не шифруются
+}
+
+// JournalBackend хранит снимок коллекций во вложенном бэкенде и
+// дописывает изменения в журналы, пока они не будут свёрнуты в новый снимок
+type JournalBackend struct {
+	inner   Backend
+	tasks   journalFile
+	notes   journalFile
+	options JournalOptions
+	mu      sync.Mutex
+}
+
+// Проверка, что JournalBackend реализует IncrementalBackend
+var _ IncrementalBackend = (*JournalBackend)(nil)
+
+// NewJournalBackend создаёт журналируемый бэкенд поверх inner,
+// журналы задач и заметок пишутся в tasksJournal и notesJournal
+func NewJournalBackend(inner Backend, tasksJournal, notesJournal string, options JournalOptions) *JournalBackend {
+	if options.MaxSize <= 0 {
+		options.MaxSize = DefaultJournalMaxSize
+	}
+	if options.MaxAge <= 0 {
+		options.MaxAge = DefaultJournalMaxAge
+	}
+
+	return &JournalBackend{
+		inner:   inner,
+		tasks:   journalFile{path: tasksJournal},
+		notes:   journalFile{path: notesJournal},
+		options: options,
+	}
+}
+
+// setCipher включает построчное шифрование журналов
+func (b *JournalBackend) setCipher(c *fileCipher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tasks.cipher = c
+	b.notes.cipher = c
+}
+
+// ========== Задачи ==========
+
+// LoadTasks загружает снимок задач и применяет к нему журнал
+func (b *JournalBackend) LoadTasks(report *LoadReport) ([]*model.Task, error) {
+	tasks, err := b.inner.LoadTasks(report)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	records, err := b.tasks.read(report)
+	if err != nil {
+		return tasks, err
+	}
+
+	tasks = replayJournal(tasks, records, func(entry journalEntry) (*model.Task, bool) {
+		if entry.record.Task == nil {
+			return nil, false
+		}
+		task, repairs, err := entry.record.Task.toTask()
+		if err != nil {
+			report.add(b.tasks.path, entry.line, LoadSkipped, err.Error())
+			return nil, false
+		}
+		for _, repair := range repairs {
+			report.add(b.tasks.path, entry.line, LoadRepaired, repair)
+		}
+		return task, true
+	})
+
+	return tasks, nil
+}
+
+// SaveTasks записывает снимок задач и очищает журнал задач
+func (b *JournalBackend) SaveTasks(tasks []*model.Task) error {
+	if err := b.inner.SaveTasks(tasks); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tasks.reset()
+}
+
+// AppendTask дописывает изменение задачи в журнал
+func (b *JournalBackend) AppendTask(op ChangeOp, task *model.Task) error {
+	rec := journalRecord{Op: op, ID: task.GetID(), Time: time.Now()}
+	if op != ChangeDeleted {
+		record := newTaskRecord(task)
+		rec.Task = &record
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tasks.append(rec)
+}
+
+// TasksNeedCompaction сообщает, превысил ли журнал задач порог размера или возраста
+func (b *JournalBackend) TasksNeedCompaction() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tasks.exceeds(b.options)
+}
+
+// ========== Заметки ==========
+
+// LoadNotes загружает снимок заметок и применяет к нему журнал
+func (b *JournalBackend) LoadNotes(report *LoadReport) ([]*model.Note, error) {
+	notes, err := b.inner.LoadNotes(report)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	records, err := b.notes.read(report)
+	if err != nil {
+		return notes, err
+	}
+
+	notes = replayJournal(notes, records, func(entry journalEntry) (*model.Note, bool) {
+		if entry.record.Note == nil {
+			return nil, false
+		}
+		note, repairs := entry.record.Note.toNote()
+		for _, repair := range repairs {
+			report.add(b.notes.path, entry.line, LoadRepaired, repair)
+		}
+		return note, true
+	})
+
+	return notes, nil
+}
+
+// SaveNotes записывает снимок заметок и очищает журнал заметок
+func (b *JournalBackend) SaveNotes(notes []*model.Note) error {
+	if err := b.inner.SaveNotes(notes); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notes.reset()
+}
+
+// AppendNote дописывает изменение заметки в журнал
+func (b *JournalBackend) AppendNote(op ChangeOp, note *model.Note) error {
+	rec := journalRecord{Op: op, ID: note.GetID(), Time: time.Now()}
+	if op != ChangeDeleted {
+		record := newNoteRecord(note)
+		rec.Note = &record
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notes.append(rec)
+}
+
+// NotesNeedCompaction сообщает, превысил ли журнал заметок порог размера или возраста
+func (b *JournalBackend) NotesNeedCompaction() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notes.exceeds(b.options)
+}
+
+// ========== Обе коллекции ==========
+
+// SaveCollections записывает снимок обеих коллекций одной записью вложенного бэкенда
+// и очищает оба журнала
+func (b *JournalBackend) SaveCollections(tasks []*model.Task, notes []*model.Note) error {
+	if err := saveCollections(b.inner, tasks, notes); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.tasks.reset(); err != nil {
+		return err
+	}
+	return b.notes.reset()
+}
+
+// ========== Последовательности ID ==========
+
+// LoadSequence делегирует чтение последовательности вложенному бэкенду
+func (b *JournalBackend) LoadSequence(name string) (int, error) {
+	return b.inner.LoadSequence(name)
+}
+
+// SaveSequence делегирует запись последовательности вложенному бэкенду
+func (b *JournalBackend) SaveSequence(name string, value int) error {
+	return b.inner.SaveSequence(name, value)
+}
+
+// ========== Файл журнала ==========
+
+// journalEntry - прочитанная запись журнала вместе с номером строки
+type journalEntry struct {
+	record journalRecord
+	line   int
+}
+
+// read читает все записи журнала и запоминает его размер и возраст.
+// Повреждённые строки (например, оборванная при сбое последняя) пропускаются и попадают в report
+func (j *journalFile) read(report *LoadReport) ([]journalEntry, error) {
+	// Журнал мог быть заменён (например, при восстановлении снимка)
+	j.size = 0
+	j.since = time.Time{}
+
+	file, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		data, err := j.cipher.openLine(j.path, scanner.Bytes())
+		if err != nil {
+			report.add(j.path, line, LoadSkipped, fmt.Sprintf("повреждённая запись журнала: %v", err))
+			continue
+		}
+
+		var rec journalRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			report.add(j.path, line, LoadSkipped, fmt.Sprintf("повреждённая запись журнала: %v", err))
+			continue
+		}
+		if j.since.IsZero() {
+			j.since = rec.Time
+		}
+		records = append(records, journalEntry{record: rec, line: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+
+	if info, err := file.Stat(); err == nil {
+		j.size = info.Size()
+	}
+	return records, nil
+}
+
+// append дописывает запись в конец журнала и сбрасывает её на диск
+func (j *journalFile) append(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if data, err = j.cipher.sealLine(data); err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	if j.since.IsZero() {
+		j.since = rec.Time
+	}
+	j.size += int64(len(data))
+	return nil
+}
+
+// reset очищает журнал после того, как его содержимое попало в снимок
+func (j *journalFile) reset() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	j.size = 0
+	j.since = time.Time{}
+	return nil
+}
+
+// exceeds сообщает, превысил ли журнал пороги компактизации
+func (j *journalFile) exceeds(options JournalOptions) bool {
+	if j.size == 0 {
+		return false
+	}
+	return j.size >= options.MaxSize || time.Since(j.since) >= options.MaxAge
+}
+
+// replayJournal применяет записи журнала к снимку items. Позиции записей ищутся по карте
+// ID → индекс, построенной один раз, а удалённые записи вырезаются одним проходом в конце,
+// так что загрузка остаётся линейной по размеру снимка и журнала.
+// decode разбирает запись журнала и возвращает false, если её нужно пропустить
+func replayJournal[T interface{ GetID() int }](items []T, records []journalEntry, decode func(journalEntry) (T, bool)) []T {
+	pos := make(map[int]int, len(items))
+	for i, item := range items {
+		if _, ok := pos[item.GetID()]; !ok {
+			pos[item.GetID()] = i
+		}
+	}
+
+	removed := make(map[int]bool)
+	for _, entry := range records {
+		id := entry.record.ID
+		i, found := pos[id]
+		if entry.record.Op == ChangeDeleted {
+			if found {
+				removed[i] = true
+				delete(pos, id)
+			}
+			continue
+		}
+		item, ok := decode(entry)
+		if !ok {
+			continue
+		}
+		if found {
+			items[i] = item
+		} else {
+			pos[id] = len(items)
+			items = append(items, item)
+		}
+	}
+
+	if len(removed) == 0 {
+		return items
+	}
+	kept := items[:0]
+	for i, item := range items {
+		if !removed[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+func TestReplayJournal(t *testing.T) {
+	update := func(id int) journalEntry {
+		return journalEntry{record: journalRecord{Op: ChangeUpdated, ID: id}}
+	}
+	create := func(id int) journalEntry {
+		return journalEntry{record: journalRecord{Op: ChangeCreated, ID: id}}
+	}
+	remove := func(id int) journalEntry {
+		return journalEntry{record: journalRecord{Op: ChangeDeleted, ID: id}}
+	}
+
+	tests := []struct {
+		name     string
+		snapshot []int
+		records  []journalEntry
+		want     []int
+		replaced []int
+	}{
+		{"пустой журнал", []int{1, 2, 3}, nil, []int{1, 2, 3}, nil},
+		{"обновление на месте", []int{1, 2, 3}, []journalEntry{update(2)}, []int{1, 2, 3}, []int{2}},
+		{"добавление в конец", []int{1, 2}, []journalEntry{create(3), create(4)}, []int{1, 2, 3, 4}, []int{3, 4}},
+		{"удаление сохраняет порядок", []int{1, 2, 3, 4}, []journalEntry{remove(1), remove(3)}, []int{2, 4}, nil},
+		{"удаление отсутствующей", []int{1, 2}, []journalEntry{remove(5)}, []int{1, 2}, nil},
+		{"удаление добавленной", []int{1}, []journalEntry{create(2), create(3), remove(2)}, []int{1, 3}, []int{3}},
+		{"обновление после удаления соседа", []int{1, 2, 3}, []journalEntry{remove(1), update(3)}, []int{2, 3}, []int{3}},
+		{"повторное создание после удаления", []int{1, 2}, []journalEntry{remove(1), create(1)}, []int{2, 1}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := make([]*model.Task, len(tt.snapshot))
+			for i, id := range tt.snapshot {
+				tasks[i] = newBenchmarkTask(t, id)
+				tasks[i].SetID(id)
+			}
+
+			replayed := make(map[*model.Task]bool)
+			tasks = replayJournal(tasks, tt.records, func(entry journalEntry) (*model.Task, bool) {
+				task := newBenchmarkTask(t, entry.record.ID)
+				task.SetID(entry.record.ID)
+				replayed[task] = true
+				return task, true
+			})
+
+			var got, replaced []int
+			for _, task := range tasks {
+				got = append(got, task.GetID())
+				if replayed[task] {
+					replaced = append(replaced, task.GetID())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ID после применения журнала %v, ожидалось %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(replaced, tt.replaced) {
+				t.Errorf("из журнала взяты %v, ожидалось %v", replaced, tt.replaced)
+			}
+		})
+	}
+}
+
+// Записи, которые decode отбрасывает, не меняют снимок
+func TestReplayJournalSkipsUndecodable(t *testing.T) {
+	task := newBenchmarkTask(t, 1)
+	task.SetID(1)
+	records := []journalEntry{
+		{record: journalRecord{Op: ChangeUpdated, ID: 1}},
+		{record: journalRecord{Op: ChangeCreated, ID: 2}},
+	}
+
+	tasks := replayJournal([]*model.Task{task}, records, func(journalEntry) (*model.Task, bool) {
+		return nil, false
+	})
+	if len(tasks) != 1 || tasks[0] != task {
+		t.Fatalf("снимок изменился: %v", tasks)
+	}
+}
+
+// Синхронные дописывания в журнал не должны теряться при сжатии журнала в SaveAll,
+// которое записывает снимок коллекций без блокировки s.mu
+func TestJournalAppendsSurviveConcurrentSaveAll(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile, WithJournal(JournalOptions{}))
+
+	const added = 200
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := storage.SaveAll(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < added; i++ {
+		if err := storage.AddModel(newBenchmarkTask(t, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// Данные должны быть на диске уже после AddModel, до финального сохранения в Close:
+	// читатель только для чтения видит файлы, не дожидаясь владельца
+	reader, _ := openStorage(t, tasksFile, notesFile, WithJournal(JournalOptions{}), WithReadOnly())
+	if tasks, _ := reader.Count(); tasks != added {
+		t.Fatalf("на диске %d задач, ожидалось %d", tasks, added)
+	}
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrClosed возвращается при обращении к хранилищу после Close
+var ErrClosed = errors.New("хранилище закрыто")
+
+// Close сохраняет данные, останавливает фоновые горутины, закрывает каналы подписок
+// и освобождает каталог данных. Возвращает ошибку финального сохранения; после Close
+// изменения и чтения с ошибкой возвращают ErrClosed, а списки пусты.
+// Повторные и одновременные вызовы безопасны (например, из обработчика сигнала и defer):
+// закрытие выполняется один раз, остальные вызовы дожидаются его и возвращают тот же результат
+func (s *Storage) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.close()
+	})
+	return s.closeErr
+}
+
+// close выполняет закрытие хранилища
+func (s *Storage) close() error {
+	// Останавливаем наблюдение, снимки по расписанию и автосохранение, если они запущены
+	s.StopWatching()
+	s.stopSnapshots()
+	s.StopAutosave()
+
+	s.saveMu.Lock()
+	s.mu.Lock()
+	// Изменения, успевшие взять блокировку раньше, попадут в финальное сохранение, а более поздние
+	// получат ErrClosed
+	s.closed.Store(true)
+
+	var err error
+	if !s.readOnly {
+		if saveErr := s.saveLocked(); saveErr != nil {
+			err = fmt.Errorf("ошибка сохранения данных при закрытии: %w", saveErr)
+		}
+	}
+
+	// Освобождаем память; читатели снимка получают пустые коллекции
+	s.tasks = nil
+	s.notes = nil
+	s.reindexLocked()
+	locks := s.locks
+	s.locks = nil
+	s.mu.Unlock()
+	s.saveMu.Unlock()
+
+	close(s.done)
+	s.closeSubscribers()
+
+	// Освобождаем каталог данных для других процессов
+	if unlockErr := unlockDirs(locks); unlockErr != nil {
+		err = errors.Join(err, fmt.Errorf("ошибка снятия блокировки каталога данных: %w", unlockErr))
+	}
+	return err
+}
+
+// checkOpen возвращает ErrClosed, если хранилище закрыто.
+// Изменения проверяют его под блокировкой s.mu, чтобы не разминуться с Close
+func (s *Storage) checkOpen() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// closeSubscribers закрывает каналы всех подписок: циклы range по ним завершаются
+func (s *Storage) closeSubscribers() {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for sub := range s.changeSubs {
+		close(sub.ch)
+	}
+	s.changeSubs = nil
+	for ch := range s.reloadSubs {
+		close(ch)
+	}
+	s.reloadSubs = nil
+}
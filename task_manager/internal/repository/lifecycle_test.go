@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// closedStorage открывает хранилище в каталоге dir со снимками по расписанию, добавляет задачу,
+// задачу в корзине и заметку и закрывает его
+func closedStorage(t *testing.T, dir string) *Storage {
+	t.Helper()
+	storage, _, err := Open(filepath.Join(dir, "tasks"), filepath.Join(dir, "notes"),
+		WithSnapshots(SnapshotOptions{Interval: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTask(t, storage, "Задача")
+	addTask(t, storage, "В корзине")
+	if err := storage.DeleteTask(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.AddModel(model.NewNote("Заметка", "Содержимое", model.CategoryWork)); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+// Изменения после Close отклоняются с ErrClosed и не попадают в файлы
+func TestClosedStorageRejectsWrites(t *testing.T) {
+	dir := t.TempDir()
+	storage := closedStorage(t, dir)
+
+	task := newBenchmarkTask(t, 1)
+	task.SetID(1)
+	task.SetVersion(1)
+	writes := map[string]error{
+		"AddModel":    storage.AddModel(newBenchmarkTask(t, 0)),
+		"UpdateTask":  storage.UpdateTask(task),
+		"DeleteTask":  storage.DeleteTask(1),
+		"DeleteNote":  storage.DeleteNote(1),
+		"RestoreTask": storage.RestoreTask(2),
+		"Transaction": storage.Transaction(func(tx *Tx) error { return nil }),
+		"SaveAll":     storage.SaveAll(),
+	}
+	_, _, writes["PurgeDeleted"] = storage.PurgeDeleted(0)
+	for name, err := range writes {
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("%s: ожидалась ErrClosed, получено %v", name, err)
+		}
+	}
+
+	reopened, _, err := Open(filepath.Join(dir, "tasks"), filepath.Join(dir, "notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if tasks, notes := reopened.Count(); tasks != 1 || notes != 1 || len(reopened.GetDeletedTasks()) != 1 {
+		t.Errorf("после повторного открытия %d задач, %d заметок и %d задач в корзине, ожидалось по 1",
+			tasks, notes, len(reopened.GetDeletedTasks()))
+	}
+}
+
+// Чтения с ошибкой возвращают ErrClosed, а чтения без ошибки - пустые коллекции, а не данные
+// закрытого хранилища
+func TestClosedStorageReads(t *testing.T) {
+	storage := closedStorage(t, t.TempDir())
+
+	if _, err := storage.GetTask(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetTask: ожидалась ErrClosed, получено %v", err)
+	}
+	if _, err := storage.GetNote(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetNote: ожидалась ErrClosed, получено %v", err)
+	}
+	if _, err := storage.ListTasks(ListOptions{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("ListTasks: ожидалась ErrClosed, получено %v", err)
+	}
+	if _, err := storage.ListNotes(ListOptions{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("ListNotes: ожидалась ErrClosed, получено %v", err)
+	}
+
+	if tasks, notes := storage.Count(); tasks != 0 || notes != 0 {
+		t.Errorf("Count после Close: %d задач и %d заметок", tasks, notes)
+	}
+	if len(storage.GetTasks()) != 0 || len(storage.GetNotes()) != 0 || len(storage.GetDeletedTasks()) != 0 {
+		t.Error("после Close коллекции не пусты")
+	}
+	if got := storage.GetTasksByStatus(model.StatusTodo); len(got) != 0 {
+		t.Errorf("индекс после Close вернул %v", taskIDs(got))
+	}
+}
+
+// Фоновые задачи и снимки после Close не запускаются
+func TestClosedStorageBackground(t *testing.T) {
+	storage := closedStorage(t, t.TempDir())
+
+	if err := storage.StartAutosave(context.Background(), AutosaveOptions{Interval: time.Hour}); !errors.Is(err, ErrClosed) {
+		t.Errorf("StartAutosave: ожидалась ErrClosed, получено %v", err)
+	}
+	if err := storage.StartWatching(context.Background(), WatchOptions{Interval: time.Hour}); !errors.Is(err, ErrClosed) {
+		t.Errorf("StartWatching: ожидалась ErrClosed, получено %v", err)
+	}
+	if err := storage.StartSnapshots(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("StartSnapshots: ожидалась ErrClosed, получено %v", err)
+	}
+	if _, err := storage.Snapshot("test"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Snapshot: ожидалась ErrClosed, получено %v", err)
+	}
+	if _, err := storage.Restore("missing"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Restore: ожидалась ErrClosed, получено %v", err)
+	}
+}
+
+// Одновременные вызовы Close, как из обработчика сигнала и defer, завершаются без ошибки
+// и закрывают каналы подписок
+func TestCloseConcurrent(t *testing.T) {
+	storage := NewMemoryStorage()
+	addTask(t, storage, "Задача")
+	events := storage.Subscribe(context.Background(), SubscribeOptions{})
+	reloads := storage.SubscribeReloads(context.Background())
+
+	const callers = 8
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = storage.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("вызов %d: %v", i, err)
+		}
+	}
+	for range events {
+	}
+	for range reloads {
+	}
+}
+
+// Ошибка финального сохранения возвращается каждым вызовом Close; повторный Close не пытается
+// сохранить снова, даже если бэкенд восстановился
+func TestCloseSaveError(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	storage, _ := NewStorageWithBackend(backend)
+	addTask(t, storage, "Задача")
+	backend.fail = true
+
+	err := storage.Close()
+	if !errors.Is(err, errBackendFailed) {
+		t.Fatalf("ожидалась ошибка бэкенда, получено %v", err)
+	}
+	backend.fail = false
+	if again := storage.Close(); again != err {
+		t.Errorf("повторный Close вернул %v, ожидалось %v", again, err)
+	}
+	if err := storage.SaveAll(); !errors.Is(err, ErrClosed) {
+		t.Errorf("SaveAll после неудачного Close: ожидалась ErrClosed, получено %v", err)
+	}
+}
+
+// Close сохраняет данные и освобождает каталог: его можно открыть заново
+func TestCloseReleasesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	tasksFile, notesFile := filepath.Join(dir, "tasks"), filepath.Join(dir, "notes")
+
+	storage, _, err := Open(tasksFile, notesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTask(t, storage, "Задача")
+	if _, _, err := Open(tasksFile, notesFile); err == nil {
+		t.Fatal("каталог открыт вторым хранилищем, пока первое не закрыто")
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, _, err := Open(tasksFile, notesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if task, err := reopened.GetTask(1); err != nil || task.GetTitle() != "Задача" {
+		t.Errorf("после повторного открытия: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lockFileName - имя файла блокировки в каталоге данных
+const lockFileName = ".lock"
+
+// ErrReadOnly возвращается при попытке изменить хранилище, открытое только для чтения
+var ErrReadOnly = errors.New("хранилище открыто только для чтения")
+
+// LockError сообщает, что каталог данных уже занят другим процессом
+type LockError struct {
+	Dir string
+	PID int // 0, если PID владельца прочитать не удалось
+}
+
+// Error возвращает текст ошибки с PID процесса-владельца
+func (e *LockError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("каталог данных %s заблокирован другим процессом", e.Dir)
+	}
+	return fmt.Sprintf("каталог данных %s заблокирован процессом с PID %d", e.Dir, e.PID)
+}
+
+// dirLock - удерживаемая рекомендательная блокировка каталога данных
+type dirLock struct {
+	dir  string
+	file *os.File
+}
+
+// lockDirs блокирует каталоги, в которых лежат указанные файлы; каждый каталог блокируется один раз
+func lockDirs(paths ...string) ([]*dirLock, error) {
+	var locks []*dirLock
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		dir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			unlockDirs(locks)
+			return nil, err
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		lock, err := lockDir(dir)
+		if err != nil {
+			unlockDirs(locks)
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+
+	return locks, nil
+}
+
+// unlockDirs снимает блокировки в обратном порядке
+func unlockDirs(locks []*dirLock) error {
+	var firstErr error
+	for i := len(locks) - 1; i >= 0; i-- {
+		if err := locks[i].unlock(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// readLockOwner читает PID владельца из файла блокировки
+func readLockOwner(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// writeLockOwner записывает PID текущего процесса в файл блокировки
+func writeLockOwner(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,58 @@
+//go:build !unix
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// lockDir создаёт файл .lock в каталоге эксклюзивно (O_EXCL).
+// На платформах без flock файл, оставшийся после аварийного завершения, нужно удалить вручную
+func lockDir(dir string) (*dirLock, error) {
+	path := filepath.Join(dir, lockFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, &LockError{Dir: dir, PID: readLockOwner(path)}
+		}
+		return nil, err
+	}
+
+	if err := writeLockOwner(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &dirLock{dir: dir, file: file}, nil
+}
+
+// unlock закрывает и удаляет файл блокировки
+func (l *dirLock) unlock() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(l.file.Name())
+}
+
+// rename переименовывает заблокированный каталог, не снимая блокировку. Блокировка здесь -
+// само существование файла .lock, поэтому файл закрывается на время переименования
+// (открытые файлы мешают переименовать каталог) и открывается заново на новом месте
+func (l *dirLock) rename(newDir string) error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(l.dir, newDir)
+	if renameErr == nil {
+		l.dir = newDir
+	}
+
+	file, err := os.OpenFile(filepath.Join(l.dir, lockFileName), os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Join(renameErr, err)
+	}
+	l.file = file
+	return renameErr
+}
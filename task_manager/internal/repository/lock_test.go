@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Второе открытие каталога данных получает *LockError с PID владельца, пока первое хранилище не закрыто
+func TestOpenLocksDataDir(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+
+	_, _, err := Open(tasksFile, notesFile)
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("ожидалась *LockError, получено %v", err)
+	}
+	if lockErr.PID != os.Getpid() || lockErr.Dir != filepath.Dir(tasksFile) {
+		t.Errorf("LockError{Dir: %s, PID: %d}, ожидался каталог %s и PID %d",
+			lockErr.Dir, lockErr.PID, filepath.Dir(tasksFile), os.Getpid())
+	}
+
+	closeStorage(t, storage)
+	openStorage(t, tasksFile, notesFile)
+}
+
+// Каталог заметок блокируется так же, как каталог задач
+func TestOpenLocksNotesDir(t *testing.T) {
+	tasksFile := filepath.Join(t.TempDir(), "tasks")
+	notesFile := filepath.Join(t.TempDir(), "notes")
+	openStorage(t, tasksFile, notesFile)
+
+	otherTasks := filepath.Join(t.TempDir(), "tasks")
+	var lockErr *LockError
+	if _, _, err := Open(otherTasks, notesFile); !errors.As(err, &lockErr) || lockErr.Dir != filepath.Dir(notesFile) {
+		t.Fatalf("ожидалась *LockError для каталога заметок, получено %v", err)
+	}
+	// Неудачное открытие не оставляет за собой блокировку каталога задач
+	openStorage(t, otherTasks, filepath.Join(t.TempDir(), "notes"))
+}
+
+// Хранилище только для чтения открывается рядом с владельцем, но не меняет данные
+func TestReadOnlyDoesNotLock(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "Задача")
+
+	reader, _ := openStorage(t, tasksFile, notesFile, WithReadOnly())
+	if tasks, _ := reader.Count(); tasks != 1 {
+		t.Errorf("читатель видит %d задач, ожидалась 1", tasks)
+	}
+	task, _ := reader.GetTask(1)
+	if err := reader.AddModel(task); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddModel: ожидалась ErrReadOnly, получено %v", err)
+	}
+	if err := reader.DeleteTask(1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("DeleteTask: ожидалась ErrReadOnly, получено %v", err)
+	}
+	if err := reader.SaveAll(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SaveAll: ожидалась ErrReadOnly, получено %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir берёт эксклюзивную блокировку flock на файл .lock в каталоге.
+// Блокировка снимается ядром и при аварийном завершении процесса
+func lockDir(dir string) (*dirLock, error) {
+	path := filepath.Join(dir, lockFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &LockError{Dir: dir, PID: readLockOwner(path)}
+		}
+		return nil, err
+	}
+
+	if err := writeLockOwner(file); err != nil {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+		return nil, err
+	}
+
+	return &dirLock{dir: dir, file: file}, nil
+}
+
+// unlock снимает блокировку; сам файл остаётся, чтобы не создавать гонку с другим процессом
+func (l *dirLock) unlock() error {
+	l.file.Truncate(0)
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// rename переименовывает заблокированный каталог, не снимая блокировку:
+// открытый файл блокировки переезжает вместе с каталогом
+func (l *dirLock) rename(newDir string) error {
+	if err := os.Rename(l.dir, newDir); err != nil {
+		return err
+	}
+	l.dir = newDir
+	return nil
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CurrentFormatVersion - версия формата JSON файлов, которую пишет текущая версия программы
+//
+// История версий:
+//
+//	1 - голый JSON массив записей без маркера версии
+//	2 - конверт {"version", "kind", "saved_at", "items"}
+//	3 - у каждой записи есть поле "version" для оптимистичных блокировок
+//	4 - необязательное поле "deleted_at" у записей в корзине
+const CurrentFormatVersion = 4
+
+// Виды коллекций в конверте
+const (
+	kindTasks = "tasks"
+	kindNotes = "notes"
+)
+
+// fileEnvelope - версионированный конверт вокруг записей JSON файла
+type fileEnvelope struct {
+	Version int             `json:"version"`
+	Kind    string          `json:"kind"`
+	SavedAt time.Time       `json:"saved_at"`
+	Items   json.RawMessage `json:"items"`
+}
+
+// Migration переводит документ JSON файла из версии From в версию From+1
+type Migration struct {
+	From        int
+	Description string
+	// Apply получает документ целиком и вид коллекции (tasks или notes)
+	Apply func(kind string, doc []byte) ([]byte, error)
+}
+
+// migrations - реестр миграций по исходной версии
+var migrations = make(map[int]Migration)
+
+// RegisterMigration регистрирует миграцию; на каждую исходную версию допускается одна миграция
+func RegisterMigration(m Migration) {
+	if _, exists := migrations[m.From]; exists {
+		panic(fmt.Sprintf("migration from version %d is already registered", m.From))
+	}
+	migrations[m.From] = m
+}
+
+// Migrations возвращает зарегистрированные миграции в порядке возрастания версий
+func Migrations() []Migration {
+	result := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].From < result[j].From })
+	return result
+}
+
+func init() {
+	RegisterMigration(Migration{
+		From:        1,
+		Description: "обёртка голого массива в версионированный конверт",
+		Apply: func(kind string, doc []byte) ([]byte, error) {
+			items := bytes.TrimSpace(doc)
+			if len(items) == 0 || bytes.Equal(items, []byte("null")) {
+				items = []byte("[]")
+			}
+			return json.Marshal(fileEnvelope{Version: 2, Kind: kind, Items: items})
+		},
+	})
+	RegisterMigration(Migration{
+		From:        2,
+		Description: "первая версия у всех записей",
+		Apply: func(kind string, doc []byte) ([]byte, error) {
+			var env fileEnvelope
+			if err := json.Unmarshal(doc, &env); err != nil {
+				return nil, err
+			}
+
+			var items []json.RawMessage
+			if err := json.Unmarshal(env.Items, &items); err != nil {
+				return nil, err
+			}
+			for i, item := range items {
+				// Испорченные записи остаются как есть и попадают в отчёт при загрузке
+				var fields map[string]json.RawMessage
+				if json.Unmarshal(item, &fields) != nil || fields == nil {
+					continue
+				}
+				if _, ok := fields["version"]; ok {
+					continue
+				}
+				fields["version"] = json.RawMessage("1")
+				item, err := json.Marshal(fields)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = item
+			}
+
+			if items == nil {
+				items = []json.RawMessage{}
+			}
+			raw, err := json.Marshal(items)
+			if err != nil {
+				return nil, err
+			}
+			env.Version, env.Items = 3, raw
+			return json.Marshal(env)
+		},
+	})
+	RegisterMigration(Migration{
+		From:        3,
+		Description: "поддержка корзины; записи без deleted_at не удалены, поэтому меняется только версия",
+		Apply: func(kind string, doc []byte) ([]byte, error) {
+			var env fileEnvelope
+			if err := json.Unmarshal(doc, &env); err != nil {
+				return nil, err
+			}
+			env.Version = 4
+			return json.Marshal(env)
+		},
+	})
+}
+
+// detectVersion определяет версию формата документа
+func detectVersion(doc []byte) (int, error) {
+	trimmed := bytes.TrimSpace(doc)
+	if len(trimmed) == 0 || trimmed[0] == '[' || bytes.Equal(trimmed, []byte("null")) {
+		return 1, nil
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(trimmed, &header); err != nil {
+		return 0, err
+	}
+	if header.Version < 1 {
+		return 0, fmt.Errorf("в файле не указана версия формата")
+	}
+	return header.Version, nil
+}
+
+// upgradeDocument последовательно применяет миграции, пока документ не достигнет текущей версии
+func upgradeDocument(kind string, doc []byte) ([]byte, int, error) {
+	from, err := detectVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from > CurrentFormatVersion {
+		return nil, from, fmt.Errorf("версия формата %d новее поддерживаемой %d", from, CurrentFormatVersion)
+	}
+
+	for v := from; v < CurrentFormatVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, from, fmt.Errorf("нет миграции с версии %d", v)
+		}
+		if doc, err = m.Apply(kind, doc); err != nil {
+			return nil, from, fmt.Errorf("ошибка миграции с версии %d: %w", v, err)
+		}
+	}
+
+	return doc, from, nil
+}
+
+// decodeEnvelope читает JSON файл любой поддерживаемой версии и возвращает записи в текущем формате
+func decodeEnvelope(r io.Reader, kind string) (json.RawMessage, error) {
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _, err = upgradeDocument(kind, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var env fileEnvelope
+	if err := json.Unmarshal(doc, &env); err != nil {
+		return nil, err
+	}
+	if env.Kind != kind {
+		return nil, fmt.Errorf("файл содержит %q, ожидалось %q", env.Kind, kind)
+	}
+	return env.Items, nil
+}
+
+// encodeEnvelope записывает записи в конверте текущей версии
+func encodeEnvelope(w io.Writer, kind string, items interface{}) error {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fileEnvelope{
+		Version: CurrentFormatVersion,
+		Kind:    kind,
+		SavedAt: time.Now(),
+		Items:   raw,
+	})
+}
+
+// MigrationResult - итог явной миграции одного файла
+type MigrationResult struct {
+	File        string
+	FromVersion int
+	ToVersion   int
+	Backup      string // путь к резервной копии; пусто, если миграция не понадобилась
+}
+
+// MigrateFiles явно переводит JSON файлы задач и заметок на текущую версию формата.
+// Перед перезаписью каждого устаревшего файла рядом создаётся резервная копия (для зашифрованных
+// файлов - зашифрованная). Зашифрованные файлы мигрируют с парольной фразой из WithEncryption.
+// Каталог данных блокируется на всё время миграции
+func MigrateFiles(tasksFile, notesFile string, opts ...Option) ([]MigrationResult, error) {
+	options := newStorageOptions(tasksFile, opts)
+	cipher := newFileCipher(options.passphrase)
+
+	locks, err := lockDirs(tasksFile, notesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockDirs(locks)
+
+	var results []MigrationResult
+
+	for _, target := range []struct{ path, kind string }{
+		{tasksFile + ".json", kindTasks},
+		{notesFile + ".json", kindNotes},
+	} {
+		result, err := migrateFile(target.path, target.kind, cipher)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", target.path, err)
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+
+	return results, nil
+}
+
+// migrateFile мигрирует один файл; отсутствующий файл пропускается
+func migrateFile(path, kind string, cipher *fileCipher) (*MigrationResult, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	doc, err := cipher.open(path, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, from, err := upgradeDocument(kind, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{File: path, FromVersion: from, ToVersion: CurrentFormatVersion}
+	if from == CurrentFormatVersion {
+		return result, nil
+	}
+
+	// Резервная копия исходного файла в том виде, в каком он лежал на диске
+	result.Backup = fmt.Sprintf("%s.v%d-%s.bak", path, from, time.Now().Format("20060102-150405"))
+	if err := writeFileAtomic(result.Backup, func(w io.Writer) error {
+		_, err := w.Write(raw)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("ошибка создания резервной копии: %w", err)
+	}
+
+	// Переписываем конверт с отступами, как при обычном сохранении
+	var env fileEnvelope
+	if err := json.Unmarshal(upgraded, &env); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(path, ".json")
+	if err := writeCollectionAtomic(base, atomicFile{path: path, write: cipher.sealWriter(func(w io.Writer) error {
+		return encodeEnvelope(w, kind, env.Items)
+	})}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
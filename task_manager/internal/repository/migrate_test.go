@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Задача в формате до версии 3: без поля version
+const legacyTask = `{"id":7,"title":"Старая задача","description":"Описание","status":"todo","priority":"medium",` +
+	`"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z"}`
+
+// Файлы задач каждой прежней версии формата
+var legacyTaskFiles = []struct {
+	version int
+	doc     string
+}{
+	{1, `[` + legacyTask + `]`},
+	{2, `{"version":2,"kind":"tasks","saved_at":"2024-01-02T03:04:05Z","items":[` + legacyTask + `]}`},
+	{3, `{"version":3,"kind":"tasks","saved_at":"2024-01-02T03:04:05Z","items":[` +
+		legacyTask[:len(legacyTask)-1] + `,"version":1}]}`},
+}
+
+// checkMigratedTask проверяет, что после миграции хранилище открывается с задачей из старого файла
+func checkMigratedTask(t *testing.T, tasksFile, notesFile string, opts ...Option) {
+	t.Helper()
+	storage, _ := openStorage(t, tasksFile, notesFile, opts...)
+	task, err := storage.GetTask(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.GetTitle() != "Старая задача" || task.GetVersion() != 1 {
+		t.Errorf("после миграции задача %q версии %d", task.GetTitle(), task.GetVersion())
+	}
+}
+
+func TestMigrateFiles(t *testing.T) {
+	for _, legacy := range legacyTaskFiles {
+		t.Run(fmt.Sprintf("v%d", legacy.version), func(t *testing.T) {
+			tasksFile, notesFile := tempDataFiles(t)
+			if err := os.WriteFile(tasksFile+".json", []byte(legacy.doc), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := MigrateFiles(tasksFile, notesFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].FromVersion != legacy.version || results[0].ToVersion != CurrentFormatVersion {
+				t.Fatalf("результат миграции %+v", results)
+			}
+			if backup, err := os.ReadFile(results[0].Backup); err != nil || string(backup) != legacy.doc {
+				t.Errorf("резервная копия не совпадает с исходным файлом: %v", err)
+			}
+			if doc, _ := os.ReadFile(tasksFile + ".json"); detectVersionOf(t, doc) != CurrentFormatVersion {
+				t.Errorf("файл не переведён на версию %d", CurrentFormatVersion)
+			}
+
+			// Повторная миграция ничего не меняет
+			if results, err := MigrateFiles(tasksFile, notesFile); err != nil || results[0].Backup != "" {
+				t.Errorf("повторная миграция: %+v, %v", results, err)
+			}
+			checkMigratedTask(t, tasksFile, notesFile)
+		})
+	}
+}
+
+// detectVersionOf возвращает версию формата документа
+func detectVersionOf(t *testing.T, doc []byte) int {
+	t.Helper()
+	version, err := detectVersion(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+// Зашифрованный файл мигрирует с парольной фразой и остаётся зашифрованным, как и его резервная копия
+func TestMigrateEncryptedFiles(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	sealed, err := newFileCipher("secret").seal([]byte(legacyTaskFiles[0].doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tasksFile+".json", sealed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateFiles(tasksFile, notesFile); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("без парольной фразы: ожидалась ErrEncrypted, получено %v", err)
+	}
+	if _, err := MigrateFiles(tasksFile, notesFile, WithEncryption("wrong")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("с неверной фразой: ожидалась ErrWrongPassphrase, получено %v", err)
+	}
+
+	results, err := MigrateFiles(tasksFile, notesFile, WithEncryption("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ := os.ReadFile(results[0].Backup); !bytes.Equal(backup, sealed) {
+		t.Error("резервная копия зашифрованного файла не совпадает с исходной")
+	}
+	if doc, _ := os.ReadFile(tasksFile + ".json"); !isEncrypted(doc) {
+		t.Fatal("мигрированный файл записан открытым текстом")
+	}
+	checkMigratedTask(t, tasksFile, notesFile, WithEncryption("secret"))
+}
+
+// Миграция не идёт, пока каталог данных занят открытым хранилищем
+func TestMigrateFilesLocked(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	openStorage(t, tasksFile, notesFile)
+
+	var lockErr *LockError
+	if _, err := MigrateFiles(tasksFile, notesFile); !errors.As(err, &lockErr) {
+		t.Fatalf("ожидалась *LockError, получено %v", err)
+	}
+}
+
+// Файл прежней версии читается при открытии без MigrateFiles и при сохранении
+// переписывается в текущей версии
+func TestOpenUpgradesLegacyFiles(t *testing.T) {
+	for _, legacy := range legacyTaskFiles {
+		t.Run(fmt.Sprintf("v%d", legacy.version), func(t *testing.T) {
+			tasksFile, notesFile := tempDataFiles(t)
+			if err := os.WriteFile(tasksFile+".json", []byte(legacy.doc), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			storage, report := openStorage(t, tasksFile, notesFile)
+			if report.HasIssues() {
+				t.Errorf("старый формат загружен с проблемами:\n%s", report)
+			}
+			closeStorage(t, storage)
+
+			if doc, _ := os.ReadFile(tasksFile + ".json"); detectVersionOf(t, doc) != CurrentFormatVersion {
+				t.Errorf("после сохранения файл не в версии %d", CurrentFormatVersion)
+			}
+			checkMigratedTask(t, tasksFile, notesFile)
+		})
+	}
+}
+
+// Файл более новой версии не читается, а его содержимое остаётся в снимке перед восстановлением
+func TestNewerFormatVersionRejected(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	doc := fmt.Sprintf(`{"version":%d,"kind":"tasks","items":[]}`, CurrentFormatVersion+1)
+	if err := os.WriteFile(tasksFile+".json", []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateFiles(tasksFile, notesFile); err == nil {
+		t.Error("MigrateFiles не отклонил файл более новой версии")
+	}
+
+	_, report := openStorage(t, tasksFile, notesFile)
+	failed := issuesWith(report, LoadFailed)
+	if len(failed) == 0 || !strings.Contains(failed[0].Reason, "новее") {
+		t.Fatalf("в отчёте нет отказа по версии:\n%s", report)
+	}
+	var kept bool
+	for _, path := range snapshotFiles(t, tasksFile) {
+		if data, _ := os.ReadFile(path); filepath.Base(path) == "tasks.json" && string(data) == doc {
+			kept = true
+		}
+	}
+	if !kept {
+		t.Error("файл новой версии не сохранён в снимке перед восстановлением")
+	}
+}
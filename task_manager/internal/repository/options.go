@@ -0,0 +1,101 @@
+package repository
+
+// Option настраивает файловое хранилище, создаваемое Open (форматы, шифрование и каталог снимков
+// учитывает и ReconcileFiles)
+type Option func(*storageOptions)
+
+// storageOptions - итоговые настройки Open
+type storageOptions struct {
+	readOnly   bool
+	strict     bool
+	journal    *JournalOptions
+	snapshots  SnapshotOptions
+	passphrase string
+	formats    []string
+}
+
+// WithReadOnly открывает хранилище только для чтения: каталог данных не блокируется,
+// поэтому его можно читать параллельно с процессом-владельцем, а любые изменения возвращают ErrReadOnly
+func WithReadOnly() Option {
+	return func(o *storageOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithJournal включает журналируемый режим: каждое изменение дописывается в файлы *.journal,
+// а файлы коллекций обновляются при компактизации
+func WithJournal(options JournalOptions) Option {
+	return func(o *storageOptions) {
+		o.journal = &options
+	}
+}
+
+// WithStrictLoad включает строгую загрузку: любая пропущенная или исправленная запись
+// прерывает открытие хранилища ошибкой *LoadError, чтобы данные не терялись молча
+func WithStrictLoad() Option {
+	return func(o *storageOptions) {
+		o.strict = true
+	}
+}
+
+// WithSnapshots задаёт каталог, период и политику хранения снимков (см. Storage.Snapshot и StartSnapshots)
+func WithSnapshots(options SnapshotOptions) Option {
+	return func(o *storageOptions) {
+		o.snapshots = options
+	}
+}
+
+// WithEncryption включает шифрование файлов данных ключом, выведенным из passphrase.
+// Неверная фраза или зашифрованные файлы без неё прерывают открытие ошибкой
+// ErrWrongPassphrase или ErrEncrypted; сменить ключ можно через RekeyFiles
+func WithEncryption(passphrase string) Option {
+	return func(o *storageOptions) {
+		o.passphrase = passphrase
+	}
+}
+
+// WithFormats задаёт основной формат файлов primary и зеркала mirrors (по умолчанию JSON и CSV).
+// Данные читаются из основного формата, а зеркала - запасные копии на случай его повреждения.
+// Доступны FormatJSON, FormatCSV, FormatGob, FormatXML, FormatNDJSON и форматы, зарегистрированные через RegisterCodec
+func WithFormats(primary string, mirrors ...string) Option {
+	return func(o *storageOptions) {
+		o.formats = append([]string{primary}, mirrors...)
+	}
+}
+
+// newStorageOptions применяет opts к настройкам по умолчанию;
+// каталог снимков по умолчанию лежит рядом с файлом задач
+func newStorageOptions(tasksFile string, opts []Option) storageOptions {
+	var options storageOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.snapshots.Dir == "" {
+		options.snapshots.Dir = defaultSnapshotDir(tasksFile)
+	}
+	return options
+}
+
+// fileBackend создаёт файловый бэкенд с форматами и шифрованием из настроек
+func (o storageOptions) fileBackend(tasksFile, notesFile string) (*FileBackend, error) {
+	// Парольная фраза проверяется до загрузки, иначе нечитаемые файлы выглядели бы пустым хранилищем
+	cipher := newFileCipher(o.passphrase)
+	if err := checkPassphrase(cipher, dataFiles(tasksFile, notesFile)...); err != nil {
+		return nil, err
+	}
+	if err := checkEncrypted(cipher, dataFiles(tasksFile, notesFile)...); err != nil {
+		return nil, err
+	}
+
+	files := NewFileBackend(tasksFile, notesFile)
+	if o.formats != nil {
+		codecs, err := lookupCodecs(o.formats)
+		if err != nil {
+			return nil, err
+		}
+		files.codecs = codecs
+	}
+	files.cipher = cipher
+	files.snapshotDir = o.snapshots.Dir
+	return files, nil
+}
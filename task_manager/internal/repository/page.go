@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"task-manager/internal/model"
+	"time"
+)
+
+// Размер страницы по умолчанию и наибольший допустимый
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// SortField - поле, по которому упорядочивается постраничный список
+type SortField string
+
+const (
+	SortByID        SortField = "id"
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByTitle     SortField = "title"
+)
+
+// ListOptions - параметры постраничного списка задач или заметок
+type ListOptions struct {
+	Limit  int       // размер страницы; 0 - DefaultPageSize, больше MaxPageSize - MaxPageSize
+	Cursor string    // NextCursor предыдущей страницы; пусто - первая страница
+	SortBy SortField // пусто - SortByID
+	Desc   bool      // по убыванию
+}
+
+// Page - одна страница списка
+type Page[T any] struct {
+	Items      []T
+	NextCursor string // курсор следующей страницы; пусто - это последняя страница
+	Total      int    // всего записей в коллекции без учёта корзины
+}
+
+// sortKey - значение поля сортировки записи; ID делает порядок строгим при равных значениях
+type sortKey struct {
+	ID    int       `json:"id"`
+	Time  time.Time `json:"time,omitzero"`
+	Title string    `json:"title,omitempty"`
+}
+
+// pageCursor - содержимое курсора: ключ последней выданной записи и порядок, для которого он выдан.
+// Курсор указывает на значение, а не на позицию, поэтому страницы не съезжают
+// при добавлении и удалении записей между запросами
+type pageCursor struct {
+	SortBy SortField `json:"sort"`
+	Desc   bool      `json:"desc,omitempty"`
+	After  sortKey   `json:"after"`
+}
+
+// encode возвращает непрозрачное строковое представление курсора
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для того же порядка сортировки
+func decodeCursor(cursor string, options ListOptions) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, model.NewValidationError("invalid page cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, model.NewValidationError("invalid page cursor")
+	}
+	if c.SortBy != options.SortBy || c.Desc != options.Desc {
+		return nil, model.NewValidationError("page cursor was issued for a different sort order")
+	}
+	return &c, nil
+}
+
+// normalize подставляет значения по умолчанию и проверяет параметры
+func (o ListOptions) normalize() (ListOptions, error) {
+	switch {
+	case o.Limit < 0:
+		return o, model.NewValidationError(fmt.Sprintf("invalid page limit %d", o.Limit))
+	case o.Limit == 0:
+		o.Limit = DefaultPageSize
+	case o.Limit > MaxPageSize:
+		o.Limit = MaxPageSize
+	}
+
+	switch o.SortBy {
+	case "":
+		o.SortBy = SortByID
+	case SortByID, SortByCreatedAt, SortByUpdatedAt, SortByTitle:
+	default:
+		return o, model.NewValidationError(fmt.Sprintf("unknown sort field %q", o.SortBy))
+	}
+	return o, nil
+}
+
+// keyOf возвращает ключ сортировки записи
+func keyOf[T any](item T, field SortField, acc recordAccessors[T]) sortKey {
+	key := sortKey{ID: acc.id(item)}
+	switch field {
+	case SortByCreatedAt:
+		key.Time = acc.created(item)
+	case SortByUpdatedAt:
+		key.Time = acc.updated(item)
+	case SortByTitle:
+		key.Title = acc.title(item)
+	}
+	return key
+}
+
+// compareKeys сравнивает ключи по полю сортировки, а при равенстве - по ID
+func compareKeys(a, b sortKey, field SortField) int {
+	var c int
+	switch field {
+	case SortByCreatedAt, SortByUpdatedAt:
+		c = a.Time.Compare(b.Time)
+	case SortByTitle:
+		c = strings.Compare(a.Title, b.Title)
+	}
+	return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+}
+
+// sortOrders - порядки записей снимка по полям сортировки, кроме ID (его поддерживает индекс).
+// Порядок строится при первом запросе страницы к снимку и дальше только читается
+type sortOrders struct {
+	mu      sync.Mutex
+	byField map[SortField][]int
+}
+
+// get возвращает порядок по полю field, строя его функцией build при первом обращении
+func (o *sortOrders) get(field SortField, build func() []int) []int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ids, ok := o.byField[field]; ok {
+		return ids
+	}
+	if o.byField == nil {
+		o.byField = make(map[SortField][]int)
+	}
+	ids := build()
+	o.byField[field] = ids
+	return ids
+}
+
+// sortedIDs возвращает ID записей вне корзины по возрастанию ключа сортировки field
+func sortedIDs[T any](items []T, field SortField, acc recordAccessors[T]) []int {
+	keys := make([]sortKey, 0, len(items))
+	for _, item := range items {
+		if !acc.deleted(item) {
+			keys = append(keys, keyOf(item, field, acc))
+		}
+	}
+	slices.SortFunc(keys, func(a, b sortKey) int { return compareKeys(a, b, field) })
+
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	return ids
+}
+
+// listPage выбирает страницу из items. orderBy возвращает ID записей вне корзины по возрастанию
+// ключа сортировки, byID - позиции записей в items. Курсор находится двоичным поиском,
+// поэтому страница стоит O(log n + limit), и копируются лишь записи страницы
+func listPage[T any](items []T, byID map[int]int, orderBy func(SortField) []int, options ListOptions, acc recordAccessors[T], clone func(T) T) (Page[T], error) {
+	options, err := options.normalize()
+	if err != nil {
+		return Page[T]{}, err
+	}
+	cursor, err := decodeCursor(options.Cursor, options)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	order := orderBy(options.SortBy)
+	itemAt := func(i int) T { return items[byID[order[i]]] }
+
+	// Записи после курсора - это [from, to) в порядке возрастания: при обратном порядке
+	// страница берётся с конца диапазона
+	from, to := 0, len(order)
+	if cursor != nil {
+		split := sort.Search(len(order), func(i int) bool {
+			c := compareKeys(keyOf(itemAt(i), options.SortBy, acc), cursor.After, options.SortBy)
+			return c > 0 || options.Desc && c == 0
+		})
+		if options.Desc {
+			to = split
+		} else {
+			from = split
+		}
+	}
+
+	// Берётся на одну запись больше страницы, чтобы узнать, есть ли следующая
+	n := min(to-from, options.Limit+1)
+	selected := make([]T, n)
+	for i := range selected {
+		if options.Desc {
+			selected[i] = itemAt(to - 1 - i)
+		} else {
+			selected[i] = itemAt(from + i)
+		}
+	}
+
+	page := Page[T]{Total: len(order)}
+	if len(selected) > options.Limit {
+		selected = selected[:options.Limit]
+		last := keyOf(selected[len(selected)-1], options.SortBy, acc)
+		page.NextCursor = pageCursor{SortBy: options.SortBy, Desc: options.Desc, After: last}.encode()
+	}
+
+	page.Items = make([]T, len(selected))
+	for i, item := range selected {
+		page.Items[i] = clone(item)
+	}
+	return page, nil
+}
+
+// ListTasks возвращает страницу копий задач в порядке options.SortBy.
+// Следующая страница запрашивается с Cursor = NextCursor; курсор действителен только
+// для того же порядка сортировки. Некорректные параметры - *model.ValidationError.
+// Страница строится по опубликованному снимку коллекции и не ждёт писателей
+func (s *Storage) ListTasks(options ListOptions) (Page[*model.Task], error) {
+	if err := s.checkOpen(); err != nil {
+		return Page[*model.Task]{}, err
+	}
+	view := s.loadView()
+	return listPage(view.tasks, view.taskIndex.byID, view.taskOrder, options, taskAccessors, (*model.Task).Clone)
+}
+
+// ListNotes возвращает страницу копий заметок аналогично ListTasks
+func (s *Storage) ListNotes(options ListOptions) (Page[*model.Note], error) {
+	if err := s.checkOpen(); err != nil {
+		return Page[*model.Note]{}, err
+	}
+	view := s.loadView()
+	return listPage(view.notes, view.noteIndex.byID, view.noteOrder, options, noteAccessors, (*model.Note).Clone)
+}
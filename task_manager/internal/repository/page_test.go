@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// pageIDs возвращает ID задач страницы
+func pageIDs(t *testing.T, s *Storage, options ListOptions) ([]int, string) {
+	t.Helper()
+	page, err := s.ListTasks(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taskIDs(page.Items), page.NextCursor
+}
+
+// Страницы по заголовку идут в порядке заголовков независимо от порядка добавления,
+// а у последней страницы нет курсора
+func TestListTasksByTitle(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, title := range []string{"Виноград", "Арбуз", "Дыня", "Банан", "Гранат"} {
+		addTask(t, storage, title)
+	}
+
+	options := ListOptions{SortBy: SortByTitle, Limit: 2}
+	var pages [][]int
+	for {
+		page, err := storage.ListTasks(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Total %d, ожидалось 5", page.Total)
+		}
+		pages = append(pages, taskIDs(page.Items))
+		if page.NextCursor == "" {
+			break
+		}
+		options.Cursor = page.NextCursor
+	}
+	if want := [][]int{{2, 4}, {1, 5}, {3}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("страницы %v, ожидались %v", pages, want)
+	}
+
+	options = ListOptions{SortBy: SortByTitle, Desc: true, Limit: 2}
+	if got, _ := pageIDs(t, storage, options); !reflect.DeepEqual(got, []int{3, 5}) {
+		t.Errorf("первая страница по убыванию %v, ожидалось [3 5]", got)
+	}
+}
+
+// Курсор указывает на значение, а не на позицию: записи, добавленные перед ним, не сдвигают
+// следующую страницу, а добавленные после него в неё попадают
+func TestListTasksCursorAfterInsert(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, title := range []string{"Б", "Г", "Е", "Ж"} {
+		addTask(t, storage, title)
+	}
+	first, cursor := pageIDs(t, storage, ListOptions{SortBy: SortByTitle, Limit: 2})
+	if !reflect.DeepEqual(first, []int{1, 2}) {
+		t.Fatalf("первая страница %v", first)
+	}
+
+	addTask(t, storage, "А") // 5 - перед уже выданными
+	addTask(t, storage, "В") // 6 - между выданными
+	addTask(t, storage, "Д") // 7 - после курсора
+
+	if next, _ := pageIDs(t, storage, ListOptions{SortBy: SortByTitle, Limit: 10, Cursor: cursor}); !reflect.DeepEqual(next, []int{7, 3, 4}) {
+		t.Errorf("следующая страница %v, ожидалось [7 3 4]", next)
+	}
+}
+
+// Удаление записи, на которой остановился курсор, не ломает листание, а удалённые
+// ещё не выданные записи просто не выдаются
+func TestListTasksCursorAfterDelete(t *testing.T) {
+	storage := NewMemoryStorage()
+	for i := 1; i <= 5; i++ {
+		addTask(t, storage, fmt.Sprintf("Задача %d", i))
+	}
+	_, cursor := pageIDs(t, storage, ListOptions{Limit: 2})
+
+	for _, id := range []int{2, 4} {
+		if err := storage.DeleteTask(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next, nextCursor := pageIDs(t, storage, ListOptions{Limit: 2, Cursor: cursor})
+	if !reflect.DeepEqual(next, []int{3, 5}) || nextCursor != "" {
+		t.Errorf("следующая страница %v (курсор %q), ожидалось [3 5] без курсора", next, nextCursor)
+	}
+}
+
+// При равных значениях поля порядок задаёт ID, поэтому граница страницы внутри группы
+// одинаковых значений ничего не пропускает и не повторяет
+func TestListTasksEqualKeys(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, title := range []string{"Повтор", "Другая", "Повтор", "Повтор"} {
+		addTask(t, storage, title)
+	}
+
+	var got []int
+	options := ListOptions{SortBy: SortByTitle, Desc: true, Limit: 2}
+	for {
+		ids, cursor := pageIDs(t, storage, options)
+		got = append(got, ids...)
+		if cursor == "" {
+			break
+		}
+		options.Cursor = cursor
+	}
+	if want := []int{4, 3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("по убыванию заголовка %v, ожидалось %v", got, want)
+	}
+}
+
+// Изменённая задача переезжает в порядке по времени изменения
+func TestListTasksByUpdatedAt(t *testing.T) {
+	storage := NewMemoryStorage()
+	for i := 1; i <= 3; i++ {
+		addTask(t, storage, fmt.Sprintf("Задача %d", i))
+	}
+	editTask(t, storage, 1, func(task *model.Task) error {
+		task.SetUpdatedAt(time.Now().Add(time.Hour))
+		return task.SetStatus(model.StatusDone)
+	})
+
+	if got, _ := pageIDs(t, storage, ListOptions{SortBy: SortByUpdatedAt, Desc: true, Limit: 1}); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("последней изменена задача %v, ожидалась [1]", got)
+	}
+}
+
+func TestListTasksInvalidOptions(t *testing.T) {
+	storage := NewMemoryStorage()
+	for i := 1; i <= 3; i++ {
+		addTask(t, storage, fmt.Sprintf("Задача %d", i))
+	}
+	first, err := storage.ListTasks(ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options ListOptions
+	}{
+		{"отрицательный размер", ListOptions{Limit: -1}},
+		{"неизвестное поле", ListOptions{SortBy: "priority"}},
+		{"испорченный курсор", ListOptions{Cursor: "не курсор"}},
+		{"курсор другого поля", ListOptions{SortBy: SortByTitle, Cursor: first.NextCursor}},
+		{"курсор другого направления", ListOptions{Desc: true, Cursor: first.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := storage.ListTasks(tt.options); !model.IsValidationError(err) {
+				t.Errorf("ожидалась *model.ValidationError, получено %v", err)
+			}
+		})
+	}
+}
+
+// Страницы по ID идут в порядке ID, даже если в файлах записи хранятся в другом порядке,
+// а заметки в корзине не попадают ни в страницы, ни в Total
+func TestListNotesStoredOutOfOrder(t *testing.T) {
+	var notes []*model.Note
+	for _, id := range []int{5, 2, 8, 1, 4} {
+		note := model.NewNote(fmt.Sprintf("Заметка %d", id), "Содержимое", model.CategoryWork)
+		note.SetID(id)
+		note.SetVersion(1)
+		notes = append(notes, note)
+	}
+	backend := NewMemoryBackend()
+	if err := backend.SaveCollections(nil, notes); err != nil {
+		t.Fatal(err)
+	}
+	storage, _ := NewStorageWithBackend(backend)
+	if err := storage.DeleteNote(4); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc bool
+		want []int
+	}{
+		{false, []int{1, 2, 5, 8}},
+		{true, []int{8, 5, 2, 1}},
+	}
+	for _, tt := range tests {
+		var got []int
+		options := ListOptions{Limit: 3, Desc: tt.desc}
+		for {
+			page, err := storage.ListNotes(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != len(tt.want) {
+				t.Errorf("Total %d, ожидалось %d", page.Total, len(tt.want))
+			}
+			for _, note := range page.Items {
+				got = append(got, note.GetID())
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			options.Cursor = page.NextCursor
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("по убыванию %v: получено %v, ожидалось %v", tt.desc, got, tt.want)
+		}
+	}
+}
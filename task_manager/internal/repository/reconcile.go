@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"task-manager/internal/model"
+	"time"
+)
+
+// ReconcilePolicy - правило разрешения расхождений между основным форматом и зеркалом
+// (по умолчанию JSON и CSV). Кроме PreferNewest, правилом может быть имя любого
+// из двух сверяемых форматов: его версия побеждает
+type ReconcilePolicy string
+
+const (
+	// PreferJSON - JSON файл считается основным: при расхождениях побеждает его версия,
+	// записи, которых в нём нет, удаляются и из CSV
+	PreferJSON ReconcilePolicy = FormatJSON
+	// PreferCSV - CSV файл считается основным (например, после правки в редакторе таблиц)
+	PreferCSV ReconcilePolicy = FormatCSV
+	// PreferNewest - для каждой записи побеждает версия с более поздним updatedAt,
+	// записи, найденные только в одном файле, сохраняются
+	PreferNewest ReconcilePolicy = "newest"
+)
+
+// DiffKind - вид расхождения записи между файлами; для записей, найденных только в одном
+// файле, - "only-" и имя формата этого файла
+type DiffKind string
+
+const (
+	DiffOnlyJSON DiffKind = "only-" + FormatJSON
+	DiffOnlyCSV  DiffKind = "only-" + FormatCSV
+	DiffChanged  DiffKind = "changed"
+)
+
+// RecordDiff - расхождение одной записи между основным форматом и зеркалом
+type RecordDiff struct {
+	Collection string // tasks или notes
+	ID         int
+	Kind       DiffKind
+	Fields     []string // различающиеся поля для DiffChanged
+	Resolution string   // какая версия записана: имя формата или deleted
+}
+
+// String возвращает описание расхождения
+func (d RecordDiff) String() string {
+	s := fmt.Sprintf("%s #%d: %s", d.Collection, d.ID, d.Kind)
+	if len(d.Fields) > 0 {
+		s += fmt.Sprintf(" %v", d.Fields)
+	}
+	if d.Resolution != "" {
+		s += " -> " + d.Resolution
+	}
+	return s
+}
+
+// ReconcileReport - итог сверки файлов
+type ReconcileReport struct {
+	Diffs   []RecordDiff
+	Load    *LoadReport // проблемы, найденные при чтении обоих форматов
+	Written bool        // были ли файлы перезаписаны
+}
+
+// ReconcileFiles сравнивает файлы задач и заметок основного формата и первого зеркала запись
+// за записью, разрешает расхождения по policy и записывает результат во все форматы.
+// Форматы, шифрование и каталог снимков задаются теми же опциями, что и для Open
+// (WithFormats, WithEncryption, WithSnapshots). При dryRun расхождения только перечисляются.
+// Каталог данных блокируется на время сверки, поэтому открытое хранилище нужно сначала закрыть
+func ReconcileFiles(tasksFile, notesFile string, policy ReconcilePolicy, dryRun bool, opts ...Option) (*ReconcileReport, error) {
+	options := newStorageOptions(tasksFile, opts)
+	formats := options.formats
+	if formats == nil {
+		formats = defaultFormats
+	}
+	if len(formats) < 2 {
+		return nil, model.NewValidationError("reconcile needs a primary format and at least one mirror")
+	}
+	primary, mirror := formats[0], formats[1]
+	switch string(policy) {
+	case string(PreferNewest), primary, mirror:
+	default:
+		return nil, model.NewValidationError(fmt.Sprintf("unknown reconcile policy %q", policy))
+	}
+
+	locks, err := lockDirs(tasksFile, notesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockDirs(locks)
+
+	// Парольная фраза проверяется до чтения: нечитаемый файл считался бы пустым
+	b, err := options.fileBackend(tasksFile, notesFile)
+	if err != nil {
+		return nil, err
+	}
+	first, second := b.codecs[0], b.codecs[1]
+	result := &ReconcileReport{Load: &LoadReport{}}
+
+	// Задачи
+	firstTasks, err := loadForReconcile(b.tasksFile, first, second, policy, result.Load, b.loadTasksFile)
+	if err != nil {
+		return nil, err
+	}
+	secondTasks, err := loadForReconcile(b.tasksFile, second, first, policy, result.Load, b.loadTasksFile)
+	if err != nil {
+		return nil, err
+	}
+	tasks, taskDiffs := reconcileRecords(kindTasks, firstTasks, secondTasks, first.Name(), second.Name(), policy, taskAccessors)
+	result.Diffs = append(result.Diffs, taskDiffs...)
+
+	// Заметки
+	firstNotes, err := loadForReconcile(b.notesFile, first, second, policy, result.Load, b.loadNotesFile)
+	if err != nil {
+		return nil, err
+	}
+	secondNotes, err := loadForReconcile(b.notesFile, second, first, policy, result.Load, b.loadNotesFile)
+	if err != nil {
+		return nil, err
+	}
+	notes, noteDiffs := reconcileRecords(kindNotes, firstNotes, secondNotes, first.Name(), second.Name(), policy, noteAccessors)
+	result.Diffs = append(result.Diffs, noteDiffs...)
+
+	// Файлы без расхождений по записям, но не совпадающие с манифестом (например, CSV, пересохранённый
+	// редактором таблиц без изменений), тоже перезаписываются, иначе Open продолжит считать их правкой
+	stale := make(map[string]bool)
+	for _, issue := range VerifyFiles(tasksFile, notesFile).Issues() {
+		for _, base := range []string{b.tasksFile, b.notesFile} {
+			if slices.Contains(b.collectionFiles(base), issue.File) {
+				stale[base] = true
+			}
+		}
+	}
+	rewriteTasks := len(taskDiffs) > 0 || stale[b.tasksFile]
+	rewriteNotes := len(noteDiffs) > 0 || stale[b.notesFile]
+
+	if dryRun || !rewriteTasks && !rewriteNotes {
+		return result, nil
+	}
+
+	// Перезапись всех форматов - рискованная операция, сохраняем исходные файлы
+	if _, err := takeSnapshot(tasksFile, notesFile, options.snapshots.Dir, "pre-reconcile"); err != nil {
+		return result, fmt.Errorf("ошибка снимка перед сверкой: %w", err)
+	}
+
+	if rewriteTasks {
+		if err := b.SaveTasks(tasks); err != nil {
+			return result, fmt.Errorf("ошибка сохранения задач: %w", err)
+		}
+		result.Written = true
+	}
+	if rewriteNotes {
+		if err := b.SaveNotes(notes); err != nil {
+			return result, fmt.Errorf("ошибка сохранения заметок: %w", err)
+		}
+		result.Written = true
+	}
+
+	return result, nil
+}
+
+// loadForReconcile читает файл коллекции base в формате c. Нечитаемый файл считается пустым
+// и будет восстановлен из формата other, если только policy не отдаёт предпочтение самому c
+func loadForReconcile[T any](base string, c, other Codec, policy ReconcilePolicy, report *LoadReport, load func(Codec, *LoadReport) ([]T, error)) ([]T, error) {
+	items, err := load(c, report)
+	if err == nil {
+		return items, nil
+	}
+
+	path := base + c.Extension()
+	if string(policy) == c.Name() {
+		return nil, fmt.Errorf("ошибка чтения %s: %w", path, err)
+	}
+	report.add(path, 0, LoadFailed, fmt.Sprintf("%v; файл будет восстановлен из %s", err, strings.ToUpper(other.Name())))
+	return nil, nil
+}
+
+// recordAccessors описывает, как сравнивать записи одного типа
+type recordAccessors[T any] struct {
+	id         func(T) int
+	setID      func(T) func(int)
+	updated    func(T) time.Time
+	created    func(T) time.Time
+	title      func(T) string
+	deleted    func(T) bool
+	diff       func(a, b T) []string
+	key        func(T) string // содержимое записи с точностью CSV
+	version    func(T) int
+	setVersion func(T) func(int)
+}
+
+var taskAccessors = recordAccessors[*model.Task]{
+	id:         (*model.Task).GetID,
+	setID:      func(t *model.Task) func(int) { return t.SetID },
+	updated:    (*model.Task).GetUpdatedAt,
+	created:    (*model.Task).GetCreatedAt,
+	title:      (*model.Task).GetTitle,
+	deleted:    (*model.Task).IsDeleted,
+	diff:       diffTasks,
+	key:        func(t *model.Task) string { return strings.Join(taskCSVRecord(t), "\x1f") },
+	version:    (*model.Task).GetVersion,
+	setVersion: func(t *model.Task) func(int) { return t.SetVersion },
+}
+
+var noteAccessors = recordAccessors[*model.Note]{
+	id:         (*model.Note).GetID,
+	setID:      func(n *model.Note) func(int) { return n.SetID },
+	updated:    (*model.Note).GetUpdatedAt,
+	created:    (*model.Note).GetCreatedAt,
+	title:      (*model.Note).GetTitle,
+	deleted:    (*model.Note).IsDeleted,
+	diff:       diffNotes,
+	key:        func(n *model.Note) string { return strings.Join(noteCSVRecord(n), "\x1f") },
+	version:    (*model.Note).GetVersion,
+	setVersion: func(n *model.Note) func(int) { return n.SetVersion },
+}
+
+// reconcileRecords сопоставляет по ID записи двух файлов в форматах firstName и secondName
+// и строит итоговый набор
+func reconcileRecords[T any](collection string, first, second []T, firstName, secondName string, policy ReconcilePolicy, acc recordAccessors[T]) ([]T, []RecordDiff) {
+	secondByID := make(map[int]T, len(second))
+	for _, rec := range second {
+		secondByID[acc.id(rec)] = rec
+	}
+	firstIDs := make(map[int]bool, len(first))
+
+	var result []T
+	var diffs []RecordDiff
+
+	for _, f := range first {
+		id := acc.id(f)
+		firstIDs[id] = true
+
+		sec, ok := secondByID[id]
+		if !ok {
+			d := RecordDiff{Collection: collection, ID: id, Kind: DiffKind("only-" + firstName), Resolution: firstName}
+			if string(policy) == secondName {
+				d.Resolution = "deleted"
+			} else {
+				result = append(result, f)
+			}
+			diffs = append(diffs, d)
+			continue
+		}
+
+		fields := acc.diff(f, sec)
+		if len(fields) == 0 {
+			result = append(result, f)
+			continue
+		}
+
+		d := RecordDiff{Collection: collection, ID: id, Kind: DiffChanged, Fields: fields, Resolution: firstName}
+		winner := f
+		if string(policy) == secondName || (policy == PreferNewest && acc.updated(sec).After(acc.updated(f))) {
+			winner = sec
+			d.Resolution = secondName
+		}
+		result = append(result, winner)
+		diffs = append(diffs, d)
+	}
+
+	for _, sec := range second {
+		id := acc.id(sec)
+		if firstIDs[id] {
+			continue
+		}
+
+		d := RecordDiff{Collection: collection, ID: id, Kind: DiffKind("only-" + secondName), Resolution: secondName}
+		if string(policy) == firstName {
+			d.Resolution = "deleted"
+		} else {
+			result = append(result, sec)
+		}
+		diffs = append(diffs, d)
+	}
+
+	return result, diffs
+}
+
+// sameTime сравнивает время с точностью до секунды: CSV хранит даты в RFC3339 без долей секунды
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+// diffTasks возвращает имена различающихся полей двух версий задачи
+func diffTasks(a, b *model.Task) []string {
+	var fields []string
+	if a.GetTitle() != b.GetTitle() {
+		fields = append(fields, "title")
+	}
+	if a.GetDescription() != b.GetDescription() {
+		fields = append(fields, "description")
+	}
+	if a.GetStatus() != b.GetStatus() {
+		fields = append(fields, "status")
+	}
+	if a.GetPriority() != b.GetPriority() {
+		fields = append(fields, "priority")
+	}
+	if !sameTime(a.GetCreatedAt(), b.GetCreatedAt()) {
+		fields = append(fields, "created_at")
+	}
+	if !sameTime(a.GetUpdatedAt(), b.GetUpdatedAt()) {
+		fields = append(fields, "updated_at")
+	}
+	if a.GetVersion() != b.GetVersion() {
+		fields = append(fields, "version")
+	}
+	if ad, bd := a.GetDeletedAt(), b.GetDeletedAt(); (ad == nil) != (bd == nil) || (ad != nil && !sameTime(*ad, *bd)) {
+		fields = append(fields, "deleted_at")
+	}
+
+	ad, bd := a.GetDueDate(), b.GetDueDate()
+	if (ad == nil) != (bd == nil) || (ad != nil && !sameTime(*ad, *bd)) {
+		fields = append(fields, "due_date")
+	}
+	return fields
+}
+
+// diffNotes возвращает имена различающихся полей двух версий заметки
+func diffNotes(a, b *model.Note) []string {
+	var fields []string
+	if a.GetTitle() != b.GetTitle() {
+		fields = append(fields, "title")
+	}
+	if a.GetContent() != b.GetContent() {
+		fields = append(fields, "content")
+	}
+	if a.GetCategory() != b.GetCategory() {
+		fields = append(fields, "category")
+	}
+	if !sameTime(a.GetCreatedAt(), b.GetCreatedAt()) {
+		fields = append(fields, "created_at")
+	}
+	if !sameTime(a.GetUpdatedAt(), b.GetUpdatedAt()) {
+		fields = append(fields, "updated_at")
+	}
+	if a.GetVersion() != b.GetVersion() {
+		fields = append(fields, "version")
+	}
+	if ad, bd := a.GetDeletedAt(), b.GetDeletedAt(); (ad == nil) != (bd == nil) || (ad != nil && !sameTime(*ad, *bd)) {
+		fields = append(fields, "deleted_at")
+	}
+	return fields
+}
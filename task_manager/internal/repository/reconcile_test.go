@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// divergedFiles создаёт данные, у которых CSV разошёлся с JSON: задача 1 изменена в CSV позже,
+// задачи 2 в CSV нет, а задача 4 есть только в CSV
+func divergedFiles(t *testing.T) (string, string) {
+	t.Helper()
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	for _, title := range []string{"Первая", "Вторая", "Третья"} {
+		addTask(t, storage, title)
+	}
+	first, _ := storage.GetTask(1)
+	third, _ := storage.GetTask(3)
+	closeStorage(t, storage)
+
+	if err := first.SetTitle("Исправленная"); err != nil {
+		t.Fatal(err)
+	}
+	first.SetUpdatedAt(first.GetUpdatedAt().Add(time.Hour))
+	onlyCSV := newBenchmarkTask(t, 4)
+	onlyCSV.SetID(4)
+	onlyCSV.SetVersion(1)
+
+	var csv bytes.Buffer
+	if err := (csvCodec{}).EncodeTasks(&csv, []*model.Task{first, third, onlyCSV}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tasksFile+".csv", csv.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tasksFile, notesFile
+}
+
+// diffSummary сводит расхождения к виду и решению по ID записи
+func diffSummary(diffs []RecordDiff) map[int]string {
+	summary := make(map[int]string)
+	for _, d := range diffs {
+		summary[d.ID] = string(d.Kind) + " -> " + d.Resolution
+	}
+	return summary
+}
+
+// Каждое правило по-своему разрешает одни и те же расхождения, после чего оба формата совпадают
+func TestReconcilePolicies(t *testing.T) {
+	tests := []struct {
+		policy    ReconcilePolicy
+		wantDiffs map[int]string
+		wantIDs   []int
+		wantTitle string // заголовок задачи 1 после сверки
+	}{
+		{PreferJSON, map[int]string{1: "changed -> json", 2: "only-json -> json", 4: "only-csv -> deleted"}, []int{1, 2, 3}, "Первая"},
+		{PreferCSV, map[int]string{1: "changed -> csv", 2: "only-json -> deleted", 4: "only-csv -> csv"}, []int{1, 3, 4}, "Исправленная"},
+		{PreferNewest, map[int]string{1: "changed -> csv", 2: "only-json -> json", 4: "only-csv -> csv"}, []int{1, 2, 3, 4}, "Исправленная"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			tasksFile, notesFile := divergedFiles(t)
+			result, err := ReconcileFiles(tasksFile, notesFile, tt.policy, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diffSummary(result.Diffs); !reflect.DeepEqual(got, tt.wantDiffs) {
+				t.Errorf("расхождения %v, ожидались %v", got, tt.wantDiffs)
+			}
+			if !result.Written {
+				t.Error("файлы не перезаписаны")
+			}
+
+			if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+				t.Errorf("после сверки форматы не совпадают с манифестом:\n%s", report)
+			}
+			storage, _ := openStorage(t, tasksFile, notesFile)
+			if got := taskIDs(storage.GetTasks()); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("задачи %v, ожидались %v", got, tt.wantIDs)
+			}
+			if task, _ := storage.GetTask(1); task.GetTitle() != tt.wantTitle {
+				t.Errorf("задача 1 %q, ожидалась %q", task.GetTitle(), tt.wantTitle)
+			}
+		})
+	}
+}
+
+// Пробная сверка перечисляет расхождения, но не трогает файлы и не делает снимок
+func TestReconcileDryRun(t *testing.T) {
+	tasksFile, notesFile := divergedFiles(t)
+	before, _ := os.ReadFile(tasksFile + ".json")
+
+	result, err := ReconcileFiles(tasksFile, notesFile, PreferCSV, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diffs) != 3 || result.Written {
+		t.Errorf("пробная сверка: %v, записано %v", result.Diffs, result.Written)
+	}
+	if after, _ := os.ReadFile(tasksFile + ".json"); !bytes.Equal(before, after) {
+		t.Error("пробная сверка изменила tasks.json")
+	}
+	if fileExists(defaultSnapshotDir(tasksFile)) {
+		t.Error("пробная сверка сделала снимок")
+	}
+
+	if _, err := ReconcileFiles(tasksFile, notesFile, "oldest", true); !model.IsValidationError(err) {
+		t.Errorf("неизвестное правило: ожидалась ошибка валидации, получено %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LoadAction - что хранилище сделало с проблемной записью при загрузке
+type LoadAction string
+
+const (
+	// LoadSkipped - запись пропущена и в память не попала
+	LoadSkipped LoadAction = "skipped"
+	// LoadRepaired - запись загружена, но часть её полей заменена
+	LoadRepaired LoadAction = "repaired"
+	// LoadFailed - файл целиком не удалось прочитать
+	LoadFailed LoadAction = "failed"
+	// LoadRecovered - повреждённый файл восстановлен из второго формата или снимка
+	LoadRecovered LoadAction = "recovered"
+	// LoadDiverged - файл изменён в обход хранилища (не совпадает с манифестом, но читается):
+	// он не перезаписывается, пока расхождение не разрешено через ReconcileFiles
+	LoadDiverged LoadAction = "diverged"
+)
+
+// LoadIssue - одна проблема, найденная при загрузке данных
+type LoadIssue struct {
+	File   string
+	Row    int // номер записи в файле с 1 (для CSV с учётом строки заголовка); 0 - файл целиком
+	Action LoadAction
+	Reason string
+}
+
+// String возвращает описание проблемы в виде "файл:строка: действие: причина"
+func (i LoadIssue) String() string {
+	if i.Row == 0 {
+		return fmt.Sprintf("%s: %s: %s", i.File, i.Action, i.Reason)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", i.File, i.Row, i.Action, i.Reason)
+}
+
+// LoadReport - отчёт о загрузке данных: все пропущенные и исправленные записи
+type LoadReport struct {
+	mu     sync.Mutex
+	issues []LoadIssue
+}
+
+// add добавляет проблему в отчёт; nil-отчёт молча игнорирует записи
+func (r *LoadReport) add(file string, row int, action LoadAction, reason string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issues = append(r.issues, LoadIssue{File: file, Row: row, Action: action, Reason: reason})
+}
+
+// Add добавляет проблему в отчёт; через него сторонние форматы (см. Codec)
+// сообщают о пропущенных и исправленных записях
+func (r *LoadReport) Add(file string, row int, action LoadAction, reason string) {
+	r.add(file, row, action, reason)
+}
+
+// Issues возвращает копию списка проблем
+func (r *LoadReport) Issues() []LoadIssue {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	issues := make([]LoadIssue, len(r.issues))
+	copy(issues, r.issues)
+	return issues
+}
+
+// HasIssues сообщает, были ли при загрузке проблемы
+func (r *LoadReport) HasIssues() bool {
+	return len(r.Issues()) > 0
+}
+
+// recovered сообщает, восстанавливались ли при загрузке повреждённые файлы
+func (r *LoadReport) recovered() bool {
+	for _, issue := range r.Issues() {
+		if issue.Action == LoadRecovered {
+			return true
+		}
+	}
+	return false
+}
+
+// lost сообщает, что коллекцию не удалось загрузить ни из одного файла (например, файл записан
+// более новой версией программы): такие файлы будут перезаписаны при первом сохранении
+func (r *LoadReport) lost() bool {
+	for _, issue := range r.Issues() {
+		if issue.Action == LoadFailed && (issue.File == kindTasks || issue.File == kindNotes) {
+			return true
+		}
+	}
+	return false
+}
+
+// String возвращает отчёт построчно
+func (r *LoadReport) String() string {
+	var sb strings.Builder
+	for _, issue := range r.Issues() {
+		sb.WriteString(issue.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// LoadError возвращается NewStorage в строгом режиме, если при загрузке были проблемы
+type LoadError struct {
+	Report *LoadReport
+}
+
+// Error возвращает число проблем и первую из них
+func (e *LoadError) Error() string {
+	issues := e.Report.Issues()
+	if len(issues) == 0 {
+		return "ошибка загрузки данных"
+	}
+	return fmt.Sprintf("строгая загрузка данных: найдено проблем: %d, первая - %s", len(issues), issues[0])
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// CSV задач, в котором строки 3 и 5 не загружаются, а строки 4 и 6 исправляются
+const reportTasksCSV = `ID,Title,Description,Status,Priority,CreatedAt,UpdatedAt,DueDate
+1,Целая,Описание,todo,medium,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,
+2,Обрезанная,Описание
+3,Со странным статусом,Описание,someday,medium,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,
+4,,Без заголовка,todo,medium,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,
+3,Повтор ID,Описание,todo,medium,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,
+`
+
+// writeReportTasks записывает reportTasksCSV как единственный файл задач
+func writeReportTasks(t *testing.T) (string, string) {
+	t.Helper()
+	tasksFile, notesFile := tempDataFiles(t)
+	if err := os.WriteFile(tasksFile+".csv", []byte(reportTasksCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tasksFile, notesFile
+}
+
+// Каждая проблемная запись попадает в отчёт с номером строки и действием, а целые записи загружаются
+func TestLoadReportRows(t *testing.T) {
+	tasksFile, notesFile := writeReportTasks(t)
+	storage, report := openStorage(t, tasksFile, notesFile, WithFormats(FormatCSV))
+
+	want := map[int]LoadAction{3: LoadSkipped, 4: LoadRepaired, 5: LoadSkipped, 6: LoadRepaired}
+	for _, issue := range report.Issues() {
+		if issue.File != tasksFile+".csv" {
+			continue
+		}
+		if action, ok := want[issue.Row]; !ok || action != issue.Action {
+			t.Errorf("неожиданная проблема %s", issue)
+		}
+		delete(want, issue.Row)
+	}
+	if len(want) != 0 {
+		t.Errorf("в отчёте нет строк %v:\n%s", want, report)
+	}
+	if !strings.Contains(report.String(), tasksFile+".csv:3: skipped: ") {
+		t.Errorf("строка отчёта не в виде файл:строка: действие: причина:\n%s", report)
+	}
+
+	if tasks, _ := storage.Count(); tasks != 3 {
+		t.Errorf("загружено %d задач, ожидалось 3", tasks)
+	}
+	// Запись с повторяющимся ID получила новый ID
+	if task, err := storage.GetTask(4); err != nil || task.GetTitle() != "Повтор ID" {
+		t.Errorf("повтор ID не получил свободный ID: %v", err)
+	}
+}
+
+// В строгом режиме та же загрузка прерывается ошибкой с полным отчётом, а каталог не остаётся заблокированным
+func TestStrictLoad(t *testing.T) {
+	tasksFile, notesFile := writeReportTasks(t)
+	storage, report, err := Open(tasksFile, notesFile, WithFormats(FormatCSV), WithStrictLoad())
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || storage != nil {
+		t.Fatalf("ожидалась *LoadError без хранилища, получено %v", err)
+	}
+	if loadErr.Report != report || len(issuesWith(report, LoadSkipped)) != 2 {
+		t.Errorf("отчёт ошибки неполон:\n%s", loadErr.Report)
+	}
+
+	if data, _ := os.ReadFile(tasksFile + ".csv"); string(data) != reportTasksCSV {
+		t.Error("строгая загрузка изменила файл")
+	}
+	openStorage(t, tasksFile, notesFile, WithFormats(FormatCSV))
+}
@@ -1,567 +1,644 @@
 package repository
 
 import (
-	"encoding/csv"
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"task-manager/internal/model"
 	"time"
 )
 
-// Storage - потокобезопасное хранилище с сохранением в файлы
+// Repository - общий интерфейс хранилища задач и заметок
+type Repository interface {
+	// AddModel добавляет задачу или заметку; модели без ID получают его от хранилища
+	AddModel(m interface{}) error
+	// GetTask возвращает задачу по ID
+	GetTask(id int) (*model.Task, error)
+	// UpdateTask заменяет сохранённую задачу с тем же ID, если её версия не изменилась с момента чтения
+	UpdateTask(task *model.Task) error
+	// DeleteTask перемещает задачу в корзину
+	DeleteTask(id int) error
+	// GetNote возвращает заметку по ID
+	GetNote(id int) (*model.Note, error)
+	// UpdateNote заменяет сохранённую заметку с тем же ID, если её версия не изменилась с момента чтения
+	UpdateNote(note *model.Note) error
+	// DeleteNote перемещает заметку в корзину
+	DeleteNote(id int) error
+	// GetTasks возвращает все задачи, кроме удалённых в корзину
+	GetTasks() []*model.Task
+	// GetNotes возвращает все заметки, кроме удалённых в корзину
+	GetNotes() []*model.Note
+	// ListTasks возвращает страницу задач
+	ListTasks(options ListOptions) (Page[*model.Task], error)
+	// ListNotes возвращает страницу заметок
+	ListNotes(options ListOptions) (Page[*model.Note], error)
+	// GetTasksByStatus возвращает задачи с указанным статусом
+	GetTasksByStatus(status model.TaskStatus) []*model.Task
+	// GetTasksByPriority возвращает задачи с указанным приоритетом
+	GetTasksByPriority(priority model.TaskPriority) []*model.Task
+	// GetTasksDueBetween возвращает задачи со сроком в интервале [from, to]
+	GetTasksDueBetween(from, to time.Time) []*model.Task
+	// Count возвращает количество задач и заметок без учёта корзины
+	Count() (int, int)
+	// GetDeletedTasks возвращает задачи в корзине
+	GetDeletedTasks() []*model.Task
+	// GetDeletedNotes возвращает заметки в корзине
+	GetDeletedNotes() []*model.Note
+	// RestoreTask возвращает задачу из корзины
+	RestoreTask(id int) error
+	// RestoreNote возвращает заметку из корзины
+	RestoreNote(id int) error
+	// PurgeDeleted окончательно удаляет записи, пролежавшие в корзине дольше maxAge
+	PurgeDeleted(maxAge time.Duration) (int, int, error)
+	// GetNewTasks возвращает задачи, добавленные после индекса lastIndex
+	GetNewTasks(lastIndex int) []*model.Task
+	// GetNewNotes возвращает заметки, добавленные после индекса lastIndex
+	GetNewNotes(lastIndex int) []*model.Note
+	// Subscribe возвращает канал событий об изменениях задач и заметок до отмены ctx
+	Subscribe(ctx context.Context, options SubscribeOptions) <-chan ChangeEvent
+	// SaveAll сохраняет все данные
+	SaveAll() error
+	// Close сохраняет данные, освобождает ресурсы и возвращает ошибку сохранения
+	Close() error
+	// Cleanup - прежнее имя Close
+	Cleanup() error
+}
+
+// Storage - потокобезопасное хранилище поверх подключаемого бэкенда
 type Storage struct {
 	tasks []*model.Task
 	notes []*model.Note
 	mu    sync.RWMutex
-	
-	tasksFile string
-	notesFile string
+
+	// Индексы коллекций (см. index.go), защищены s.mu
+	taskIndex *taskIndex
+	noteIndex *noteIndex
+
+	// Снимок коллекций для чтения без блокировок (см. view.go)
+	view atomic.Pointer[storageView]
+
+	// Последние выданные ID задач и заметок
+	taskSeq int
+	noteSeq int
+
+	backend Backend
+
+	// Блокировки каталогов данных и режим только для чтения
+	locks    []*dirLock
+	readOnly bool
+
+	// Все записи в бэкенд выполняются строго по очереди под saveMu; порядок блокировок - saveMu, затем mu.
+	// Изменения берут обе блокировки, поэтому запись снимка в flush не пересекается с синхронной записью
+	// изменения (в том числе с дописыванием в журнал, которое сжатие журнала иначе бы стёрло)
+	saveMu sync.Mutex
+
+	// Состояние отложенного сохранения (см. autosave.go), защищено s.mu
+	autosave     *autosaveWorker
+	tasksDirty   bool
+	notesDirty   bool
+	lastSaveTime time.Time
+	lastSaveErr  error
+
+	// Файлы данных и настройки снимков (см. snapshot.go); пусты для хранилищ без файлов
+	tasksFile   string
+	notesFile   string
+	snapshots   SnapshotOptions
+	snapshotter *snapshotScheduler
+	// Ошибка последнего снимка по расписанию, защищена s.mu
+	lastSnapshotErr error
+
+	// Шифр файлов данных (см. crypto.go); nil - файлы не шифруются
+	cipher *fileCipher
+
+	// Файловый бэкенд под журналом и наблюдение за внешними изменениями (см. watcher.go)
+	files      *FileBackend
+	watcher    *fileWatcher
+	subsMu     sync.Mutex
+	reloadSubs map[chan ReloadEvent]struct{}
+
+	// Подписчики на изменения (см. changes.go), защищены s.subsMu
+	changeSubs map[*changeSubscriber]struct{}
+
+	// Закрытие хранилища (см. lifecycle.go); closed меняется под s.saveMu и s.mu
+	closed    atomic.Bool
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{} // закрывается в Close
 }
 
-// NewStorage создаёт новое хранилище с указанием файлов для сохранения
-func NewStorage(tasksFile, notesFile string) *Storage {
-	storage := &Storage{
-		tasks:     make([]*model.Task, 0),
-		notes:     make([]*model.Note, 0),
-		tasksFile: tasksFile,
-		notesFile: notesFile,
-	}
-	
-	// Загружаем данные из файлов при создании
-	storage.loadFromFiles()
-	
+// Проверка, что Storage реализует Repository
+var _ Repository = (*Storage)(nil)
+
+// Open открывает хранилище с указанием файлов для сохранения (без расширения;
+// форматы файлов - JSON и CSV или заданные через WithFormats).
+// Каталог данных блокируется от других процессов до вызова Close;
+// если он уже занят, возвращается *LockError с PID владельца.
+// Отчёт о загрузке перечисляет все пропущенные и исправленные записи;
+// в строгом режиме (WithStrictLoad) любая проблема возвращается как *LoadError.
+// Файлы, не прошедшие проверку по манифесту контрольных сумм, восстанавливаются
+// из второго формата или последнего целого снимка и сразу перезаписываются (после снимка "pre-recovery");
+// файлы, изменённые в обход хранилища, не перезаписываются до сверки (см. LoadDiverged).
+// Если коллекцию не удалось загрузить совсем, её файлы тоже сохраняются в снимке "pre-recovery"
+func Open(tasksFile, notesFile string, opts ...Option) (*Storage, *LoadReport, error) {
+	options := newStorageOptions(tasksFile, opts)
+
+	var locks []*dirLock
+	if !options.readOnly {
+		var err error
+		if locks, err = lockDirs(tasksFile, notesFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	files, err := options.fileBackend(tasksFile, notesFile)
+	if err != nil {
+		unlockDirs(locks)
+		return nil, nil, err
+	}
+	cipher := files.cipher
+	var backend Backend = files
+	if options.journal != nil {
+		journal := NewJournalBackend(backend, tasksFile+".journal", notesFile+".journal", *options.journal)
+		journal.setCipher(cipher)
+		backend = journal
+	}
+
+	storage, report := newStorage(backend, options.readOnly)
+	if options.strict && report.HasIssues() {
+		unlockDirs(locks)
+		return nil, report, &LoadError{Report: report}
+	}
+
+	storage.locks = locks
+	storage.tasksFile = tasksFile
+	storage.notesFile = notesFile
+	storage.snapshots = options.snapshots
+	storage.cipher = cipher
+	storage.files = files
+
+	// Восстановленные при загрузке файлы сразу перезаписываются целыми данными, а незагруженные
+	// перезапишет первое же сохранение. Перед этим делается снимок: повреждение могло оказаться
+	// правкой, которую стоит разобрать вручную, а нечитаемый файл - записью более новой версии программы
+	if !storage.readOnly && (report.recovered() || report.lost()) {
+		if _, err := takeSnapshot(tasksFile, notesFile, options.snapshots.Dir, "pre-recovery"); err != nil {
+			unlockDirs(locks)
+			return nil, report, fmt.Errorf("ошибка снимка перед восстановлением файлов: %w", err)
+		}
+	}
+
+	// Журнал, переживший порог возраста, пока хранилище было закрыто, сворачивается сразу,
+	// а не при первом изменении
+	if !storage.readOnly && !report.recovered() {
+		if err := storage.flush(false); err != nil {
+			unlockDirs(locks)
+			return nil, report, fmt.Errorf("ошибка свёртки журнала: %w", err)
+		}
+	}
+	if !storage.readOnly && report.recovered() {
+		if err := storage.SaveAll(); err != nil {
+			unlockDirs(locks)
+			return nil, report, fmt.Errorf("ошибка перезаписи восстановленных файлов: %w", err)
+		}
+	}
+	return storage, report, nil
+}
+
+// NewStorage открывает хранилище так же, как Open
+//
+// Deprecated: используйте Open
+func NewStorage(tasksFile, notesFile string, opts ...Option) (*Storage, *LoadReport, error) {
+	return Open(tasksFile, notesFile, opts...)
+}
+
+// NewMemoryStorage создаёт хранилище, которое держит данные только в памяти
+func NewMemoryStorage() *Storage {
+	storage, _ := NewStorageWithBackend(NewMemoryBackend())
 	return storage
 }
 
-// AddModel добавляет модель в соответствующий слайс и сохраняет в файл
+// NewStorageWithBackend создаёт хранилище поверх произвольного бэкенда и возвращает отчёт о загрузке
+func NewStorageWithBackend(backend Backend) (*Storage, *LoadReport) {
+	return newStorage(backend, false)
+}
+
+// newStorage создаёт хранилище и загружает данные; хранилище только для чтения
+// при загрузке ничего не записывает в бэкенд
+func newStorage(backend Backend, readOnly bool) (*Storage, *LoadReport) {
+	storage := &Storage{
+		tasks:    make([]*model.Task, 0),
+		notes:    make([]*model.Note, 0),
+		backend:  backend,
+		readOnly: readOnly,
+		done:     make(chan struct{}),
+	}
+
+	// Загружаем данные из бэкенда при создании
+	report := &LoadReport{}
+	storage.loadFromFiles(report)
+
+	return storage, report
+}
+
+// AddModel добавляет модель в соответствующий слайс и сохраняет в бэкенд
+// Модель с нулевым ID получает следующий ID из последовательности,
+// модель с уже занятым ID отклоняется. Хранилище держит собственную копию модели с версией 1;
+// ID и версия записываются в m только после успешного сохранения
 func (s *Storage) AddModel(m interface{}) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
+	if err := s.prepareWriteLocked(); err != nil {
+		return err
+	}
+
 	switch v := m.(type) {
 	case *model.Task:
-		s.tasks = append(s.tasks, v)
-		// Сохраняем задачи в файл
-		if err := s.saveTasksToFile(); err != nil {
+		stored := v.Clone()
+		if err := s.assignTaskID(stored); err != nil {
+			return err
+		}
+		stored.SetVersion(1)
+		// Сохраняем задачи в бэкенд и только потом показываем задачу читателям и подписчикам
+		undo := s.insertTaskLocked(stored)
+		if err := s.persistTask(ChangeCreated, stored); err != nil {
+			undo()
 			return fmt.Errorf("ошибка сохранения задач: %w", err)
 		}
+		s.storeViewLocked()
+		s.publishTask(ChangeCreated, nil, stored)
+		v.SetID(stored.GetID())
+		v.SetVersion(1)
 		return nil
 	case *model.Note:
-		s.notes = append(s.notes, v)
-		// Сохраняем заметки в файл
-		if err := s.saveNotesToFile(); err != nil {
+		stored := v.Clone()
+		if err := s.assignNoteID(stored); err != nil {
+			return err
+		}
+		stored.SetVersion(1)
+		// Сохраняем заметки в бэкенд и только потом показываем заметку читателям и подписчикам
+		undo := s.insertNoteLocked(stored)
+		if err := s.persistNote(ChangeCreated, stored); err != nil {
+			undo()
 			return fmt.Errorf("ошибка сохранения заметок: %w", err)
 		}
+		s.storeViewLocked()
+		s.publishNote(ChangeCreated, nil, stored)
+		v.SetID(stored.GetID())
+		v.SetVersion(1)
 		return nil
 	default:
 		return model.NewValidationError("unknown model type")
 	}
 }
 
-// SaveAll сохраняет все данные во все файлы
-func (s *Storage) SaveAll() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	if err := s.saveTasksToFile(); err != nil {
-		return fmt.Errorf("ошибка сохранения задач: %w", err)
+// GetTask возвращает копию задачи по ID или *model.NotFoundError.
+// Изменения копии применяются только через UpdateTask. Читает последний опубликованный снимок и не ждёт писателей
+func (s *Storage) GetTask(id int) (*model.Task, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
 	}
-	
-	if err := s.saveNotesToFile(); err != nil {
-		return fmt.Errorf("ошибка сохранения заметок: %w", err)
+	task, ok := s.loadView().activeTask(id)
+	if !ok {
+		return nil, model.NewNotFoundError("task", id)
 	}
-	
-	return nil
+	return task.Clone(), nil
 }
 
-// loadFromFiles загружает данные из файлов при старте
-func (s *Storage) loadFromFiles() {
-	// Загружаем задачи
-	if err := s.loadTasksFromFile(); err != nil {
-		fmt.Printf("Ошибка загрузки задач: %v\n", err)
+// UpdateTask заменяет задачу с тем же ID и сохраняет задачи в бэкенд.
+// Версия task должна совпадать с сохранённой, иначе возвращается *model.ConflictError:
+// задачу изменили после того, как вызывающий её прочитал. При успехе версия task увеличивается
+func (s *Storage) UpdateTask(task *model.Task) error {
+	if s.readOnly {
+		return ErrReadOnly
 	}
-	
-	// Загружаем заметки
-	if err := s.loadNotesFromFile(); err != nil {
-		fmt.Printf("Ошибка загрузки заметок: %v\n", err)
+	if task == nil {
+		return model.NewValidationError("task cannot be nil")
 	}
-}
-
-// ========== Методы для работы с задачами ==========
 
-// saveTasksToFile сохраняет задачи в файлы (CSV и JSON)
-func (s *Storage) saveTasksToFile() error {
-	// Сохраняем в CSV
-	if err := s.saveTasksToCSV(); err != nil {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
 		return err
 	}
-	
-	// Сохраняем в JSON
-	return s.saveTasksToJSON()
-}
 
-// saveTasksToCSV сохраняет задачи в CSV файл
-func (s *Storage) saveTasksToCSV() error {
-	file, err := os.Create(s.tasksFile + ".csv")
-	if err != nil {
-		return err
+	i := s.findActiveTask(task.GetID())
+	if i < 0 {
+		return model.NewNotFoundError("task", task.GetID())
 	}
-	defer file.Close()
-	
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-	
-	// Записываем заголовки
-	headers := []string{"ID", "Title", "Description", "Status", "Priority", "CreatedAt", "UpdatedAt", "DueDate"}
-	if err := writer.Write(headers); err != nil {
+
+	old := s.tasks[i]
+	stored, err := nextTaskVersion(old, task)
+	if err != nil {
 		return err
 	}
-	
-	// Записываем данные задач
-	for _, task := range s.tasks {
-		var dueDateStr string
-		if dueDate := task.GetDueDate(); dueDate != nil {
-			dueDateStr = dueDate.Format(time.RFC3339)
-		}
-		
-		record := []string{
-			strconv.Itoa(task.GetID()),
-			task.GetTitle(),
-			task.GetDescription(),
-			string(task.GetStatus()),
-			string(task.GetPriority()),
-			task.GetCreatedAt().Format(time.RFC3339),
-			task.GetUpdatedAt().Format(time.RFC3339),
-			dueDateStr,
-		}
-		
-		if err := writer.Write(record); err != nil {
-			return err
-		}
+	undo := s.replaceTaskLocked(i, stored)
+	if err := s.persistTask(ChangeUpdated, stored); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения задач: %w", err)
 	}
-	
+	s.storeViewLocked()
+	s.publishTask(ChangeUpdated, old, stored)
+	task.SetVersion(stored.GetVersion())
 	return nil
 }
 
-// saveTasksToJSON сохраняет задачи в JSON файл
-func (s *Storage) saveTasksToJSON() error {
-	file, err := os.Create(s.tasksFile + ".json")
-	if err != nil {
+// DeleteTask перемещает задачу в корзину и сохраняет задачи в бэкенд.
+// Задача пропадает из списков и поиска, но её можно вернуть через RestoreTask,
+// пока она не удалена окончательно через PurgeDeleted
+func (s *Storage) DeleteTask(id int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
 		return err
 	}
-	defer file.Close()
-	
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	
-	// Создаем структуру для JSON сериализации
-	type jsonTask struct {
-		ID          int        `json:"id"`
-		Title       string     `json:"title"`
-		Description string     `json:"description"`
-		Status      string     `json:"status"`
-		Priority    string     `json:"priority"`
-		CreatedAt   time.Time  `json:"created_at"`
-		UpdatedAt   time.Time  `json:"updated_at"`
-		DueDate     *time.Time `json:"due_date,omitempty"`
-	}
-	
-	var jsonTasks []jsonTask
-	for _, task := range s.tasks {
-		jsonTasks = append(jsonTasks, jsonTask{
-			ID:          task.GetID(),
-			Title:       task.GetTitle(),
-			Description: task.GetDescription(),
-			Status:      string(task.GetStatus()),
-			Priority:    string(task.GetPriority()),
-			CreatedAt:   task.GetCreatedAt(),
-			UpdatedAt:   task.GetUpdatedAt(),
-			DueDate:     task.GetDueDate(),
-		})
-	}
-	
-	return encoder.Encode(jsonTasks)
+
+	i := s.findActiveTask(id)
+	if i < 0 {
+		return model.NewNotFoundError("task", id)
+	}
+
+	old := s.tasks[i]
+	trashed := trashedTask(old, time.Now())
+	undo := s.replaceTaskLocked(i, trashed)
+	if err := s.persistTask(ChangeUpdated, trashed); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения задач: %w", err)
+	}
+	s.storeViewLocked()
+	s.publishTask(ChangeDeleted, old, trashed)
+	return nil
 }
 
-// loadTasksFromFile загружает задачи из файлов
-func (s *Storage) loadTasksFromFile() error {
-	// Сначала пробуем загрузить из JSON
-	if err := s.loadTasksFromJSON(); err == nil {
-		return nil
+// GetNote возвращает копию заметки по ID или *model.NotFoundError.
+// Изменения копии применяются только через UpdateNote. Читает последний опубликованный снимок и не ждёт писателей
+func (s *Storage) GetNote(id int) (*model.Note, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
 	}
-	
-	// Если не получилось, загружаем из CSV
-	return s.loadTasksFromCSV()
+	note, ok := s.loadView().activeNote(id)
+	if !ok {
+		return nil, model.NewNotFoundError("note", id)
+	}
+	return note.Clone(), nil
 }
 
-// loadTasksFromCSV загружает задачи из CSV файла
-func (s *Storage) loadTasksFromCSV() error {
-	file, err := os.Open(s.tasksFile + ".csv")
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Файл не существует - это нормально при первом запуске
-		}
-		return err
+// UpdateNote заменяет заметку с тем же ID и сохраняет заметки в бэкенд.
+// Версия note должна совпадать с сохранённой, иначе возвращается *model.ConflictError
+func (s *Storage) UpdateNote(note *model.Note) error {
+	if s.readOnly {
+		return ErrReadOnly
 	}
-	defer file.Close()
-	
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return err
+	if note == nil {
+		return model.NewValidationError("note cannot be nil")
 	}
-	
-	// Пропускаем заголовок
-	if len(records) <= 1 {
-		return nil
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
+		return err
 	}
-	
-	for _, record := range records[1:] {
-		if len(record) < 8 {
-			continue
-		}
-		
-		id, _ := strconv.Atoi(record[0])
-		title := record[1]
-		description := record[2]
-		status := model.TaskStatus(record[3])
-		priority := model.TaskPriority(record[4])
-		
-		var dueDate *time.Time
-		if record[7] != "" {
-			parsedDate, err := time.Parse(time.RFC3339, record[7])
-			if err == nil {
-				dueDate = &parsedDate
-			}
-		}
-		
-		task, err := model.NewTask(title, description, priority, dueDate)
-		if err != nil {
-			fmt.Printf("Ошибка создания задачи из CSV: %v\n", err)
-			continue
-		}
-		
-		task.SetID(id)
-		task.SetStatus(status)
-		
-		// Устанавливаем даты из файла
-		if createdAt, err := time.Parse(time.RFC3339, record[5]); err == nil {
-			task.SetCreatedAt(createdAt)
-		}
-		
-		if updatedAt, err := time.Parse(time.RFC3339, record[6]); err == nil {
-			task.SetUpdatedAt(updatedAt)
-		}
-		
-		s.tasks = append(s.tasks, task)
+
+	i := s.findActiveNote(note.GetID())
+	if i < 0 {
+		return model.NewNotFoundError("note", note.GetID())
 	}
-	
-	return nil
-}
 
-// loadTasksFromJSON загружает задачи из JSON файла
-func (s *Storage) loadTasksFromJSON() error {
-	file, err := os.Open(s.tasksFile + ".json")
+	old := s.notes[i]
+	stored, err := nextNoteVersion(old, note)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
-	defer file.Close()
-	
-	// Структура для десериализации
-	type jsonTask struct {
-		ID          int        `json:"id"`
-		Title       string     `json:"title"`
-		Description string     `json:"description"`
-		Status      string     `json:"status"`
-		Priority    string     `json:"priority"`
-		CreatedAt   time.Time  `json:"created_at"`
-		UpdatedAt   time.Time  `json:"updated_at"`
-		DueDate     *time.Time `json:"due_date,omitempty"`
-	}
-	
-	var jsonTasks []jsonTask
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&jsonTasks); err != nil {
-		return err
+	undo := s.replaceNoteLocked(i, stored)
+	if err := s.persistNote(ChangeUpdated, stored); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения заметок: %w", err)
 	}
-	
-	for _, jt := range jsonTasks {
-		task, err := model.NewTask(jt.Title, jt.Description, model.TaskPriority(jt.Priority), jt.DueDate)
-		if err != nil {
-			fmt.Printf("Ошибка создания задачи из JSON: %v\n", err)
-			continue
-		}
-		
-		task.SetID(jt.ID)
-		task.SetStatus(model.TaskStatus(jt.Status))
-		task.SetCreatedAt(jt.CreatedAt)
-		task.SetUpdatedAt(jt.UpdatedAt)
-		
-		s.tasks = append(s.tasks, task)
-	}
-	
+	s.storeViewLocked()
+	s.publishNote(ChangeUpdated, old, stored)
+	note.SetVersion(stored.GetVersion())
 	return nil
 }
 
-// ========== Методы для работы с заметками ==========
+// DeleteNote перемещает заметку в корзину и сохраняет заметки в бэкенд (см. DeleteTask)
+func (s *Storage) DeleteNote(id int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
 
-// saveNotesToFile сохраняет заметки в файлы (CSV и JSON)
-func (s *Storage) saveNotesToFile() error {
-	// Сохраняем в CSV
-	if err := s.saveNotesToCSV(); err != nil {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
 		return err
 	}
-	
-	// Сохраняем в JSON
-	return s.saveNotesToJSON()
+
+	i := s.findActiveNote(id)
+	if i < 0 {
+		return model.NewNotFoundError("note", id)
+	}
+
+	old := s.notes[i]
+	trashed := trashedNote(old, time.Now())
+	undo := s.replaceNoteLocked(i, trashed)
+	if err := s.persistNote(ChangeUpdated, trashed); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения заметок: %w", err)
+	}
+	s.storeViewLocked()
+	s.publishNote(ChangeDeleted, old, trashed)
+	return nil
 }
 
-// saveNotesToCSV сохраняет заметки в CSV файл
-func (s *Storage) saveNotesToCSV() error {
-	file, err := os.Create(s.notesFile + ".csv")
-	if err != nil {
-		return err
+// findTask возвращает индекс задачи с указанным ID или -1
+// Вызывается под блокировкой s.mu
+func (s *Storage) findTask(id int) int {
+	if i, ok := s.taskIndex.byID[id]; ok {
+		return i
 	}
-	defer file.Close()
-	
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-	
-	// Записываем заголовки
-	headers := []string{"ID", "Title", "Content", "Category", "CreatedAt", "UpdatedAt"}
-	if err := writer.Write(headers); err != nil {
-		return err
+	return -1
+}
+
+// findNote возвращает индекс заметки с указанным ID или -1
+// Вызывается под блокировкой s.mu
+func (s *Storage) findNote(id int) int {
+	if i, ok := s.noteIndex.byID[id]; ok {
+		return i
 	}
-	
-	// Записываем данные заметок
-	for _, note := range s.notes {
-		record := []string{
-			strconv.Itoa(note.GetID()),
-			note.GetTitle(),
-			note.GetContent(),
-			note.GetCategory(),
-			note.GetCreatedAt().Format(time.RFC3339),
-			note.GetUpdatedAt().Format(time.RFC3339),
-		}
-		
-		if err := writer.Write(record); err != nil {
-			return err
-		}
+	return -1
+}
+
+// findActiveTask возвращает индекс задачи с указанным ID, если она не в корзине, или -1
+// Вызывается под блокировкой s.mu
+func (s *Storage) findActiveTask(id int) int {
+	if i := s.findTask(id); i >= 0 && !s.tasks[i].IsDeleted() {
+		return i
 	}
-	
-	return nil
+	return -1
 }
 
-// saveNotesToJSON сохраняет заметки в JSON файл
-func (s *Storage) saveNotesToJSON() error {
-	file, err := os.Create(s.notesFile + ".json")
-	if err != nil {
-		return err
+// findActiveNote возвращает индекс заметки с указанным ID, если она не в корзине, или -1
+// Вызывается под блокировкой s.mu
+func (s *Storage) findActiveNote(id int) int {
+	if i := s.findNote(id); i >= 0 && !s.notes[i].IsDeleted() {
+		return i
 	}
-	defer file.Close()
-	
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	
-	// Структура для JSON сериализации
-	type jsonNote struct {
-		ID        int       `json:"id"`
-		Title     string    `json:"title"`
-		Content   string    `json:"content"`
-		Category  string    `json:"category"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-	}
-	
-	var jsonNotes []jsonNote
-	for _, note := range s.notes {
-		jsonNotes = append(jsonNotes, jsonNote{
-			ID:        note.GetID(),
-			Title:     note.GetTitle(),
-			Content:   note.GetContent(),
-			Category:  note.GetCategory(),
-			CreatedAt: note.GetCreatedAt(),
-			UpdatedAt: note.GetUpdatedAt(),
-		})
-	}
-	
-	return encoder.Encode(jsonNotes)
+	return -1
 }
 
-// loadNotesFromFile загружает заметки из файлов
-func (s *Storage) loadNotesFromFile() error {
-	// Сначала пробуем загрузить из JSON
-	if err := s.loadNotesFromJSON(); err == nil {
-		return nil
+// prepareWriteLocked проверяет, что хранилище открыто, и подхватывает внешние правки файлов
+// до изменения, чтобы оно применялось к актуальным данным и не перезаписало их при сохранении
+// Вызывается под блокировками s.saveMu и s.mu
+func (s *Storage) prepareWriteLocked() error {
+	if err := s.checkOpen(); err != nil {
+		return err
 	}
-	
-	// Если не получилось, загружаем из CSV
-	return s.loadNotesFromCSV()
+	return s.syncExternalLocked()
 }
 
-// loadNotesFromCSV загружает заметки из CSV файла
-func (s *Storage) loadNotesFromCSV() error {
-	file, err := os.Open(s.notesFile + ".csv")
-	if err != nil {
-		if os.IsNotExist(err) {
+// persistTask сохраняет изменение задачи: журналируемый бэкенд получает одну запись
+// и сворачивает журнал в снимок по достижении порога, остальные перезаписывают все задачи.
+// В режиме автосохранения обычный бэкенд только помечает задачи изменёнными, а журнал
+// по-прежнему дописывается сразу - свёртку журнала выполняет фоновая горутина
+// Вызывается под блокировками s.saveMu и s.mu после prepareWriteLocked
+func (s *Storage) persistTask(op ChangeOp, task *model.Task) error {
+	inc, ok := s.backend.(IncrementalBackend)
+	if !ok {
+		if s.autosave != nil {
+			s.markDirty(true, false)
 			return nil
 		}
-		return err
+		return s.backend.SaveTasks(s.tasks)
 	}
-	defer file.Close()
-	
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
+
+	if err := inc.AppendTask(op, task); err != nil {
 		return err
 	}
-	
-	if len(records) <= 1 {
-		return nil
-	}
-	
-	for _, record := range records[1:] {
-		if len(record) < 6 {
-			continue
-		}
-		
-		id, _ := strconv.Atoi(record[0])
-		title := record[1]
-		content := record[2]
-		category := model.NoteCategory(record[3])
-		
-		note := model.NewNote(title, content, category)
-		note.SetID(id)
-		
-		// Устанавливаем даты из файла
-		if createdAt, err := time.Parse(time.RFC3339, record[4]); err == nil {
-			note.SetCreatedAt(createdAt)
-		}
-		
-		if updatedAt, err := time.Parse(time.RFC3339, record[5]); err == nil {
-			note.SetUpdatedAt(updatedAt)
+	if inc.TasksNeedCompaction() {
+		if s.autosave != nil {
+			s.autosave.wake()
+			return nil
 		}
-		
-		s.notes = append(s.notes, note)
+		return inc.SaveTasks(s.tasks)
 	}
-	
 	return nil
 }
 
-// loadNotesFromJSON загружает заметки из JSON файла
-func (s *Storage) loadNotesFromJSON() error {
-	file, err := os.Open(s.notesFile + ".json")
-	if err != nil {
-		if os.IsNotExist(err) {
+// persistNote сохраняет изменение заметки аналогично persistTask
+// Вызывается под блокировками s.saveMu и s.mu после prepareWriteLocked
+func (s *Storage) persistNote(op ChangeOp, note *model.Note) error {
+	inc, ok := s.backend.(IncrementalBackend)
+	if !ok {
+		if s.autosave != nil {
+			s.markDirty(false, true)
 			return nil
 		}
-		return err
+		return s.backend.SaveNotes(s.notes)
 	}
-	defer file.Close()
-	
-	// Структура для десериализации
-	type jsonNote struct {
-		ID        int       `json:"id"`
-		Title     string    `json:"title"`
-		Content   string    `json:"content"`
-		Category  string    `json:"category"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-	}
-	
-	var jsonNotes []jsonNote
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&jsonNotes); err != nil {
+
+	if err := inc.AppendNote(op, note); err != nil {
 		return err
 	}
-	
-	for _, jn := range jsonNotes {
-		note := model.NewNote(jn.Title, jn.Content, model.NoteCategory(jn.Category))
-		note.SetID(jn.ID)
-		note.SetCreatedAt(jn.CreatedAt)
-		note.SetUpdatedAt(jn.UpdatedAt)
-		
-		s.notes = append(s.notes, note)
+	if inc.NotesNeedCompaction() {
+		if s.autosave != nil {
+			s.autosave.wake()
+			return nil
+		}
+		return inc.SaveNotes(s.notes)
 	}
-	
 	return nil
 }
 
-// ========== Существующие методы (без изменений) ==========
+// SaveAll сохраняет все данные в бэкенд
+func (s *Storage) SaveAll() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.flush(true)
+}
 
-// GetTasks возвращает копию слайса с задачами
+// loadFromFiles загружает данные из бэкенда при старте, собирая проблемы в report
+func (s *Storage) loadFromFiles(report *LoadReport) {
+	// Загружаем задачи
+	tasks, err := s.backend.LoadTasks(report)
+	if err != nil {
+		report.add(kindTasks, 0, LoadFailed, err.Error())
+	}
+	s.tasks = append(s.tasks, tasks...)
+
+	// Загружаем заметки
+	notes, err := s.backend.LoadNotes(report)
+	if err != nil {
+		report.add(kindNotes, 0, LoadFailed, err.Error())
+	}
+	s.notes = append(s.notes, notes...)
+
+	// Восстанавливаем последовательности ID и заменяем повторяющиеся ID
+	s.restoreSequences(report)
+	s.reindexLocked()
+}
+
+// GetTasks возвращает копии всех задач; для больших хранилищ удобнее ListTasks.
+// Читает последний опубликованный снимок и не ждёт писателей (см. view.go)
 func (s *Storage) GetTasks() []*model.Task {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	tasks := make([]*model.Task, len(s.tasks))
-	copy(tasks, s.tasks)
-	return tasks
+	return cloneTasks(s.loadView().tasks, false)
 }
 
-// GetNotes возвращает копию слайса с заметками
+// GetNotes возвращает копии всех заметок; для больших хранилищ удобнее ListNotes.
+// Читает последний опубликованный снимок и не ждёт писателей
 func (s *Storage) GetNotes() []*model.Note {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	notes := make([]*model.Note, len(s.notes))
-	copy(notes, s.notes)
-	return notes
+	return cloneNotes(s.loadView().notes, false)
 }
 
-// Count возвращает количество моделей каждого типа
+// Count возвращает количество моделей каждого типа без учёта корзины, не ожидая писателей
 func (s *Storage) Count() (int, int) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.tasks), len(s.notes)
+	view := s.loadView()
+	return view.activeTasks, view.activeNotes
 }
 
 // GetNewTasks возвращает задачи, добавленные после определённого индекса
+//
+// Deprecated: индексы сдвигаются после удалений, а изменения не видны; используйте Subscribe
 func (s *Storage) GetNewTasks(lastIndex int) []*model.Task {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	if lastIndex >= len(s.tasks) {
+	tasks := s.loadView().tasks
+	if lastIndex >= len(tasks) {
 		return []*model.Task{}
 	}
-	
-	newTasks := s.tasks[lastIndex:]
-	result := make([]*model.Task, len(newTasks))
-	copy(result, newTasks)
-	return result
+
+	return cloneTasks(tasks[lastIndex:], false)
 }
 
 // GetNewNotes возвращает заметки, добавленные после определённого индекса
+//
+// Deprecated: индексы сдвигаются после удалений, а изменения не видны; используйте Subscribe
 func (s *Storage) GetNewNotes(lastIndex int) []*model.Note {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	if lastIndex >= len(s.notes) {
+	notes := s.loadView().notes
+	if lastIndex >= len(notes) {
 		return []*model.Note{}
 	}
-	
-	newNotes := s.notes[lastIndex:]
-	result := make([]*model.Note, len(newNotes))
-	copy(result, newNotes)
-	return result
+
+	return cloneNotes(notes[lastIndex:], false)
 }
 
-// Cleanup освобождает ресурсы и сохраняет данные перед завершением
-func (s *Storage) Cleanup() {
-	// Сохраняем все данные перед завершением
-	if err := s.SaveAll(); err != nil {
-		fmt.Printf("Ошибка сохранения данных при завершении: %v\n", err)
-	}
-	
-	// Очищаем слайсы
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.tasks = nil
-	s.notes = nil
-}
\ No newline at end of file
+// Cleanup закрывает хранилище так же, как Close, и возвращает ошибку сохранения
+//
+// Deprecated: используйте Close
+func (s *Storage) Cleanup() error {
+	return s.Close()
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+func TestUpdateDeleteErrors(t *testing.T) {
+	storage := NewMemoryStorage()
+	task := addTask(t, storage, "Задача")
+	if err := storage.DeleteTask(task.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	missing := newBenchmarkTask(t, 9)
+	missing.SetID(9)
+
+	tests := []struct {
+		name  string
+		call  func() error
+		check func(error) bool
+	}{
+		{"изменение несуществующей задачи", func() error { return storage.UpdateTask(missing) }, model.IsNotFoundError},
+		{"изменение задачи в корзине", func() error { return storage.UpdateTask(task) }, model.IsNotFoundError},
+		{"удаление задачи в корзине", func() error { return storage.DeleteTask(task.GetID()) }, model.IsNotFoundError},
+		{"удаление несуществующей заметки", func() error { return storage.DeleteNote(1) }, model.IsNotFoundError},
+		{"пустая задача", func() error { return storage.UpdateTask(nil) }, model.IsValidationError},
+		{"пустая заметка", func() error { return storage.UpdateNote(nil) }, model.IsValidationError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !tt.check(err) {
+				t.Errorf("неожиданная ошибка %v", err)
+			}
+		})
+	}
+}
+
+// Изменение, которое не удалось сохранить, не видно ни в хранилище, ни в модели вызывающего
+func TestFailedWriteIsRolledBack(t *testing.T) {
+	backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+	storage, _ := NewStorageWithBackend(backend)
+	task := addTask(t, storage, "Исходная")
+	backend.fail = true
+
+	if err := task.SetTitle("Изменённая"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateTask(task); !errors.Is(err, errBackendFailed) {
+		t.Fatalf("UpdateTask: ожидалась ошибка бэкенда, получено %v", err)
+	}
+	if task.GetVersion() != 1 {
+		t.Errorf("версия модели вызывающего изменилась: %d", task.GetVersion())
+	}
+	if err := storage.DeleteTask(task.GetID()); !errors.Is(err, errBackendFailed) {
+		t.Fatalf("DeleteTask: ожидалась ошибка бэкенда, получено %v", err)
+	}
+
+	stored, err := storage.GetTask(task.GetID())
+	if err != nil {
+		t.Fatalf("задача пропала после неудачного удаления: %v", err)
+	}
+	if stored.GetTitle() != "Исходная" || stored.GetVersion() != 1 {
+		t.Errorf("в хранилище %q v%d, ожидалась исходная задача", stored.GetTitle(), stored.GetVersion())
+	}
+
+	// После восстановления бэкенда то же изменение проходит
+	backend.fail = false
+	if err := storage.UpdateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := storage.GetTask(task.GetID()); stored.GetTitle() != "Изменённая" {
+		t.Errorf("после повтора в хранилище %q", stored.GetTitle())
+	}
+}
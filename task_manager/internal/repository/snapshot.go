@@ -0,0 +1,529 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"task-manager/internal/model"
+	"time"
+)
+
+// snapshotSuffixes возвращает расширения файлов коллекции, которые попадают в снимок:
+// файлы всех зарегистрированных форматов, последовательность, журнал и манифест
+func snapshotSuffixes() []string {
+	return append(codecExtensions(), ".seq", ".journal", manifestSuffix)
+}
+
+// Формат идентификатора снимка: время создания в UTC
+const snapshotIDLayout = "20060102-150405.000000000"
+
+// Имя файла с описанием снимка
+const snapshotMetaFile = "snapshot.json"
+
+// errNoSnapshotFiles возвращается, если хранилище не связано с файлами данных
+var errNoSnapshotFiles = errors.New("снимки доступны только для файлового хранилища")
+
+// RetentionPolicy - сколько снимков хранить. Снимок остаётся, если подходит хотя бы под одно правило
+type RetentionPolicy struct {
+	KeepLast   int // последние N снимков
+	KeepDaily  int // самый свежий снимок за каждый из последних N дней, когда снимки делались
+	KeepWeekly int // самый свежий снимок за каждую из последних N недель, когда снимки делались
+}
+
+// DefaultRetention - политика хранения снимков по умолчанию
+var DefaultRetention = RetentionPolicy{KeepLast: 10, KeepDaily: 7, KeepWeekly: 4}
+
+// SnapshotOptions настраивает снимки файлового хранилища
+type SnapshotOptions struct {
+	// Dir - каталог снимков; по умолчанию snapshots рядом с файлом задач
+	Dir string
+	// Interval - период снимков по расписанию для StartSnapshots
+	Interval time.Duration
+	// Retention - политика хранения; нулевое значение заменяется DefaultRetention
+	Retention RetentionPolicy
+}
+
+// SnapshotInfo описывает один снимок
+type SnapshotInfo struct {
+	ID     string
+	Time   time.Time
+	Reason string
+	Dir    string
+}
+
+// snapshotMeta - содержимое snapshot.json
+type snapshotMeta struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// snapshotScheduler - состояние фоновой горутины снимков
+type snapshotScheduler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// defaultSnapshotDir возвращает каталог снимков по умолчанию
+func defaultSnapshotDir(tasksFile string) string {
+	return filepath.Join(filepath.Dir(tasksFile), "snapshots")
+}
+
+// ========== Снимки открытого хранилища ==========
+
+// Snapshot сохраняет текущее состояние и делает снимок файлов данных,
+// после чего удаляет устаревшие снимки по политике хранения.
+// Хранилище только для чтения не держит блокировку каталога данных, поэтому снимки в нём
+// не делаются и не удаляются: возвращается ErrReadOnly
+func (s *Storage) Snapshot(reason string) (SnapshotInfo, error) {
+	if s.readOnly {
+		return SnapshotInfo{}, ErrReadOnly
+	}
+	if s.tasksFile == "" {
+		return SnapshotInfo{}, errNoSnapshotFiles
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	return s.snapshotLocked(reason)
+}
+
+// snapshotLocked делает снимок; вызывается под s.saveMu и s.mu
+func (s *Storage) snapshotLocked(reason string) (SnapshotInfo, error) {
+	// Файлы должны отражать состояние в памяти (автосохранение и журнал могли отстать)
+	if err := s.saveLocked(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	info, err := takeSnapshot(s.tasksFile, s.notesFile, s.snapshots.Dir, reason)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	if _, err := PruneSnapshots(s.snapshots.Dir, s.snapshots.Retention); err != nil {
+		return info, fmt.Errorf("ошибка удаления старых снимков: %w", err)
+	}
+	return info, nil
+}
+
+// saveLocked синхронно сохраняет обе коллекции; вызывается под s.saveMu и s.mu
+func (s *Storage) saveLocked() error {
+	if err := s.syncExternalLocked(); err != nil {
+		return err
+	}
+	if err := s.backend.SaveTasks(s.tasks); err != nil {
+		return fmt.Errorf("ошибка сохранения задач: %w", err)
+	}
+	if err := s.backend.SaveNotes(s.notes); err != nil {
+		return fmt.Errorf("ошибка сохранения заметок: %w", err)
+	}
+	s.tasksDirty = false
+	s.notesDirty = false
+	if s.autosave != nil {
+		s.autosave.changes = 0
+	}
+	s.lastSaveTime = time.Now()
+	s.lastSaveErr = nil
+	return nil
+}
+
+// Snapshots возвращает снимки хранилища, начиная с самого нового
+func (s *Storage) Snapshots() ([]SnapshotInfo, error) {
+	if s.tasksFile == "" {
+		return nil, errNoSnapshotFiles
+	}
+	return ListSnapshots(s.snapshots.Dir)
+}
+
+// Restore возвращает данные к снимку id. Перед заменой файлов текущее состояние
+// сохраняется в снимок "pre-restore", так что восстановление тоже можно откатить.
+// Возвращает отчёт о загрузке восстановленных данных
+func (s *Storage) Restore(id string) (*LoadReport, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if s.tasksFile == "" {
+		return nil, errNoSnapshotFiles
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	// Снимок мог быть сделан до смены ключа: проверяем фразу до замены файлов,
+	// иначе нечитаемый снимок превратился бы в пустое хранилище
+	dir, err := snapshotPath(s.snapshots.Dir, id)
+	if err != nil {
+		return nil, err
+	}
+	snapFiles := dataFiles(filepath.Join(dir, filepath.Base(s.tasksFile)), filepath.Join(dir, filepath.Base(s.notesFile)))
+	if err := checkPassphrase(s.cipher, snapFiles...); err != nil {
+		return nil, fmt.Errorf("снимок %s: %w", id, err)
+	}
+	if err := checkEncrypted(s.cipher, snapFiles...); err != nil {
+		return nil, fmt.Errorf("снимок %s: %w", id, err)
+	}
+
+	if _, err := s.snapshotLocked("pre-restore"); err != nil {
+		return nil, fmt.Errorf("ошибка снимка перед восстановлением: %w", err)
+	}
+
+	if err := restoreFiles(s.tasksFile, s.notesFile, s.snapshots.Dir, id); err != nil {
+		return nil, err
+	}
+
+	// Перечитываем восстановленные файлы
+	oldTasks, oldNotes := s.tasks, s.notes
+	s.tasks = make([]*model.Task, 0)
+	s.notes = make([]*model.Note, 0)
+	report := &LoadReport{}
+	s.loadFromFiles(report)
+	s.publishReplaced(oldTasks, oldNotes, true)
+	return report, nil
+}
+
+// StartSnapshots запускает снимки по расписанию раз в Interval из WithSnapshots.
+// Ошибки снимков не выводятся, а запоминаются и доступны через LastSnapshotError.
+// Горутина останавливается при отмене ctx или в Close
+func (s *Storage) StartSnapshots(ctx context.Context) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.tasksFile == "" {
+		return errNoSnapshotFiles
+	}
+	if s.snapshots.Interval <= 0 {
+		return model.NewValidationError("snapshot interval is not configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	if s.snapshotter != nil {
+		return model.NewValidationError("snapshots are already scheduled")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sch := &snapshotScheduler{cancel: cancel, done: make(chan struct{})}
+	s.snapshotter = sch
+
+	go func() {
+		defer close(sch.done)
+
+		ticker := time.NewTicker(s.snapshots.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.mu.Lock()
+				s.snapshotter = nil
+				s.mu.Unlock()
+				return
+			case <-ticker.C:
+				_, err := s.Snapshot("scheduled")
+				s.mu.Lock()
+				s.lastSnapshotErr = err
+				s.mu.Unlock()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// LastSnapshotError возвращает ошибку последнего снимка по расписанию или nil, если он прошёл успешно
+func (s *Storage) LastSnapshotError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSnapshotErr
+}
+
+// stopSnapshots останавливает снимки по расписанию и дожидается завершения горутины
+func (s *Storage) stopSnapshots() {
+	s.mu.RLock()
+	sch := s.snapshotter
+	s.mu.RUnlock()
+
+	if sch == nil {
+		return
+	}
+
+	sch.cancel()
+	<-sch.done
+}
+
+// ========== Снимки файлов данных ==========
+
+// TakeSnapshot делает снимок файлов данных в каталоге snapshotDir.
+// Каталог данных блокируется на время снимка, поэтому для открытого хранилища используйте Storage.Snapshot
+func TakeSnapshot(tasksFile, notesFile, snapshotDir, reason string) (SnapshotInfo, error) {
+	locks, err := lockDirs(tasksFile, notesFile)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer unlockDirs(locks)
+
+	return takeSnapshot(tasksFile, notesFile, snapshotDir, reason)
+}
+
+// takeSnapshot копирует файлы данных во временный каталог и атомарно переименовывает его в снимок
+func takeSnapshot(tasksFile, notesFile, snapshotDir, reason string) (SnapshotInfo, error) {
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	now := time.Now()
+	info := SnapshotInfo{
+		ID:     now.UTC().Format(snapshotIDLayout),
+		Time:   now,
+		Reason: reason,
+	}
+	info.Dir = filepath.Join(snapshotDir, info.ID)
+
+	tmpDir, err := os.MkdirTemp(snapshotDir, ".tmp-"+info.ID+"-")
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	for _, base := range []string{tasksFile, notesFile} {
+		for _, suffix := range snapshotSuffixes() {
+			src := base + suffix
+			if err := copyFileAtomic(src, filepath.Join(tmpDir, filepath.Base(src))); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				os.RemoveAll(tmpDir)
+				return SnapshotInfo{}, fmt.Errorf("ошибка копирования %s: %w", src, err)
+			}
+		}
+	}
+
+	meta := snapshotMeta{Time: info.Time, Reason: reason}
+	if err := writeFileAtomic(filepath.Join(tmpDir, snapshotMetaFile), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(meta)
+	}); err != nil {
+		os.RemoveAll(tmpDir)
+		return SnapshotInfo{}, err
+	}
+
+	if err := os.Rename(tmpDir, info.Dir); err != nil {
+		os.RemoveAll(tmpDir)
+		return SnapshotInfo{}, err
+	}
+	if err := syncDir(snapshotDir); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	return info, nil
+}
+
+// ListSnapshots возвращает снимки из каталога snapshotDir, начиная с самого нового
+func ListSnapshots(snapshotDir string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []SnapshotInfo
+	for _, entry := range entries {
+		// Незавершённые снимки (.tmp-*) пропускаем
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info := SnapshotInfo{ID: entry.Name(), Dir: filepath.Join(snapshotDir, entry.Name())}
+		data, err := os.ReadFile(filepath.Join(info.Dir, snapshotMetaFile))
+		if err != nil {
+			continue
+		}
+		var meta snapshotMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			continue
+		}
+		info.Time = meta.Time
+		info.Reason = meta.Reason
+		snapshots = append(snapshots, info)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// PruneSnapshots удаляет снимки, которые не подходят ни под одно правило политики хранения,
+// и возвращает удалённые. Нулевая политика заменяется DefaultRetention
+func PruneSnapshots(snapshotDir string, policy RetentionPolicy) ([]SnapshotInfo, error) {
+	if policy == (RetentionPolicy{}) {
+		policy = DefaultRetention
+	}
+
+	snapshots, err := ListSnapshots(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	for i := 0; i < len(snapshots) && i < policy.KeepLast; i++ {
+		keep[snapshots[i].ID] = true
+	}
+	keepNewestPerPeriod(snapshots, policy.KeepDaily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(snapshots, policy.KeepWeekly, keep, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	var removed []SnapshotInfo
+	for _, snap := range snapshots {
+		if keep[snap.ID] {
+			continue
+		}
+		if err := os.RemoveAll(snap.Dir); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snap)
+	}
+	return removed, nil
+}
+
+// keepNewestPerPeriod отмечает самый свежий снимок в каждом из последних limit периодов.
+// snapshots должны быть отсортированы от новых к старым
+func keepNewestPerPeriod(snapshots []SnapshotInfo, limit int, keep map[string]bool, period func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, snap := range snapshots {
+		if len(seen) >= limit {
+			return
+		}
+		key := period(snap.Time.Local())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep[snap.ID] = true
+	}
+}
+
+// RestoreSnapshot возвращает файлы данных к снимку id, предварительно сняв снимок "pre-restore".
+// Каталог данных блокируется, поэтому для открытого хранилища используйте Storage.Restore
+func RestoreSnapshot(tasksFile, notesFile, snapshotDir, id string) error {
+	locks, err := lockDirs(tasksFile, notesFile)
+	if err != nil {
+		return err
+	}
+	defer unlockDirs(locks)
+
+	if _, err := takeSnapshot(tasksFile, notesFile, snapshotDir, "pre-restore"); err != nil {
+		return fmt.Errorf("ошибка снимка перед восстановлением: %w", err)
+	}
+	return restoreFiles(tasksFile, notesFile, snapshotDir, id)
+}
+
+// restoreFiles заменяет файлы данных файлами снимка одной атомарной группой.
+// Файлы, которых в снимке нет (например, журнал), удаляются. Последовательности ID
+// не откатываются, чтобы ID, выданные после снимка, не достались другим записям
+func restoreFiles(tasksFile, notesFile, snapshotDir, id string) error {
+	dir, err := snapshotPath(snapshotDir, id)
+	if err != nil {
+		return err
+	}
+
+	var files []atomicFile
+	var stale []string
+	for _, base := range []string{tasksFile, notesFile} {
+		for _, suffix := range snapshotSuffixes() {
+			dst := base + suffix
+			src := filepath.Join(dir, filepath.Base(dst))
+
+			data, err := os.ReadFile(src)
+			if os.IsNotExist(err) {
+				stale = append(stale, dst)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if suffix == ".seq" && readSequenceFile(dst) > readSequenceFile(src) {
+				continue
+			}
+
+			files = append(files, atomicFile{path: dst, write: func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}})
+		}
+	}
+
+	if err := writeFilesAtomic(files...); err != nil {
+		return err
+	}
+
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotPath проверяет ID снимка и возвращает его каталог
+func snapshotPath(snapshotDir, id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", model.NewValidationError(fmt.Sprintf("invalid snapshot id %q", id))
+	}
+
+	dir := filepath.Join(snapshotDir, id)
+	if _, err := os.Stat(filepath.Join(dir, snapshotMetaFile)); err != nil {
+		return "", fmt.Errorf("снимок %s не найден: %w", id, err)
+	}
+	return dir, nil
+}
+
+// readSequenceFile читает значение последовательности из файла; нечитаемый файл считается нулём
+func readSequenceFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// copyFileAtomic копирует файл src в dst через временный файл
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
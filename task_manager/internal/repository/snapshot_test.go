@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Restore возвращает данные к снимку, а состояние до восстановления остаётся в снимке pre-restore
+func TestSnapshotRestore(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "Первая")
+	snap, err := storage.Snapshot("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTask(t, storage, "Вторая")
+
+	if _, err := storage.Restore(snap.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := taskIDs(storage.GetTasks()); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("после восстановления задачи %v, ожидалась [1]", got)
+	}
+	// Последовательность ID не откатывается вместе с данными
+	if task := addTask(t, storage, "Третья"); task.GetID() != 3 {
+		t.Errorf("новая задача получила ID %d, ожидался 3", task.GetID())
+	}
+
+	snapshots, err := storage.Snapshots()
+	if err != nil || len(snapshots) != 2 || snapshots[0].Reason != "pre-restore" {
+		t.Fatalf("снимки после восстановления: %v, %v", snapshots, err)
+	}
+	if _, err := storage.Restore(snapshots[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := taskIDs(storage.GetTasks()); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("после отката восстановления задачи %v, ожидались [1 2]", got)
+	}
+
+	if _, err := storage.Restore("../" + snap.ID); err == nil {
+		t.Error("ID снимка с путём не отклонён")
+	}
+}
+
+// fakeSnapshot создаёт в dir пустой снимок, сделанный в момент at
+func fakeSnapshot(t *testing.T, dir string, at time.Time) string {
+	t.Helper()
+	id := at.UTC().Format(snapshotIDLayout)
+	if err := os.MkdirAll(filepath.Join(dir, id), 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := json.Marshal(snapshotMeta{Time: at, Reason: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id, snapshotMetaFile), meta, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// Снимок остаётся, если он среди последних, самый свежий за свой день или за свою неделю
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	day := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2026, month, d, hour, 0, 0, 0, time.Local)
+	}
+	latest := fakeSnapshot(t, dir, day(time.March, 11, 12))
+	fakeSnapshot(t, dir, day(time.March, 11, 10))
+	newestYesterday := fakeSnapshot(t, dir, day(time.March, 10, 12))
+	fakeSnapshot(t, dir, day(time.March, 10, 9))
+	newestLastWeek := fakeSnapshot(t, dir, day(time.March, 4, 12))
+	fakeSnapshot(t, dir, day(time.March, 3, 12))
+	fakeSnapshot(t, dir, day(time.February, 25, 12))
+
+	removed, err := PruneSnapshots(dir, RetentionPolicy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 4 {
+		t.Errorf("удалено %d снимков, ожидалось 4", len(removed))
+	}
+
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	for _, snap := range snapshots {
+		kept = append(kept, snap.ID)
+	}
+	want := []string{latest, newestYesterday, newestLastWeek}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("остались снимки %v, ожидались %v", kept, want)
+	}
+}
@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"task-manager/internal/model"
+	"time"
+)
+
+// Tx - транзакция хранилища: изменения копятся в рабочих копиях коллекций
+// и применяются все вместе при успешном завершении Storage.Transaction
+type Tx struct {
+	tasks []*model.Task
+	notes []*model.Note
+
+	// Позиции записей в tasks и notes по ID
+	taskPos map[int]int
+	notePos map[int]int
+
+	// Последовательности ID с учётом добавленных в транзакции моделей
+	taskSeq int
+	noteSeq int
+
+	tasksChanged bool
+	notesChanged bool
+
+	// Действия после успешной фиксации: ID и версия передаются моделям вызывающего
+	// только тогда, когда транзакция сохранена
+	onCommit []func()
+}
+
+// Transaction выполняет fn как одну транзакцию: создания, изменения и удаления, сделанные
+// через tx, применяются вместе и сохраняются одной записью, а если fn вернула ошибку
+// (или не удалось сохранить данные), хранилище остаётся в прежнем состоянии.
+// fn выполняется под блокировкой хранилища, поэтому внутри неё нельзя вызывать методы Storage -
+// только методы tx. Модели, полученные через tx, - копии: их можно менять и передавать в Update*
+func (s *Storage) Transaction(fn func(tx *Tx) error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	// Внешние правки подхватываются до начала, чтобы транзакция видела актуальные данные
+	if err := s.syncExternalLocked(); err != nil {
+		return err
+	}
+
+	tx := &Tx{
+		tasks:   make([]*model.Task, len(s.tasks)),
+		notes:   make([]*model.Note, len(s.notes)),
+		taskPos: maps.Clone(s.taskIndex.byID),
+		notePos: maps.Clone(s.noteIndex.byID),
+		taskSeq: s.taskSeq,
+		noteSeq: s.noteSeq,
+	}
+	copy(tx.tasks, s.tasks)
+	copy(tx.notes, s.notes)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return s.commitLocked(tx)
+}
+
+// commitLocked сохраняет последовательности и коллекции транзакции и применяет их.
+// При ошибке сохранения данные в памяти не меняются
+// Вызывается под блокировками s.saveMu и s.mu
+func (s *Storage) commitLocked(tx *Tx) error {
+	if !tx.tasksChanged && !tx.notesChanged {
+		return nil
+	}
+
+	// Последовательности сохраняются первыми, чтобы после сбоя ID не были выданы повторно
+	if tx.taskSeq != s.taskSeq {
+		if err := s.backend.SaveSequence(taskSequence, tx.taskSeq); err != nil {
+			return fmt.Errorf("ошибка сохранения последовательности %s: %w", taskSequence, err)
+		}
+		s.taskSeq = tx.taskSeq
+	}
+	if tx.noteSeq != s.noteSeq {
+		if err := s.backend.SaveSequence(noteSequence, tx.noteSeq); err != nil {
+			return fmt.Errorf("ошибка сохранения последовательности %s: %w", noteSequence, err)
+		}
+		s.noteSeq = tx.noteSeq
+	}
+
+	// В режиме автосохранения обе коллекции уйдут в следующей записи вместе.
+	// С журналом транзакция сохраняется сразу, как и отдельные изменения
+	if _, journaled := s.backend.(IncrementalBackend); s.autosave != nil && !journaled {
+		s.applyLocked(tx)
+		s.markDirty(tx.tasksChanged, tx.notesChanged)
+	} else {
+		if err := saveCollections(s.backend, tx.tasks, tx.notes); err != nil {
+			return fmt.Errorf("ошибка сохранения транзакции: %w", err)
+		}
+		s.applyLocked(tx)
+	}
+
+	for _, fn := range tx.onCommit {
+		fn()
+	}
+	return nil
+}
+
+// applyLocked подменяет коллекции коллекциями транзакции и рассылает изменения
+// Вызывается под блокировкой s.mu
+func (s *Storage) applyLocked(tx *Tx) {
+	oldTasks, oldNotes := s.tasks, s.notes
+	s.tasks, s.notes = tx.tasks, tx.notes
+	s.reindexLocked()
+	s.publishReplaced(oldTasks, oldNotes, false)
+}
+
+// AddModel добавляет задачу или заметку; модели без ID получают его от хранилища.
+// В транзакцию попадает копия модели, а выданные ID и версия записываются в саму модель
+// только после успешной фиксации: при откате модель вызывающего остаётся прежней
+func (tx *Tx) AddModel(m interface{}) error {
+	switch v := m.(type) {
+	case *model.Task:
+		stored := v.Clone()
+		id := stored.GetID()
+		switch {
+		case id < 0:
+			return model.NewValidationError(fmt.Sprintf("invalid task id %d", id))
+		case id == 0:
+			tx.taskSeq++
+			stored.SetID(tx.taskSeq)
+		case tx.hasTask(id):
+			return model.NewValidationError(fmt.Sprintf("task with id %d already exists", id))
+		default:
+			tx.taskSeq = max(tx.taskSeq, id)
+		}
+		stored.SetVersion(1)
+		tx.taskPos[stored.GetID()] = len(tx.tasks)
+		tx.tasks = append(tx.tasks, stored)
+		tx.tasksChanged = true
+		tx.onCommit = append(tx.onCommit, func() {
+			v.SetID(stored.GetID())
+			v.SetVersion(1)
+		})
+		return nil
+	case *model.Note:
+		stored := v.Clone()
+		id := stored.GetID()
+		switch {
+		case id < 0:
+			return model.NewValidationError(fmt.Sprintf("invalid note id %d", id))
+		case id == 0:
+			tx.noteSeq++
+			stored.SetID(tx.noteSeq)
+		case tx.hasNote(id):
+			return model.NewValidationError(fmt.Sprintf("note with id %d already exists", id))
+		default:
+			tx.noteSeq = max(tx.noteSeq, id)
+		}
+		stored.SetVersion(1)
+		tx.notePos[stored.GetID()] = len(tx.notes)
+		tx.notes = append(tx.notes, stored)
+		tx.notesChanged = true
+		tx.onCommit = append(tx.onCommit, func() {
+			v.SetID(stored.GetID())
+			v.SetVersion(1)
+		})
+		return nil
+	default:
+		return model.NewValidationError("unknown model type")
+	}
+}
+
+// GetTask возвращает копию задачи по ID с учётом изменений транзакции или *model.NotFoundError
+func (tx *Tx) GetTask(id int) (*model.Task, error) {
+	i := tx.activeTask(id)
+	if i < 0 {
+		return nil, model.NewNotFoundError("task", id)
+	}
+	return tx.tasks[i].Clone(), nil
+}
+
+// GetTasks возвращает копии всех задач с учётом изменений транзакции
+func (tx *Tx) GetTasks() []*model.Task {
+	return cloneTasks(tx.tasks, false)
+}
+
+// UpdateTask заменяет задачу с тем же ID; версии сравниваются так же, как в Storage.UpdateTask,
+// а новая версия записывается в task после успешной фиксации
+func (tx *Tx) UpdateTask(task *model.Task) error {
+	if task == nil {
+		return model.NewValidationError("task cannot be nil")
+	}
+
+	i := tx.activeTask(task.GetID())
+	if i < 0 {
+		return model.NewNotFoundError("task", task.GetID())
+	}
+
+	stored, err := nextTaskVersion(tx.tasks[i], task)
+	if err != nil {
+		return err
+	}
+	tx.tasks[i] = stored
+	tx.tasksChanged = true
+	tx.onCommit = append(tx.onCommit, func() { task.SetVersion(stored.GetVersion()) })
+	return nil
+}
+
+// DeleteTask перемещает задачу в корзину
+func (tx *Tx) DeleteTask(id int) error {
+	i := tx.activeTask(id)
+	if i < 0 {
+		return model.NewNotFoundError("task", id)
+	}
+
+	tx.tasks[i] = trashedTask(tx.tasks[i], time.Now())
+	tx.tasksChanged = true
+	return nil
+}
+
+// hasTask сообщает, есть ли в транзакции задача с указанным ID (в том числе в корзине)
+func (tx *Tx) hasTask(id int) bool {
+	_, ok := tx.taskPos[id]
+	return ok
+}
+
+// activeTask возвращает позицию задачи не из корзины или -1
+func (tx *Tx) activeTask(id int) int {
+	i, ok := tx.taskPos[id]
+	if !ok || tx.tasks[i].IsDeleted() {
+		return -1
+	}
+	return i
+}
+
+// deleteTasks окончательно убирает из транзакции задачи, для которых del возвращает true,
+// и возвращает их число
+func (tx *Tx) deleteTasks(del func(*model.Task) bool) int {
+	before := len(tx.tasks)
+	tx.tasks = slices.DeleteFunc(tx.tasks, del)
+	if len(tx.tasks) == before {
+		return 0
+	}
+
+	// Позиции оставшихся задач сдвинулись
+	clear(tx.taskPos)
+	for i, task := range tx.tasks {
+		tx.taskPos[task.GetID()] = i
+	}
+	tx.tasksChanged = true
+	return before - len(tx.tasks)
+}
+
+// GetNote возвращает копию заметки по ID с учётом изменений транзакции или *model.NotFoundError
+func (tx *Tx) GetNote(id int) (*model.Note, error) {
+	i := tx.activeNote(id)
+	if i < 0 {
+		return nil, model.NewNotFoundError("note", id)
+	}
+	return tx.notes[i].Clone(), nil
+}
+
+// GetNotes возвращает копии всех заметок с учётом изменений транзакции
+func (tx *Tx) GetNotes() []*model.Note {
+	return cloneNotes(tx.notes, false)
+}
+
+// UpdateNote заменяет заметку с тем же ID; версии сравниваются так же, как в Storage.UpdateNote,
+// а новая версия записывается в note после успешной фиксации
+func (tx *Tx) UpdateNote(note *model.Note) error {
+	if note == nil {
+		return model.NewValidationError("note cannot be nil")
+	}
+
+	i := tx.activeNote(note.GetID())
+	if i < 0 {
+		return model.NewNotFoundError("note", note.GetID())
+	}
+
+	stored, err := nextNoteVersion(tx.notes[i], note)
+	if err != nil {
+		return err
+	}
+	tx.notes[i] = stored
+	tx.notesChanged = true
+	tx.onCommit = append(tx.onCommit, func() { note.SetVersion(stored.GetVersion()) })
+	return nil
+}
+
+// DeleteNote перемещает заметку в корзину
+func (tx *Tx) DeleteNote(id int) error {
+	i := tx.activeNote(id)
+	if i < 0 {
+		return model.NewNotFoundError("note", id)
+	}
+
+	tx.notes[i] = trashedNote(tx.notes[i], time.Now())
+	tx.notesChanged = true
+	return nil
+}
+
+// hasNote сообщает, есть ли в транзакции заметка с указанным ID (в том числе в корзине)
+func (tx *Tx) hasNote(id int) bool {
+	_, ok := tx.notePos[id]
+	return ok
+}
+
+// activeNote возвращает позицию заметки не из корзины или -1
+func (tx *Tx) activeNote(id int) int {
+	i, ok := tx.notePos[id]
+	if !ok || tx.notes[i].IsDeleted() {
+		return -1
+	}
+	return i
+}
+
+// deleteNotes окончательно убирает из транзакции заметки, для которых del возвращает true (см. deleteTasks)
+func (tx *Tx) deleteNotes(del func(*model.Note) bool) int {
+	before := len(tx.notes)
+	tx.notes = slices.DeleteFunc(tx.notes, del)
+	if len(tx.notes) == before {
+		return 0
+	}
+
+	clear(tx.notePos)
+	for i, note := range tx.notes {
+		tx.notePos[note.GetID()] = i
+	}
+	tx.notesChanged = true
+	return before - len(tx.notes)
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// failingBackend - бэкенд в памяти, запись коллекций в который можно сломать
+type failingBackend struct {
+	*MemoryBackend
+	fail bool
+}
+
+var errBackendFailed = errors.New("бэкенд недоступен")
+
+func (b *failingBackend) SaveTasks(tasks []*model.Task) error {
+	if b.fail {
+		return errBackendFailed
+	}
+	return b.MemoryBackend.SaveTasks(tasks)
+}
+
+func (b *failingBackend) SaveNotes(notes []*model.Note) error {
+	if b.fail {
+		return errBackendFailed
+	}
+	return b.MemoryBackend.SaveNotes(notes)
+}
+
+func (b *failingBackend) SaveCollections(tasks []*model.Task, notes []*model.Note) error {
+	if b.fail {
+		return errBackendFailed
+	}
+	return b.MemoryBackend.SaveCollections(tasks, notes)
+}
+
+// addTask добавляет в хранилище задачу с указанным заголовком
+func addTask(t *testing.T, s *Storage, title string) *model.Task {
+	t.Helper()
+	task, err := model.NewTask(title, "Описание задачи", model.PriorityMedium, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddModel(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestTransactionRollback(t *testing.T) {
+	errAbort := errors.New("отмена")
+
+	tests := []struct {
+		name string
+		// fn меняет задачу с ID 1 и добавляет added, а затем, возможно, прерывает транзакцию
+		fn       func(tx *Tx, added *model.Task) error
+		fail     bool
+		wantErr  func(error) bool
+		wantKept bool
+	}{
+		{
+			name: "успешная фиксация",
+			fn: func(tx *Tx, added *model.Task) error {
+				return changeAndAdd(tx, added)
+			},
+			wantErr:  func(err error) bool { return err == nil },
+			wantKept: true,
+		},
+		{
+			name: "ошибка fn",
+			fn: func(tx *Tx, added *model.Task) error {
+				if err := changeAndAdd(tx, added); err != nil {
+					return err
+				}
+				return errAbort
+			},
+			wantErr: func(err error) bool { return errors.Is(err, errAbort) },
+		},
+		{
+			name: "дубликат ID",
+			fn: func(tx *Tx, added *model.Task) error {
+				if err := changeAndAdd(tx, added); err != nil {
+					return err
+				}
+				duplicate := newBenchmarkTask(t, 0)
+				duplicate.SetID(1)
+				return tx.AddModel(duplicate)
+			},
+			wantErr: model.IsValidationError,
+		},
+		{
+			name: "конфликт версий",
+			fn: func(tx *Tx, added *model.Task) error {
+				if err := changeAndAdd(tx, added); err != nil {
+					return err
+				}
+				stale, err := tx.GetTask(1)
+				if err != nil {
+					return err
+				}
+				stale.SetVersion(1)
+				return tx.UpdateTask(stale)
+			},
+			wantErr: model.IsConflictError,
+		},
+		{
+			name: "ошибка сохранения",
+			fn: func(tx *Tx, added *model.Task) error {
+				return changeAndAdd(tx, added)
+			},
+			fail:    true,
+			wantErr: func(err error) bool { return errors.Is(err, errBackendFailed) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &failingBackend{MemoryBackend: NewMemoryBackend()}
+			storage, _ := NewStorageWithBackend(backend)
+			addTask(t, storage, "Первая")
+			addTask(t, storage, "Вторая")
+			backend.fail = tt.fail
+
+			added := newBenchmarkTask(t, 3)
+			err := storage.Transaction(func(tx *Tx) error { return tt.fn(tx, added) })
+			if !tt.wantErr(err) {
+				t.Fatalf("неожиданная ошибка транзакции: %v", err)
+			}
+
+			first, err := storage.GetTask(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, secondErr := storage.GetTask(2)
+			tasks, _ := storage.Count()
+
+			if tt.wantKept {
+				if first.GetTitle() != "Изменённая" || first.GetVersion() != 2 || !model.IsNotFoundError(secondErr) || tasks != 2 {
+					t.Errorf("изменения не применены: %q v%d, задача 2: %v, задач %d", first.GetTitle(), first.GetVersion(), secondErr, tasks)
+				}
+				if added.GetID() != 3 || added.GetVersion() != 1 {
+					t.Errorf("добавленная задача получила ID %d и версию %d", added.GetID(), added.GetVersion())
+				}
+				return
+			}
+
+			if first.GetTitle() != "Первая" || first.GetVersion() != 1 || secondErr != nil || tasks != 2 {
+				t.Errorf("откат не восстановил хранилище: %q v%d, задача 2: %v, задач %d", first.GetTitle(), first.GetVersion(), secondErr, tasks)
+			}
+			if added.GetID() != 0 || added.GetVersion() != 0 {
+				t.Errorf("после отката добавленная задача получила ID %d и версию %d", added.GetID(), added.GetVersion())
+			}
+		})
+	}
+}
+
+// changeAndAdd меняет задачу 1, удаляет задачу 2 и добавляет added
+func changeAndAdd(tx *Tx, added *model.Task) error {
+	task, err := tx.GetTask(1)
+	if err != nil {
+		return err
+	}
+	if err := task.SetTitle("Изменённая"); err != nil {
+		return err
+	}
+	if err := tx.UpdateTask(task); err != nil {
+		return err
+	}
+	if err := tx.DeleteTask(2); err != nil {
+		return err
+	}
+	return tx.AddModel(added)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"task-manager/internal/model"
+	"time"
+)
+
+// trashedTask возвращает копию задачи, перемещённую в корзину в момент now, со следующей версией
+func trashedTask(task *model.Task, now time.Time) *model.Task {
+	trashed := task.Clone()
+	trashed.SetDeletedAt(&now)
+	trashed.SetVersion(task.GetVersion() + 1)
+	return trashed
+}
+
+// trashedNote возвращает копию заметки, перемещённую в корзину в момент now, со следующей версией
+func trashedNote(note *model.Note, now time.Time) *model.Note {
+	trashed := note.Clone()
+	trashed.SetDeletedAt(&now)
+	trashed.SetVersion(note.GetVersion() + 1)
+	return trashed
+}
+
+// GetDeletedTasks возвращает копии задач в корзине
+func (s *Storage) GetDeletedTasks() []*model.Task {
+	return cloneTasks(s.loadView().tasks, true)
+}
+
+// GetDeletedNotes возвращает копии заметок в корзине
+func (s *Storage) GetDeletedNotes() []*model.Note {
+	return cloneNotes(s.loadView().notes, true)
+}
+
+// RestoreTask возвращает задачу из корзины; если задачи в корзине нет - *model.NotFoundError
+func (s *Storage) RestoreTask(id int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
+		return err
+	}
+
+	i := s.findTask(id)
+	if i < 0 || !s.tasks[i].IsDeleted() {
+		return model.NewNotFoundError("deleted task", id)
+	}
+
+	old := s.tasks[i]
+	restored := old.Clone()
+	restored.SetDeletedAt(nil)
+	restored.SetVersion(old.GetVersion() + 1)
+	undo := s.replaceTaskLocked(i, restored)
+	if err := s.persistTask(ChangeUpdated, restored); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения задач: %w", err)
+	}
+	s.storeViewLocked()
+	s.publishTask(ChangeRestored, old, restored)
+	return nil
+}
+
+// RestoreNote возвращает заметку из корзины; если заметки в корзине нет - *model.NotFoundError
+func (s *Storage) RestoreNote(id int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prepareWriteLocked(); err != nil {
+		return err
+	}
+
+	i := s.findNote(id)
+	if i < 0 || !s.notes[i].IsDeleted() {
+		return model.NewNotFoundError("deleted note", id)
+	}
+
+	old := s.notes[i]
+	restored := old.Clone()
+	restored.SetDeletedAt(nil)
+	restored.SetVersion(old.GetVersion() + 1)
+	undo := s.replaceNoteLocked(i, restored)
+	if err := s.persistNote(ChangeUpdated, restored); err != nil {
+		undo()
+		return fmt.Errorf("ошибка сохранения заметок: %w", err)
+	}
+	s.storeViewLocked()
+	s.publishNote(ChangeRestored, old, restored)
+	return nil
+}
+
+// PurgeDeleted окончательно удаляет задачи и заметки, пролежавшие в корзине не меньше maxAge
+// (0 - очистить корзину целиком), и возвращает число удалённых задач и заметок.
+// Удаление выполняется одной транзакцией и сохраняется одной записью
+func (s *Storage) PurgeDeleted(maxAge time.Duration) (int, int, error) {
+	if maxAge < 0 {
+		return 0, 0, model.NewValidationError(fmt.Sprintf("invalid trash age %v", maxAge))
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var tasks, notes int
+	err := s.Transaction(func(tx *Tx) error {
+		tasks, notes = tx.purgeDeleted(cutoff)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return tasks, notes, nil
+}
+
+// purgeDeleted убирает из транзакции записи, перемещённые в корзину не позже cutoff
+func (tx *Tx) purgeDeleted(cutoff time.Time) (int, int) {
+	expired := func(deletedAt *time.Time) bool {
+		return deletedAt != nil && !deletedAt.After(cutoff)
+	}
+
+	tasks := tx.deleteTasks(func(t *model.Task) bool { return expired(t.GetDeletedAt()) })
+	notes := tx.deleteNotes(func(n *model.Note) bool { return expired(n.GetDeletedAt()) })
+	return tasks, notes
+}
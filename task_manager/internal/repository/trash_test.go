@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// trashAges - сколько пролежали в корзине задачи и заметки с ID 2-4; запись с ID 1 не удалена
+var trashAges = map[int]time.Duration{
+	2: time.Hour,
+	3: 25 * time.Hour,
+	4: 49 * time.Hour,
+}
+
+// newTrashStorage создаёт хранилище, в корзине которого записи пролежали trashAges
+func newTrashStorage(t *testing.T) *Storage {
+	t.Helper()
+	now := time.Now()
+	var tasks []*model.Task
+	var notes []*model.Note
+	for id := 1; id <= 4; id++ {
+		task := newBenchmarkTask(t, id)
+		task.SetID(id)
+		task.SetVersion(1)
+		note := model.NewNote("Заметка", "Содержимое", model.CategoryIdea)
+		note.SetID(id)
+		note.SetVersion(1)
+		if age, ok := trashAges[id]; ok {
+			deletedAt := now.Add(-age)
+			task.SetDeletedAt(&deletedAt)
+			note.SetDeletedAt(&deletedAt)
+		}
+		tasks = append(tasks, task)
+		notes = append(notes, note)
+	}
+
+	backend := NewMemoryBackend()
+	if err := backend.SaveCollections(tasks, notes); err != nil {
+		t.Fatal(err)
+	}
+	storage, _ := NewStorageWithBackend(backend)
+	return storage
+}
+
+// trashIDs возвращает ID задач и заметок в корзине
+func trashIDs(s *Storage) (tasks, notes []int) {
+	tasks = taskIDs(s.GetDeletedTasks())
+	for _, note := range s.GetDeletedNotes() {
+		notes = append(notes, note.GetID())
+	}
+	return tasks, notes
+}
+
+// Нулевой возраст очищает всю корзину и не трогает записи вне неё
+func TestPurgeDeletedAll(t *testing.T) {
+	storage := newTrashStorage(t)
+
+	tasks, notes, err := storage.PurgeDeleted(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks != 3 || notes != 3 {
+		t.Errorf("удалено задач %d и заметок %d, ожидалось по 3", tasks, notes)
+	}
+	if trashedTasks, trashedNotes := storage.GetDeletedTasks(), storage.GetDeletedNotes(); len(trashedTasks) != 0 || len(trashedNotes) != 0 {
+		t.Errorf("в корзине осталось %d задач и %d заметок", len(trashedTasks), len(trashedNotes))
+	}
+	if active, activeNotes := storage.Count(); active != 1 || activeNotes != 1 {
+		t.Errorf("вне корзины %d задач и %d заметок, ожидалось по 1", active, activeNotes)
+	}
+}
+
+// Очищаются только записи, пролежавшие в корзине дольше maxAge; повторная очистка с меньшим
+// возрастом доудаляет следующие
+func TestPurgeDeletedAgeCutoff(t *testing.T) {
+	storage := newTrashStorage(t)
+
+	if tasks, notes, err := storage.PurgeDeleted(48 * time.Hour); err != nil || tasks != 1 || notes != 1 {
+		t.Fatalf("старше двух суток удалено %d задач и %d заметок (%v), ожидалось по 1", tasks, notes, err)
+	}
+	if tasks, notes := trashIDs(storage); !reflect.DeepEqual(tasks, []int{2, 3}) || !reflect.DeepEqual(notes, []int{2, 3}) {
+		t.Errorf("в корзине задачи %v и заметки %v, ожидались [2 3]", tasks, notes)
+	}
+
+	if tasks, _, err := storage.PurgeDeleted(24 * time.Hour); err != nil || tasks != 1 {
+		t.Fatalf("старше суток удалено %d задач (%v), ожидалась 1", tasks, err)
+	}
+	if tasks, _, err := storage.PurgeDeleted(72 * time.Hour); err != nil || tasks != 0 {
+		t.Fatalf("старше трёх суток удалено %d задач (%v), ожидалось 0", tasks, err)
+	}
+	if tasks, notes := trashIDs(storage); !reflect.DeepEqual(tasks, []int{2}) || !reflect.DeepEqual(notes, []int{2}) {
+		t.Errorf("в корзине задачи %v и заметки %v, ожидалась [2]", tasks, notes)
+	}
+}
+
+// Отрицательный возраст - ошибка валидации, корзина не меняется
+func TestPurgeDeletedNegativeAge(t *testing.T) {
+	storage := newTrashStorage(t)
+
+	if _, _, err := storage.PurgeDeleted(-time.Hour); !model.IsValidationError(err) {
+		t.Fatalf("ожидалась *model.ValidationError, получено %v", err)
+	}
+	if tasks, notes := trashIDs(storage); len(tasks) != 3 || len(notes) != 3 {
+		t.Errorf("в корзине задачи %v и заметки %v, ожидалось по 3", tasks, notes)
+	}
+}
+
+// Восстановление возвращает запись из корзины независимо от того, как давно она удалена
+func TestRestoreFromTrash(t *testing.T) {
+	storage := newTrashStorage(t)
+
+	for _, id := range []int{2, 4} {
+		if err := storage.RestoreTask(id); err != nil {
+			t.Fatalf("задача %d: %v", id, err)
+		}
+		if err := storage.RestoreNote(id); err != nil {
+			t.Fatalf("заметка %d: %v", id, err)
+		}
+		task, err := storage.GetTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.IsDeleted() || task.GetVersion() != 2 {
+			t.Errorf("задача %d: удалена %v, версия %d, ожидалась версия 2", id, task.IsDeleted(), task.GetVersion())
+		}
+		if note, err := storage.GetNote(id); err != nil || note.IsDeleted() {
+			t.Errorf("заметка %d не восстановлена: %v", id, err)
+		}
+	}
+	if tasks, notes := trashIDs(storage); !reflect.DeepEqual(tasks, []int{3}) || !reflect.DeepEqual(notes, []int{3}) {
+		t.Errorf("в корзине задачи %v и заметки %v, ожидалась [3]", tasks, notes)
+	}
+}
+
+// Восстановить можно только запись из корзины: неудалённая и несуществующая не найдены,
+// а неудалённая при этом не меняется
+func TestRestoreNotInTrash(t *testing.T) {
+	storage := newTrashStorage(t)
+
+	for _, id := range []int{1, 9} {
+		if err := storage.RestoreTask(id); !model.IsNotFoundError(err) {
+			t.Errorf("задача %d: ожидалась *model.NotFoundError, получено %v", id, err)
+		}
+		if err := storage.RestoreNote(id); !model.IsNotFoundError(err) {
+			t.Errorf("заметка %d: ожидалась *model.NotFoundError, получено %v", id, err)
+		}
+	}
+	if task, err := storage.GetTask(1); err != nil || task.GetVersion() != 1 {
+		t.Errorf("неудалённая задача изменилась: %v", err)
+	}
+}
+
+// Окончательно удалённую запись восстановить нельзя, оставшиеся в корзине восстанавливаются
+func TestRestoreAfterPurge(t *testing.T) {
+	storage := newTrashStorage(t)
+	if _, _, err := storage.PurgeDeleted(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.RestoreTask(3); !model.IsNotFoundError(err) {
+		t.Errorf("очищенная задача: ожидалась *model.NotFoundError, получено %v", err)
+	}
+	if err := storage.RestoreNote(3); !model.IsNotFoundError(err) {
+		t.Errorf("очищенная заметка: ожидалась *model.NotFoundError, получено %v", err)
+	}
+	if err := storage.RestoreTask(2); err != nil {
+		t.Errorf("оставшаяся задача: %v", err)
+	}
+	if active, _ := storage.Count(); active != 2 {
+		t.Errorf("вне корзины %d задач, ожидалось 2", active)
+	}
+}
+
+// Удалённая задача не видна, пока её не восстановят, и сохраняет содержимое
+func TestDeleteAndRestoreTask(t *testing.T) {
+	storage := NewMemoryStorage()
+	task := addTask(t, storage, "В корзину")
+
+	if err := storage.DeleteTask(task.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetTask(task.GetID()); !model.IsNotFoundError(err) {
+		t.Fatalf("удалённая задача доступна: %v", err)
+	}
+	if err := storage.DeleteTask(task.GetID()); !model.IsNotFoundError(err) {
+		t.Fatalf("повторное удаление: ожидалась *model.NotFoundError, получено %v", err)
+	}
+
+	// Корзина не очищается раньше срока
+	if tasks, _, err := storage.PurgeDeleted(time.Hour); err != nil || tasks != 0 {
+		t.Fatalf("очистка удалила %d задач: %v", tasks, err)
+	}
+
+	if err := storage.RestoreTask(task.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := storage.GetTask(task.GetID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetTitle() != "В корзину" || restored.GetVersion() != 3 {
+		t.Errorf("восстановлено %q v%d, ожидалось \"В корзину\" v3", restored.GetTitle(), restored.GetVersion())
+	}
+}
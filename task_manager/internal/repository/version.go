@@ -0,0 +1,53 @@
+package repository
+
+import "task-manager/internal/model"
+
+// nextTaskVersion сравнивает версию задачи task с сохранённой stored и возвращает копию task
+// со следующей версией, которую нужно сохранить. Сама task не меняется: вызывающий получает
+// новую версию только после успешного сохранения. Несовпадение версий - *model.ConflictError
+func nextTaskVersion(stored, task *model.Task) (*model.Task, error) {
+	if task.GetVersion() != stored.GetVersion() {
+		return nil, model.NewConflictError("task", task.GetID(), task.GetVersion(), stored.GetVersion())
+	}
+
+	clone := task.Clone()
+	clone.SetVersion(stored.GetVersion() + 1)
+	// Пометка удаления меняется только через DeleteTask и RestoreTask
+	clone.SetDeletedAt(stored.GetDeletedAt())
+	return clone, nil
+}
+
+// nextNoteVersion - то же, что nextTaskVersion, для заметок
+func nextNoteVersion(stored, note *model.Note) (*model.Note, error) {
+	if note.GetVersion() != stored.GetVersion() {
+		return nil, model.NewConflictError("note", note.GetID(), note.GetVersion(), stored.GetVersion())
+	}
+
+	clone := note.Clone()
+	clone.SetVersion(stored.GetVersion() + 1)
+	clone.SetDeletedAt(stored.GetDeletedAt())
+	return clone, nil
+}
+
+// cloneTasks возвращает копии задач вне корзины (deleted = false) или в корзине (deleted = true):
+// изменения копий не затрагивают хранилище в обход проверки версий
+func cloneTasks(tasks []*model.Task, deleted bool) []*model.Task {
+	result := make([]*model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.IsDeleted() == deleted {
+			result = append(result, task.Clone())
+		}
+	}
+	return result
+}
+
+// cloneNotes возвращает копии заметок вне корзины или в корзине аналогично cloneTasks
+func cloneNotes(notes []*model.Note, deleted bool) []*model.Note {
+	result := make([]*model.Note, 0, len(notes))
+	for _, note := range notes {
+		if note.IsDeleted() == deleted {
+			result = append(result, note.Clone())
+		}
+	}
+	return result
+}
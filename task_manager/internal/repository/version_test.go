@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// versioned - задача или заметка, над которой проверяется сравнение версий
+type versioned struct {
+	name string
+	// add добавляет запись и возвращает её ID
+	add func(t *testing.T, s *Storage) int
+	// update сохраняет запись id с версией version и заголовком title
+	// и возвращает версию переданной в хранилище модели после вызова
+	update func(s *Storage, id, version int, title string) (int, error)
+	title  func(s *Storage, id int) string
+}
+
+var versionedEntities = []versioned{
+	{
+		name: "задача",
+		add:  func(t *testing.T, s *Storage) int { return addTask(t, s, "Исходная").GetID() },
+		update: func(s *Storage, id, version int, title string) (int, error) {
+			task, err := s.GetTask(id)
+			if err != nil {
+				return 0, err
+			}
+			task.SetVersion(version)
+			if err := task.SetTitle(title); err != nil {
+				return 0, err
+			}
+			err = s.UpdateTask(task)
+			return task.GetVersion(), err
+		},
+		title: func(s *Storage, id int) string {
+			task, _ := s.GetTask(id)
+			return task.GetTitle()
+		},
+	},
+	{
+		name: "заметка",
+		add: func(t *testing.T, s *Storage) int {
+			note := model.NewNote("Исходная", "Содержимое", model.CategoryWork)
+			if err := s.AddModel(note); err != nil {
+				t.Fatal(err)
+			}
+			return note.GetID()
+		},
+		update: func(s *Storage, id, version int, title string) (int, error) {
+			// У заметки нет сеттеров содержимого, поэтому изменение - новая заметка с тем же ID
+			note := model.NewNote(title, "Содержимое", model.CategoryWork)
+			note.SetID(id)
+			note.SetVersion(version)
+			err := s.UpdateNote(note)
+			return note.GetVersion(), err
+		},
+		title: func(s *Storage, id int) string {
+			note, _ := s.GetNote(id)
+			return note.GetTitle()
+		},
+	},
+}
+
+func TestUpdateVersionConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		// updates - число успешных изменений записи до проверяемого
+		updates     int
+		version     int
+		wantVersion int
+		wantTitle   string
+		// wantActual - версия в ConflictError; 0 - конфликта быть не должно
+		wantActual int
+	}{
+		{"актуальная версия", 0, 1, 2, "Новая", 0},
+		{"актуальная версия после изменений", 2, 3, 4, "Новая", 0},
+		{"устаревшая версия", 1, 1, 1, "Изменение 1", 2},
+		{"версия из будущего", 0, 5, 5, "Исходная", 1},
+		{"нулевая версия", 1, 0, 0, "Изменение 1", 2},
+	}
+
+	for _, entity := range versionedEntities {
+		for _, tt := range tests {
+			t.Run(entity.name+"/"+tt.name, func(t *testing.T) {
+				storage := NewMemoryStorage()
+				id := entity.add(t, storage)
+				for i := 1; i <= tt.updates; i++ {
+					if _, err := entity.update(storage, id, i, fmt.Sprintf("Изменение %d", i)); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				version, err := entity.update(storage, id, tt.version, "Новая")
+				var conflict *model.ConflictError
+				switch {
+				case tt.wantActual == 0 && err != nil:
+					t.Fatalf("неожиданная ошибка: %v", err)
+				case tt.wantActual != 0 && !errors.As(err, &conflict):
+					t.Fatalf("ожидался *model.ConflictError, получено %v", err)
+				case conflict != nil && (conflict.GetExpectedVersion() != tt.version || conflict.GetActualVersion() != tt.wantActual):
+					t.Errorf("конфликт с версиями %d/%d, ожидались %d/%d",
+						conflict.GetExpectedVersion(), conflict.GetActualVersion(), tt.version, tt.wantActual)
+				}
+
+				if version != tt.wantVersion {
+					t.Errorf("версия модели вызывающего %d, ожидалась %d", version, tt.wantVersion)
+				}
+				if title := entity.title(storage, id); title != tt.wantTitle {
+					t.Errorf("сохранён заголовок %q, ожидался %q", title, tt.wantTitle)
+				}
+			})
+		}
+	}
+}
+
+// Из двух изменений одной прочитанной версии проходит только первое
+func TestUpdateConcurrentReaders(t *testing.T) {
+	storage := NewMemoryStorage()
+	addTask(t, storage, "Исходная")
+
+	first, _ := storage.GetTask(1)
+	second, _ := storage.GetTask(1)
+	if err := first.SetTitle("Первый"); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.SetTitle("Второй"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.UpdateTask(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateTask(second); !model.IsConflictError(err) {
+		t.Fatalf("второе изменение: ожидался конфликт, получено %v", err)
+	}
+
+	// После перечитывания изменение проходит
+	second, _ = storage.GetTask(1)
+	if err := second.SetTitle("Второй"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateTask(second); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := storage.GetTask(1); stored.GetTitle() != "Второй" || stored.GetVersion() != 3 {
+		t.Errorf("сохранено %q v%d, ожидалось \"Второй\" v3", stored.GetTitle(), stored.GetVersion())
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import "task-manager/internal/model"
+
+// storageView - неизменяемый снимок коллекций хранилища для чтения без блокировок.
+// Писатели по-прежнему работают по очереди под s.mu и после каждого изменения публикуют
+// новый снимок через атомарный указатель s.view; читатели берут текущий снимок и не ждут
+// писателей, даже если те держат блокировку на время записи файлов.
+//
+// Слайсы снимка разделяют массив с s.tasks и s.notes, поэтому записи до их длины никогда
+// не меняются на месте: добавление дописывает в конец, а замена и удаление сначала копируют
+// слайс (см. index.go). Индексы снимка тоже не меняются - писатели изменяют их копии.
+// Сами записи хранилища и так неизменяемы - изменения создают новые копии
+type storageView struct {
+	tasks []*model.Task
+	notes []*model.Note
+
+	// Индексы, соответствующие слайсам снимка
+	taskIndex *taskIndex
+	noteIndex *noteIndex
+
+	// Число записей вне корзины
+	activeTasks int
+	activeNotes int
+
+	// Порядки для постраничных списков по полям, кроме ID
+	taskOrders sortOrders
+	noteOrders sortOrders
+}
+
+// loadView возвращает текущий снимок коллекций
+func (s *Storage) loadView() *storageView {
+	return s.view.Load()
+}
+
+// storeViewLocked публикует снимок текущего состояния коллекций
+// Вызывается под блокировкой s.mu после каждого изменения s.tasks, s.notes и индексов
+func (s *Storage) storeViewLocked() {
+	s.view.Store(&storageView{
+		// Ёмкость ограничена длиной, чтобы append к слайсу снимка не мог задеть общий массив
+		tasks:       s.tasks[:len(s.tasks):len(s.tasks)],
+		notes:       s.notes[:len(s.notes):len(s.notes)],
+		taskIndex:   s.taskIndex,
+		noteIndex:   s.noteIndex,
+		activeTasks: len(s.tasks) - s.taskIndex.deleted,
+		activeNotes: len(s.notes) - s.noteIndex.deleted,
+	})
+}
+
+// activeTask возвращает задачу снимка с указанным ID, если она не в корзине
+func (v *storageView) activeTask(id int) (*model.Task, bool) {
+	if i, ok := v.taskIndex.byID[id]; ok && !v.tasks[i].IsDeleted() {
+		return v.tasks[i], true
+	}
+	return nil, false
+}
+
+// activeNote возвращает заметку снимка с указанным ID, если она не в корзине
+func (v *storageView) activeNote(id int) (*model.Note, bool) {
+	if i, ok := v.noteIndex.byID[id]; ok && !v.notes[i].IsDeleted() {
+		return v.notes[i], true
+	}
+	return nil, false
+}
+
+// tasksAt возвращает копии задач снимка на позициях positions
+func (v *storageView) tasksAt(positions []int) []*model.Task {
+	result := make([]*model.Task, len(positions))
+	for i, pos := range positions {
+		result[i] = v.tasks[pos].Clone()
+	}
+	return result
+}
+
+// taskOrder возвращает ID задач снимка вне корзины по возрастанию поля field
+func (v *storageView) taskOrder(field SortField) []int {
+	if field == SortByID {
+		return v.taskIndex.active
+	}
+	return v.taskOrders.get(field, func() []int { return sortedIDs(v.tasks, field, taskAccessors) })
+}
+
+// noteOrder возвращает ID заметок снимка вне корзины по возрастанию поля field
+func (v *storageView) noteOrder(field SortField) []int {
+	if field == SortByID {
+		return v.noteIndex.active
+	}
+	return v.noteOrders.get(field, func() []int { return sortedIDs(v.notes, field, noteAccessors) })
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// reader - методы хранилища, которые сравниваются под нагрузкой
+type reader interface {
+	AddModel(m interface{}) error
+	GetTasks() []*model.Task
+	Count() (int, int)
+}
+
+// lockedStorage воспроизводит прежнюю схему чтения: GetTasks и Count берут RWMutex,
+// а AddModel держит его на время полной записи файлов
+type lockedStorage struct {
+	mu      sync.RWMutex
+	storage *Storage
+}
+
+func (l *lockedStorage) AddModel(m interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.storage.AddModel(m)
+}
+
+func (l *lockedStorage) GetTasks() []*model.Task {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.storage.GetTasks()
+}
+
+func (l *lockedStorage) Count() (int, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.storage.Count()
+}
+
+// readDesigns - сравниваемые схемы чтения: через RWMutex и через снимок хранилища
+var readDesigns = []struct {
+	name string
+	wrap func(s *Storage) reader
+}{
+	{"mutex", func(s *Storage) reader { return &lockedStorage{storage: s} }},
+	{"snapshot", func(s *Storage) reader { return s }},
+}
+
+// Число задач в хранилище перед замером
+const benchmarkPreload = 1000
+
+func BenchmarkGetTasks(b *testing.B) {
+	for _, design := range readDesigns {
+		b.Run(design.name, func(b *testing.B) {
+			benchmarkReads(b, design.wrap, func(r reader) { r.GetTasks() })
+		})
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	for _, design := range readDesigns {
+		b.Run(design.name, func(b *testing.B) {
+			benchmarkReads(b, design.wrap, func(r reader) { r.Count() })
+		})
+	}
+}
+
+// benchmarkReads замеряет чтения read, пока приёмник, как в cmd/concurrent, непрерывно добавляет
+// задачи и заметки, и каждое добавление перезаписывает файлы
+func benchmarkReads(b *testing.B, wrap func(*Storage) reader, read func(reader)) {
+	dir := b.TempDir()
+	storage, _, err := Open(filepath.Join(dir, "tasks"), filepath.Join(dir, "notes"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer storage.Close()
+
+	if err := storage.Transaction(func(tx *Tx) error {
+		for i := 0; i < benchmarkPreload; i++ {
+			if err := tx.AddModel(newBenchmarkTask(b, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		b.Fatal(err)
+	}
+
+	r := wrap(storage)
+
+	// Приёмник добавляет модели по одной, чередуя задачи и заметки.
+	// Замер начинается после первого добавления, когда запись файлов уже идёт
+	var writes atomic.Int64
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			if i == 1 {
+				close(started)
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			var m interface{} = model.NewNote(fmt.Sprintf("Заметка %d", i), "Содержимое", "bench")
+			if i%2 == 0 {
+				m = newBenchmarkTask(b, i)
+			}
+			if err := r.AddModel(m); err == nil {
+				writes.Add(1)
+			}
+		}
+	}()
+	<-started
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			read(r)
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-done
+	b.ReportMetric(float64(writes.Load()), "writes")
+}
+
+// newBenchmarkTask создаёт задачу для заполнения хранилища
+func newBenchmarkTask(tb testing.TB, i int) *model.Task {
+	task, err := model.NewTask(fmt.Sprintf("Задача %d", i), "Описание задачи", model.PriorityMedium, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return task
+}
+
+// blockingBackend - бэкенд в памяти, запись задач в который ждёт, пока тест не разрешит её
+type blockingBackend struct {
+	*MemoryBackend
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) SaveTasks(tasks []*model.Task) error {
+	b.saving <- struct{}{}
+	<-b.release
+	return b.MemoryBackend.SaveTasks(tasks)
+}
+
+// Поиск по ID и по индексам читает опубликованный снимок и не ждёт писателя,
+// который держит блокировку на время записи; несохранённое изменение не видно
+func TestIndexedReadsDoNotWaitForWriters(t *testing.T) {
+	backend := &blockingBackend{MemoryBackend: NewMemoryBackend(), saving: make(chan struct{}), release: make(chan struct{})}
+	storage, _ := NewStorageWithBackend(backend)
+	go func() {
+		<-backend.saving
+		backend.release <- struct{}{}
+	}()
+	saved := addTask(t, storage, "Сохранённая")
+
+	written := make(chan error)
+	go func() {
+		pending, _ := model.NewTask("Записывается", "Описание задачи", model.PriorityHigh, nil)
+		written <- storage.AddModel(pending)
+	}()
+	<-backend.saving
+
+	reads := make(chan struct{})
+	go func() {
+		defer close(reads)
+		if task, err := storage.GetTask(saved.GetID()); err != nil || task.GetTitle() != "Сохранённая" {
+			t.Errorf("GetTask: %v", err)
+		}
+		if _, err := storage.GetTask(saved.GetID() + 1); !model.IsNotFoundError(err) {
+			t.Errorf("несохранённая задача видна: %v", err)
+		}
+		if tasks := storage.GetTasksByPriority(model.PriorityHigh); len(tasks) != 0 {
+			t.Errorf("несохранённая задача видна в индексе приоритетов: %d", len(tasks))
+		}
+		if tasks := storage.GetTasksByStatus(saved.GetStatus()); len(tasks) != 1 {
+			t.Errorf("в индексе статусов %d задач, ожидалась 1", len(tasks))
+		}
+	}()
+
+	select {
+	case <-reads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("чтения ждут писателя")
+	}
+
+	close(backend.release)
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if tasks := storage.GetTasksByPriority(model.PriorityHigh); len(tasks) != 1 {
+		t.Errorf("после сохранения в индексе приоритетов %d задач, ожидалась 1", len(tasks))
+	}
+}
@@ -0,0 +1,492 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"task-manager/internal/model"
+	"time"
+)
+
+// Период опроса файлов по умолчанию
+const DefaultWatchInterval = time.Second
+
+// WatchOptions настраивает наблюдение за внешними изменениями файлов данных
+type WatchOptions struct {
+	// Interval - период опроса времени изменения и контрольных сумм; 0 - DefaultWatchInterval
+	Interval time.Duration
+}
+
+// ReloadEvent - изменения коллекции, подхваченные из отредактированного извне файла
+type ReloadEvent struct {
+	Collection string // tasks или notes
+	File       string // файл, из которого загружены изменения
+	Added      []int
+	Updated    []int
+	Removed    []int
+	Issues     []LoadIssue // проблемы при чтении изменённого файла
+	// Err - ошибка загрузки или сохранения внешних изменений наблюдением; остальные поля тогда пусты
+	Err error
+}
+
+// fileWatcher - состояние фоновой горутины наблюдения
+type fileWatcher struct {
+	options WatchOptions
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// fileState - отпечаток файла данных при последней синхронизации
+type fileState struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+// ========== Отпечатки файлов в FileBackend ==========
+
+// startWatching запоминает текущее состояние файлов; после этого каждое сохранение и загрузка
+// обновляют отпечатки, чтобы собственные записи не принимались за внешние правки
+func (b *FileBackend) startWatching() {
+	b.watchMu.Lock()
+	b.watching = true
+	b.watchMu.Unlock()
+
+	b.rememberFiles(b.watchedFiles()...)
+}
+
+// watchedFiles возвращает файлы задач и заметок во всех форматах бэкенда
+func (b *FileBackend) watchedFiles() []string {
+	return append(b.collectionFiles(b.tasksFile), b.collectionFiles(b.notesFile)...)
+}
+
+// rememberFiles обновляет отпечатки файлов, если включено наблюдение
+func (b *FileBackend) rememberFiles(paths ...string) {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+
+	if !b.watching {
+		return
+	}
+	if b.states == nil {
+		b.states = make(map[string]fileState)
+	}
+
+	for _, path := range paths {
+		state, err := readFileState(path)
+		if err != nil {
+			delete(b.states, path)
+			continue
+		}
+		b.states[path] = state
+	}
+}
+
+// rememberBase запоминает содержимое записей, которые сейчас лежат в файлах коллекции:
+// это общая база для трёхстороннего слияния с внешними правками
+func (b *FileBackend) rememberBase(collection string, base map[int]string) {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+
+	if b.bases == nil {
+		b.bases = make(map[string]map[int]string)
+	}
+	b.bases[collection] = base
+}
+
+// base возвращает последнюю известную базу коллекции
+func (b *FileBackend) base(collection string) map[int]string {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+	return b.bases[collection]
+}
+
+// changedFiles возвращает файлы, изменённые извне с прошлой синхронизации.
+// Файл с прежними временем изменения и размером не читается; отсутствующие файлы пропускаются
+func (b *FileBackend) changedFiles() []string {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+
+	var changed []string
+	for _, path := range b.watchedFiles() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		known := b.states[path]
+		if info.ModTime().Equal(known.modTime) && info.Size() == known.size {
+			continue
+		}
+
+		state, err := readFileState(path)
+		if err != nil {
+			continue
+		}
+		b.states[path] = state
+		if state.sum != known.sum {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// readFileState читает отпечаток файла
+func readFileState(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	sum, err := checksumFile(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), sum: sum}, nil
+}
+
+// recordKeys возвращает содержимое записей по ID
+func recordKeys[T any](items []T, acc recordAccessors[T]) map[int]string {
+	keys := make(map[int]string, len(items))
+	for _, item := range items {
+		keys[acc.id(item)] = acc.key(item)
+	}
+	return keys
+}
+
+// ========== Наблюдение в Storage ==========
+
+// StartWatching включает наблюдение за файлами данных во всех форматах: раз в Interval проверяются
+// время изменения и контрольные суммы, а изменённый извне файл перечитывается и сливается с памятью.
+// Внешние правки всегда побеждают: локальные изменения сохраняются только для записей,
+// которых внешняя правка не касалась. Перед каждым сохранением файлы проверяются ещё раз,
+// поэтому устаревшее состояние в памяти не перезаписывает внешние правки.
+// Горутина останавливается при отмене ctx или в Close
+func (s *Storage) StartWatching(ctx context.Context, options WatchOptions) error {
+	if s.files == nil {
+		return model.NewValidationError("watching requires a file storage")
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultWatchInterval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	if s.watcher != nil {
+		return model.NewValidationError("watching is already running")
+	}
+
+	s.files.startWatching()
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &fileWatcher{options: options, cancel: cancel, done: make(chan struct{})}
+	s.watcher = w
+
+	go s.runWatcher(ctx, w)
+	return nil
+}
+
+// StopWatching останавливает наблюдение и дожидается завершения горутины
+func (s *Storage) StopWatching() {
+	s.mu.RLock()
+	w := s.watcher
+	s.mu.RUnlock()
+
+	if w == nil {
+		return
+	}
+
+	w.cancel()
+	<-w.done
+}
+
+// SubscribeReloads возвращает канал событий о подхваченных внешних изменениях
+// и об ошибках их загрузки в фоновой горутине наблюдения (поле Err).
+// Канал буферизован; если подписчик не успевает читать, лишние события отбрасываются.
+// Подписка снимается, а канал закрывается при отмене ctx или в Close
+func (s *Storage) SubscribeReloads(ctx context.Context) <-chan ReloadEvent {
+	ch := make(chan ReloadEvent, 16)
+
+	s.subsMu.Lock()
+	if s.reloadSubs == nil {
+		s.reloadSubs = make(map[chan ReloadEvent]struct{})
+	}
+	s.reloadSubs[ch] = struct{}{}
+	s.subsMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+		s.subsMu.Lock()
+		// После Close канал уже закрыт в closeSubscribers
+		if _, ok := s.reloadSubs[ch]; ok {
+			delete(s.reloadSubs, ch)
+			close(ch)
+		}
+		s.subsMu.Unlock()
+	}()
+
+	return ch
+}
+
+// runWatcher - цикл фоновой горутины наблюдения
+func (s *Storage) runWatcher(ctx context.Context, w *fileWatcher) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.watcher = nil
+			s.mu.Unlock()
+			return
+		case <-ticker.C:
+			s.saveMu.Lock()
+			s.mu.Lock()
+			err := s.syncExternalLocked()
+			s.mu.Unlock()
+			s.saveMu.Unlock()
+
+			if err != nil {
+				s.notifyReload(ReloadEvent{Err: err})
+			}
+		}
+	}
+}
+
+// syncExternalLocked подхватывает внешние изменения файлов, если наблюдение включено,
+// и сразу сохраняет слитую коллекцию, чтобы оба формата и манифест снова совпадали.
+// Вызывается под блокировками s.saveMu и s.mu
+func (s *Storage) syncExternalLocked() error {
+	if s.watcher == nil {
+		return nil
+	}
+
+	// changedFiles перечисляет файлы от основного формата к зеркалам, поэтому
+	// если извне изменены несколько форматов, берётся основной
+	changed := make(map[string]string)
+	for _, path := range s.files.changedFiles() {
+		collection := kindTasks
+		if slices.Contains(s.files.collectionFiles(s.files.notesFile), path) {
+			collection = kindNotes
+		}
+		if _, ok := changed[collection]; !ok {
+			changed[collection] = path
+		}
+	}
+
+	if path, ok := changed[kindTasks]; ok {
+		if err := s.reloadTasksLocked(path); err != nil {
+			return err
+		}
+	}
+	if path, ok := changed[kindNotes]; ok {
+		if err := s.reloadNotesLocked(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadTasksLocked сливает задачи из изменённого файла path с памятью
+func (s *Storage) reloadTasksLocked(path string) error {
+	report := &LoadReport{}
+	codec, _ := s.files.codecFor(s.files.tasksFile, path)
+	external, err := s.files.loadTasksFile(codec, report)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	merged, added, event := mergeExternal(s.files.base(kindTasks), s.tasks, external, taskAccessors)
+	old := s.tasks
+	s.tasks = merged
+	err = fixExternalIDs(s, taskSequence, &s.taskSeq, s.tasks, taskAccessors)
+	s.reindexLocked()
+	s.publishReplaced(old, s.notes, true)
+	if err != nil {
+		return err
+	}
+	for _, task := range added {
+		event.Added = append(event.Added, task.GetID())
+	}
+
+	// Правка подхвачена в память, поэтому файл, отложенный при загрузке до сверки, снова перезаписывается
+	s.files.release(path)
+	if s.readOnly {
+		s.files.rememberBase(kindTasks, recordKeys(external, taskAccessors))
+	} else if err := s.backend.SaveTasks(s.tasks); err != nil {
+		return fmt.Errorf("ошибка сохранения задач: %w", err)
+	}
+
+	event.Collection, event.File, event.Issues = kindTasks, path, report.Issues()
+	s.notifyReload(event)
+	return nil
+}
+
+// reloadNotesLocked сливает заметки из изменённого файла path с памятью
+func (s *Storage) reloadNotesLocked(path string) error {
+	report := &LoadReport{}
+	codec, _ := s.files.codecFor(s.files.notesFile, path)
+	external, err := s.files.loadNotesFile(codec, report)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	merged, added, event := mergeExternal(s.files.base(kindNotes), s.notes, external, noteAccessors)
+	old := s.notes
+	s.notes = merged
+	err = fixExternalIDs(s, noteSequence, &s.noteSeq, s.notes, noteAccessors)
+	s.reindexLocked()
+	s.publishReplaced(s.tasks, old, true)
+	if err != nil {
+		return err
+	}
+	for _, note := range added {
+		event.Added = append(event.Added, note.GetID())
+	}
+
+	// Правка подхвачена в память, поэтому файл, отложенный при загрузке до сверки, снова перезаписывается
+	s.files.release(path)
+	if s.readOnly {
+		s.files.rememberBase(kindNotes, recordKeys(external, noteAccessors))
+	} else if err := s.backend.SaveNotes(s.notes); err != nil {
+		return fmt.Errorf("ошибка сохранения заметок: %w", err)
+	}
+
+	event.Collection, event.File, event.Issues = kindNotes, path, report.Issues()
+	s.notifyReload(event)
+	return nil
+}
+
+// fixExternalIDs выдаёт новые ID записям без корректного или с повторяющимся ID
+// (например, строкам, добавленным в CSV вручную) и поднимает последовательность до внешних ID.
+// Записи обходятся с конца, чтобы при совпадении новый ID получила добавленная извне запись
+func fixExternalIDs[T any](s *Storage, name string, seq *int, items []T, acc recordAccessors[T]) error {
+	counts := make(map[int]int, len(items))
+	for _, item := range items {
+		counts[acc.id(item)]++
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		id := acc.id(items[i])
+		if id > 0 && counts[id] == 1 {
+			if id > *seq {
+				if err := s.advanceExternalSequence(name, seq, id, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		counts[id]--
+		if err := s.advanceExternalSequence(name, seq, *seq+1, acc.setID(items[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceExternalSequence продвигает последовательность; хранилище только для чтения
+// не пишет её в бэкенд
+func (s *Storage) advanceExternalSequence(name string, seq *int, value int, apply func(int)) error {
+	if !s.readOnly {
+		return s.advanceSequence(name, seq, value, apply)
+	}
+
+	*seq = value
+	if apply != nil {
+		apply(value)
+	}
+	return nil
+}
+
+// notifyReload рассылает событие подписчикам, не блокируясь на медленных
+func (s *Storage) notifyReload(event ReloadEvent) {
+	if event.Err == nil && len(event.Added)+len(event.Updated)+len(event.Removed) == 0 {
+		return
+	}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for ch := range s.reloadSubs {
+		select {
+		case ch <- event:
+		default:
+			// Подписчик не успевает читать - событие отбрасывается
+		}
+	}
+}
+
+// mergeExternal выполняет трёхстороннее слияние: base - записи в файле при последней синхронизации,
+// local - память, external - новое содержимое файла. Изменения, сделанные извне, побеждают;
+// локальные изменения остаются для записей, которых внешняя правка не касалась
+func mergeExternal[T any](base map[int]string, local, external []T, acc recordAccessors[T]) ([]T, []T, ReloadEvent) {
+	var event ReloadEvent
+	var added []T
+
+	externalByID := make(map[int]T, len(external))
+	for _, rec := range external {
+		externalByID[acc.id(rec)] = rec
+	}
+
+	// externallyChanged сообщает, изменила ли внешняя правка запись id
+	externallyChanged := func(id int) bool {
+		baseKey, inBase := base[id]
+		rec, inExternal := externalByID[id]
+		if inBase != inExternal {
+			return true
+		}
+		return inExternal && baseKey != acc.key(rec)
+	}
+
+	result := make([]T, 0, len(local))
+	seen := make(map[int]bool, len(local))
+	for _, rec := range local {
+		id := acc.id(rec)
+		seen[id] = true
+
+		if !externallyChanged(id) {
+			result = append(result, rec)
+			continue
+		}
+
+		ext, ok := externalByID[id]
+		if !ok {
+			event.Removed = append(event.Removed, id)
+			continue
+		}
+		if len(acc.diff(rec, ext)) > 0 {
+			event.Updated = append(event.Updated, id)
+			// Внешняя правка обычно не трогает версию, а копии, прочитанные до неё, должны получить конфликт
+			if acc.version(ext) <= acc.version(rec) {
+				acc.setVersion(ext)(acc.version(rec) + 1)
+			}
+		}
+		result = append(result, ext)
+	}
+
+	// Записи, которых нет в памяти: новые извне или изменённые извне после локального удаления
+	for _, rec := range external {
+		id := acc.id(rec)
+		if seen[id] && id > 0 {
+			continue
+		}
+		if id > 0 && !externallyChanged(id) {
+			continue // удалена локально, извне не менялась
+		}
+		result = append(result, rec)
+		added = append(added, rec)
+	}
+
+	return result, added, event
+}
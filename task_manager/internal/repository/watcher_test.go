@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"task-manager/internal/model"
+)
+
+// nextReload ждёт следующее событие наблюдения
+func nextReload(t *testing.T, events <-chan ReloadEvent) ReloadEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event.Err != nil {
+			t.Fatalf("ошибка наблюдения: %v", event.Err)
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("не дождались события наблюдения")
+		return ReloadEvent{}
+	}
+}
+
+// Внешняя правка одной записи сливается с несохранённым локальным изменением другой,
+// и результат слияния сразу записывается в файлы
+func TestWatcherMergesExternalEdit(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "Первая")
+	addTask(t, storage, "Вторая")
+	ctx := context.Background()
+	events := storage.SubscribeReloads(ctx)
+	// Локальные изменения остаются в памяти до сохранения
+	if err := storage.StartAutosave(ctx, AutosaveOptions{Interval: time.Hour, MaxChanges: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.StartWatching(ctx, WatchOptions{Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	local, _ := storage.GetTask(1)
+	if err := local.SetTitle("Первая локально"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateTask(local); err != nil {
+		t.Fatal(err)
+	}
+	editFile(t, tasksFile+".json", "Вторая", "Вторая извне")
+
+	event := nextReload(t, events)
+	if event.Collection != kindTasks || event.File != tasksFile+".json" || !reflect.DeepEqual(event.Updated, []int{2}) {
+		t.Errorf("событие %+v, ожидалось изменение задачи 2 из tasks.json", event)
+	}
+	for id, want := range map[int]string{1: "Первая локально", 2: "Вторая извне"} {
+		if task, _ := storage.GetTask(id); task.GetTitle() != want {
+			t.Errorf("задача %d: %q, ожидалось %q", id, task.GetTitle(), want)
+		}
+	}
+
+	// Слитое состояние записано во все форматы
+	if report := VerifyFiles(tasksFile, notesFile); report.HasIssues() {
+		t.Errorf("после слияния файлы расходятся с манифестом:\n%s", report)
+	}
+	reader, _ := openStorage(t, tasksFile, notesFile, WithReadOnly())
+	if task, _ := reader.GetTask(1); task.GetTitle() != "Первая локально" {
+		t.Errorf("на диске задача 1 %q, локальное изменение потеряно", task.GetTitle())
+	}
+}
+
+// Если внешняя правка и локальное изменение касаются одной записи, побеждает внешняя,
+// а копия, прочитанная до неё, получает конфликт версий
+func TestWatcherExternalEditWins(t *testing.T) {
+	tasksFile, notesFile := tempDataFiles(t)
+	storage, _ := openStorage(t, tasksFile, notesFile)
+	addTask(t, storage, "Общая")
+	events := storage.SubscribeReloads(context.Background())
+	// Интервал не успеет истечь: правку подхватывает проверка файлов перед записью
+	if err := storage.StartWatching(context.Background(), WatchOptions{Interval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, _ := storage.GetTask(1)
+	editFile(t, tasksFile+".json", "Общая", "Извне")
+	if err := stale.SetTitle("Локально"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateTask(stale); !model.IsConflictError(err) {
+		t.Fatalf("ожидался конфликт версий, получено %v", err)
+	}
+
+	if event := nextReload(t, events); !reflect.DeepEqual(event.Updated, []int{1}) {
+		t.Errorf("событие %+v, ожидалось изменение задачи 1", event)
+	}
+	task, _ := storage.GetTask(1)
+	if task.GetTitle() != "Извне" || task.GetVersion() != 2 {
+		t.Errorf("задача %q v%d, ожидалась внешняя правка v2", task.GetTitle(), task.GetVersion())
+	}
+}
@@ -0,0 +1,420 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"task-manager/internal/model"
+)
+
+// Имена файлов задач и заметок внутри каталога рабочего пространства (без расширения)
+const (
+	workspaceTasksFile = "tasks"
+	workspaceNotesFile = "notes"
+)
+
+// Ошибки операций с рабочими пространствами
+var (
+	// ErrWorkspaceNotFound - рабочего пространства с таким именем нет
+	ErrWorkspaceNotFound = errors.New("рабочее пространство не найдено")
+	// ErrWorkspaceExists - рабочее пространство с таким именем уже есть
+	ErrWorkspaceExists = errors.New("рабочее пространство уже существует")
+	// ErrWorkspaceNotEmpty - в удаляемом рабочем пространстве есть задачи или заметки
+	ErrWorkspaceNotEmpty = errors.New("рабочее пространство не пустое")
+)
+
+// workspaceName - допустимое имя рабочего пространства: оно же имя каталога
+var workspaceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Workspaces - именованные рабочие пространства в одном корневом каталоге данных.
+// Каждое пространство - отдельный каталог root/<имя> со своими файлами задач и заметок,
+// последовательностями ID, журналом, снимками и блокировкой, то есть отдельное хранилище Storage
+type Workspaces struct {
+	root string
+	opts []Option
+
+	// Каталог снимков, заданный через WithSnapshots; снимки пространства лежат в его подкаталоге
+	snapshotDir string
+
+	mu   sync.Mutex
+	open map[string]*Storage
+}
+
+// NewWorkspaces открывает корневой каталог рабочих пространств, создавая его при необходимости.
+// Параметры opts применяются к хранилищу каждого пространства (см. NewStorage)
+func NewWorkspaces(root string, opts ...Option) (*Workspaces, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога рабочих пространств: %w", err)
+	}
+
+	var options storageOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Workspaces{
+		root:        root,
+		opts:        opts,
+		snapshotDir: options.snapshots.Dir,
+		open:        make(map[string]*Storage),
+	}, nil
+}
+
+// validateWorkspaceName проверяет имя пространства
+func validateWorkspaceName(name string) error {
+	if !workspaceName.MatchString(name) {
+		return model.NewValidationError(fmt.Sprintf("invalid workspace name %q: use up to 64 letters, digits, '-' and '_'", name))
+	}
+	return nil
+}
+
+// dir возвращает каталог пространства
+func (w *Workspaces) dir(name string) string {
+	return filepath.Join(w.root, name)
+}
+
+// exists сообщает, есть ли каталог пространства
+func (w *Workspaces) exists(name string) (bool, error) {
+	info, err := os.Stat(w.dir(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// sameDir сообщает, указывают ли пути на один каталог
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// List возвращает имена рабочих пространств по алфавиту
+func (w *Workspaces) List() ([]string, error) {
+	entries, err := os.ReadDir(w.root)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога рабочих пространств: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !workspaceName.MatchString(entry.Name()) {
+			continue
+		}
+		// Общий каталог снимков может лежать в корне, но пространством не является
+		if w.snapshotDir != "" && sameDir(w.dir(entry.Name()), w.snapshotDir) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Create создаёт пустое рабочее пространство и открывает его хранилище
+func (w *Workspaces) Create(name string) (*Storage, error) {
+	if err := validateWorkspaceName(name); err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := os.Mkdir(w.dir(name), 0755); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrWorkspaceExists, name)
+		}
+		return nil, fmt.Errorf("ошибка создания рабочего пространства %s: %w", name, err)
+	}
+
+	storage, _, err := w.openLocked(name)
+	if err != nil {
+		os.RemoveAll(w.dir(name))
+		return nil, err
+	}
+	return storage, nil
+}
+
+// Open возвращает хранилище рабочего пространства, открывая его при первом обращении.
+// Отчёт о загрузке возвращается только при первом открытии, для уже открытого пространства - nil.
+// Хранилища закрываются вместе с Workspaces через Close
+func (w *Workspaces) Open(name string) (*Storage, *LoadReport, error) {
+	if err := validateWorkspaceName(name); err != nil {
+		return nil, nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if storage, ok := w.open[name]; ok {
+		return storage, nil, nil
+	}
+
+	ok, err := w.exists(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка открытия рабочего пространства %s: %w", name, err)
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrWorkspaceNotFound, name)
+	}
+	return w.openLocked(name)
+}
+
+// openLocked открывает хранилище существующего пространства
+// Вызывается под блокировкой w.mu
+func (w *Workspaces) openLocked(name string) (*Storage, *LoadReport, error) {
+	dir := w.dir(name)
+	opts := append(slices.Clone(w.opts), func(o *storageOptions) {
+		// Общий каталог снимков делится на подкаталоги, чтобы снимки пространств не смешивались
+		if w.snapshotDir != "" {
+			o.snapshots.Dir = filepath.Join(w.snapshotDir, name)
+		}
+	})
+
+	storage, report, err := Open(filepath.Join(dir, workspaceTasksFile), filepath.Join(dir, workspaceNotesFile), opts...)
+	if err != nil {
+		return nil, report, fmt.Errorf("ошибка открытия рабочего пространства %s: %w", name, err)
+	}
+	w.open[name] = storage
+	return storage, report, nil
+}
+
+// closeLocked закрывает хранилище пространства, если оно открыто, и возвращает ошибку сохранения
+// Вызывается под блокировкой w.mu
+func (w *Workspaces) closeLocked(name string) error {
+	storage, ok := w.open[name]
+	if !ok {
+		return nil
+	}
+	delete(w.open, name)
+	if err := storage.Close(); err != nil {
+		return fmt.Errorf("ошибка закрытия рабочего пространства %s: %w", name, err)
+	}
+	return nil
+}
+
+// checkUnlocked проверяет, что каталог пространства не открыт другим процессом
+func checkUnlocked(dir string) error {
+	lock, err := lockDir(dir)
+	if err != nil {
+		return err
+	}
+	return lock.unlock()
+}
+
+// Rename переименовывает рабочее пространство. Открытое хранилище пространства закрывается:
+// хранилища, полученные до переименования, использовать больше нельзя - их нужно открыть заново
+func (w *Workspaces) Rename(oldName, newName string) error {
+	if err := validateWorkspaceName(oldName); err != nil {
+		return err
+	}
+	if err := validateWorkspaceName(newName); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ok, err := w.exists(oldName); err != nil || !ok {
+		if err != nil {
+			return fmt.Errorf("ошибка переименования рабочего пространства %s: %w", oldName, err)
+		}
+		return fmt.Errorf("%w: %s", ErrWorkspaceNotFound, oldName)
+	}
+	if ok, err := w.exists(newName); err != nil || ok {
+		if err != nil {
+			return fmt.Errorf("ошибка переименования рабочего пространства %s: %w", oldName, err)
+		}
+		return fmt.Errorf("%w: %s", ErrWorkspaceExists, newName)
+	}
+
+	if err := w.closeLocked(oldName); err != nil {
+		return err
+	}
+
+	// Блокировка держится до конца переименования, чтобы другой процесс не открыл пространство
+	// под старым именем между проверкой и переименованием
+	lock, err := lockDir(w.dir(oldName))
+	if err != nil {
+		return fmt.Errorf("рабочее пространство %s используется: %w", oldName, err)
+	}
+	if err := lock.rename(w.dir(newName)); err != nil {
+		lock.unlock()
+		return fmt.Errorf("ошибка переименования рабочего пространства %s: %w", oldName, err)
+	}
+	if err := lock.unlock(); err != nil {
+		return fmt.Errorf("ошибка снятия блокировки рабочего пространства %s: %w", newName, err)
+	}
+	if w.snapshotDir != "" {
+		oldSnapshots := filepath.Join(w.snapshotDir, oldName)
+		if err := os.Rename(oldSnapshots, filepath.Join(w.snapshotDir, newName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("ошибка переименования снимков рабочего пространства %s: %w", oldName, err)
+		}
+	}
+	return nil
+}
+
+// Delete удаляет рабочее пространство вместе с файлами и снимками.
+// Пространство с задачами или заметками (в том числе в корзине) удаляется только с force,
+// иначе возвращается ErrWorkspaceNotEmpty
+func (w *Workspaces) Delete(name string, force bool) error {
+	if err := validateWorkspaceName(name); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if ok, err := w.exists(name); err != nil || !ok {
+		if err != nil {
+			return fmt.Errorf("ошибка удаления рабочего пространства %s: %w", name, err)
+		}
+		return fmt.Errorf("%w: %s", ErrWorkspaceNotFound, name)
+	}
+
+	if !force {
+		storage, ok := w.open[name]
+		if !ok {
+			var err error
+			if storage, _, err = w.openLocked(name); err != nil {
+				return err
+			}
+		}
+		tasks, notes := storage.Count()
+		tasks += len(storage.GetDeletedTasks())
+		notes += len(storage.GetDeletedNotes())
+		if tasks > 0 || notes > 0 {
+			return fmt.Errorf("%w: %s (задач: %d, заметок: %d)", ErrWorkspaceNotEmpty, name, tasks, notes)
+		}
+	}
+
+	if err := w.closeLocked(name); err != nil {
+		return err
+	}
+	if err := checkUnlocked(w.dir(name)); err != nil {
+		return fmt.Errorf("рабочее пространство %s используется: %w", name, err)
+	}
+
+	if err := os.RemoveAll(w.dir(name)); err != nil {
+		return fmt.Errorf("ошибка удаления рабочего пространства %s: %w", name, err)
+	}
+	if w.snapshotDir != "" {
+		if err := os.RemoveAll(filepath.Join(w.snapshotDir, name)); err != nil {
+			return fmt.Errorf("ошибка удаления снимков рабочего пространства %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// MoveTask переносит задачу из пространства from в пространство to и возвращает её новый ID:
+// последовательности ID у пространств свои. Задача сначала добавляется в to и только потом
+// удаляется из from, поэтому после сбоя она может оказаться в обоих пространствах, но не потеряется;
+// если убрать копию из to не удалось, эта ошибка возвращается вместе с исходной
+func (w *Workspaces) MoveTask(id int, from, to string) (int, error) {
+	src, dst, err := w.movePair(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	task, err := src.GetTask(id)
+	if err != nil {
+		return 0, err
+	}
+	task.SetID(0)
+	if err := dst.AddModel(task); err != nil {
+		return 0, err
+	}
+
+	if err := src.removeTask(id); err != nil {
+		// Задача осталась в исходном пространстве - убираем копию, чтобы не было дубля
+		if rollbackErr := dst.removeTask(task.GetID()); rollbackErr != nil {
+			return 0, errors.Join(err, fmt.Errorf("задача %d осталась и в пространстве %s под ID %d: %w", id, to, task.GetID(), rollbackErr))
+		}
+		return 0, err
+	}
+	return task.GetID(), nil
+}
+
+// MoveNote переносит заметку из пространства from в пространство to и возвращает её новый ID (см. MoveTask)
+func (w *Workspaces) MoveNote(id int, from, to string) (int, error) {
+	src, dst, err := w.movePair(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	note, err := src.GetNote(id)
+	if err != nil {
+		return 0, err
+	}
+	note.SetID(0)
+	if err := dst.AddModel(note); err != nil {
+		return 0, err
+	}
+
+	if err := src.removeNote(id); err != nil {
+		if rollbackErr := dst.removeNote(note.GetID()); rollbackErr != nil {
+			return 0, errors.Join(err, fmt.Errorf("заметка %d осталась и в пространстве %s под ID %d: %w", id, to, note.GetID(), rollbackErr))
+		}
+		return 0, err
+	}
+	return note.GetID(), nil
+}
+
+// movePair открывает исходное и целевое пространства переноса
+func (w *Workspaces) movePair(from, to string) (*Storage, *Storage, error) {
+	if from == to {
+		return nil, nil, model.NewValidationError("source and target workspaces must differ")
+	}
+	src, _, err := w.Open(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, _, err := w.Open(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+// Close закрывает хранилища всех открытых пространств (см. Storage.Close)
+// и возвращает ошибки их сохранения
+func (w *Workspaces) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	for name := range w.open {
+		errs = append(errs, w.closeLocked(name))
+	}
+	return errors.Join(errs...)
+}
+
+// removeTask окончательно удаляет задачу не из корзины (при переносе в другое пространство)
+func (s *Storage) removeTask(id int) error {
+	return s.Transaction(func(tx *Tx) error {
+		if tx.activeTask(id) < 0 {
+			return model.NewNotFoundError("task", id)
+		}
+		tx.deleteTasks(func(t *model.Task) bool { return t.GetID() == id })
+		return nil
+	})
+}
+
+// removeNote окончательно удаляет заметку не из корзины (при переносе в другое пространство)
+func (s *Storage) removeNote(id int) error {
+	return s.Transaction(func(tx *Tx) error {
+		if tx.activeNote(id) < 0 {
+			return model.NewNotFoundError("note", id)
+		}
+		tx.deleteNotes(func(n *model.Note) bool { return n.GetID() == id })
+		return nil
+	})
+}
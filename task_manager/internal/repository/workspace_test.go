@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"task-manager/internal/model"
+)
+
+// newWorkspaces открывает рабочие пространства во временном каталоге и закрывает их по окончании теста
+func newWorkspaces(t *testing.T, opts ...Option) *Workspaces {
+	t.Helper()
+	workspaces, err := NewWorkspaces(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { workspaces.Close() })
+	return workspaces
+}
+
+// createWorkspace создаёт пространство с задачами titles
+func createWorkspace(t *testing.T, w *Workspaces, name string, titles ...string) *Storage {
+	t.Helper()
+	storage, err := w.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range titles {
+		addTask(t, storage, title)
+	}
+	return storage
+}
+
+func TestWorkspacesAreIsolated(t *testing.T) {
+	w := newWorkspaces(t)
+	work := createWorkspace(t, w, "work", "Отчёт")
+	home := createWorkspace(t, w, "home", "Покупки", "Уборка")
+
+	if names, err := w.List(); err != nil || !reflect.DeepEqual(names, []string{"home", "work"}) {
+		t.Fatalf("List: %v, %v", names, err)
+	}
+	// Последовательности ID у пространств свои
+	if task, err := work.GetTask(1); err != nil || task.GetTitle() != "Отчёт" {
+		t.Errorf("work: %v", err)
+	}
+	if task, err := home.GetTask(1); err != nil || task.GetTitle() != "Покупки" {
+		t.Errorf("home: %v", err)
+	}
+
+	if _, err := w.Create("work"); !errors.Is(err, ErrWorkspaceExists) {
+		t.Errorf("повторное создание: ожидалась ErrWorkspaceExists, получено %v", err)
+	}
+	if _, _, err := w.Open("missing"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("открытие несуществующего: ожидалась ErrWorkspaceNotFound, получено %v", err)
+	}
+	if opened, _, err := w.Open("work"); err != nil || opened != work {
+		t.Errorf("повторное открытие вернуло другое хранилище: %v", err)
+	}
+}
+
+func TestWorkspaceRename(t *testing.T) {
+	snapshots := filepath.Join(t.TempDir(), "snapshots")
+	w := newWorkspaces(t, WithSnapshots(SnapshotOptions{Dir: snapshots}))
+	storage := createWorkspace(t, w, "draft", "Задача")
+	if _, err := storage.Snapshot("manual"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Rename("draft", "final"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetTask(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("хранилище под старым именем не закрыто: %v", err)
+	}
+
+	renamed, _, err := w.Open("final")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task, err := renamed.GetTask(1); err != nil || task.GetTitle() != "Задача" {
+		t.Errorf("данные не переехали: %v", err)
+	}
+	if list, err := renamed.Snapshots(); err != nil || len(list) != 1 {
+		t.Errorf("снимки не переехали: %v, %v", list, err)
+	}
+	if _, _, err := w.Open("draft"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("старое имя осталось: %v", err)
+	}
+}
+
+// Пространство, открытое другим процессом, не переименовывается и не удаляется
+func TestWorkspaceInUse(t *testing.T) {
+	w := newWorkspaces(t)
+	createWorkspace(t, w, "shared")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Другой процесс открыл пространство напрямую
+	dir := filepath.Join(w.root, "shared")
+	openStorage(t, filepath.Join(dir, workspaceTasksFile), filepath.Join(dir, workspaceNotesFile))
+
+	var lockErr *LockError
+	if err := w.Rename("shared", "other"); !errors.As(err, &lockErr) {
+		t.Errorf("Rename: ожидалась *LockError, получено %v", err)
+	}
+	if err := w.Delete("shared", true); !errors.As(err, &lockErr) {
+		t.Errorf("Delete: ожидалась *LockError, получено %v", err)
+	}
+	if names, _ := w.List(); !reflect.DeepEqual(names, []string{"shared"}) {
+		t.Errorf("занятое пространство изменено: %v", names)
+	}
+}
+
+func TestWorkspaceDelete(t *testing.T) {
+	w := newWorkspaces(t)
+	storage := createWorkspace(t, w, "old", "Задача")
+	if err := storage.DeleteTask(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Задача в корзине - тоже данные
+	if err := w.Delete("old", false); !errors.Is(err, ErrWorkspaceNotEmpty) {
+		t.Fatalf("ожидалась ErrWorkspaceNotEmpty, получено %v", err)
+	}
+	if err := w.Delete("old", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(w.root, "old")); !os.IsNotExist(err) {
+		t.Errorf("каталог пространства остался: %v", err)
+	}
+
+	createWorkspace(t, w, "empty")
+	if err := w.Delete("empty", false); err != nil {
+		t.Errorf("пустое пространство не удалено: %v", err)
+	}
+}
+
+// Ошибка финального сохранения при удалении не теряется, и пространство остаётся на месте
+func TestWorkspaceDeleteReportsCloseError(t *testing.T) {
+	w := newWorkspaces(t)
+	createWorkspace(t, w, "broken", "Задача")
+
+	// Файл задач подменён непустым каталогом - финальное сохранение не сможет его заменить
+	tasksJSON := filepath.Join(w.root, "broken", workspaceTasksFile+".json")
+	if err := os.Remove(tasksJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tasksJSON, "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Delete("broken", true); err == nil {
+		t.Fatal("ошибка закрытия пространства не возвращена")
+	}
+	if _, err := os.Stat(filepath.Join(w.root, "broken")); err != nil {
+		t.Errorf("пространство удалено, несмотря на ошибку: %v", err)
+	}
+}
+
+func TestWorkspaceMoveTask(t *testing.T) {
+	w := newWorkspaces(t)
+	createWorkspace(t, w, "from", "Первая", "Переносимая")
+	to := createWorkspace(t, w, "to", "Своя")
+
+	newID, err := w.MoveTask(2, "from", "to")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID != 2 {
+		t.Errorf("новый ID %d, ожидался 2 из последовательности целевого пространства", newID)
+	}
+	if task, err := to.GetTask(newID); err != nil || task.GetTitle() != "Переносимая" {
+		t.Errorf("задача не появилась в целевом пространстве: %v", err)
+	}
+
+	from, _, _ := w.Open("from")
+	if _, err := from.GetTask(2); err == nil {
+		t.Error("задача осталась в исходном пространстве")
+	}
+	if deleted := from.GetDeletedTasks(); len(deleted) != 0 {
+		t.Errorf("перенесённая задача попала в корзину: %d", len(deleted))
+	}
+
+	if _, err := w.MoveTask(1, "from", "from"); err == nil {
+		t.Error("перенос в то же пространство не отклонён")
+	}
+}
+
+// Имя пространства - имя каталога в корне, поэтому пути, скрытые каталоги и длинные имена отклоняются
+func TestWorkspaceNames(t *testing.T) {
+	w := newWorkspaces(t)
+	createWorkspace(t, w, "project_2-b")
+
+	for _, name := range []string{"", ".hidden", "../outside", "a/b", "with space", "-dash", strings.Repeat("a", 65)} {
+		if _, err := w.Create(name); !model.IsValidationError(err) {
+			t.Errorf("Create(%q): ожидалась ошибка валидации, получено %v", name, err)
+		}
+		if err := w.Rename("project_2-b", name); !model.IsValidationError(err) {
+			t.Errorf("Rename в %q: ожидалась ошибка валидации, получено %v", name, err)
+		}
+	}
+	if names, _ := w.List(); !reflect.DeepEqual(names, []string{"project_2-b"}) {
+		t.Errorf("List: %v", names)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(w.root)); len(entries) != 1 {
+		t.Errorf("за пределами корня созданы каталоги: %d записей", len(entries))
+	}
+}
+
+func TestWorkspaceMoveNote(t *testing.T) {
+	w := newWorkspaces(t)
+	from := createWorkspace(t, w, "from")
+	to := createWorkspace(t, w, "to")
+	for _, storage := range []*Storage{from, from, to} {
+		if err := storage.AddModel(model.NewNote("Заметка", "Содержимое", model.CategoryIdea)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newID, err := w.MoveNote(2, "from", "to")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID != 2 {
+		t.Errorf("новый ID %d, ожидался 2 из последовательности целевого пространства", newID)
+	}
+	if note, err := to.GetNote(newID); err != nil || note.GetCategory() != string(model.CategoryIdea) {
+		t.Errorf("заметка не появилась в целевом пространстве: %v", err)
+	}
+	if _, notes := from.Count(); notes != 1 {
+		t.Errorf("в исходном пространстве %d заметок, ожидалась 1", notes)
+	}
+
+	// Перенос несуществующей заметки ничего не добавляет в целевое пространство
+	if _, err := w.MoveNote(9, "from", "to"); !model.IsNotFoundError(err) {
+		t.Errorf("ожидалась *model.NotFoundError, получено %v", err)
+	}
+	if _, err := w.MoveNote(1, "from", "missing"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Errorf("перенос в несуществующее пространство: %v", err)
+	}
+	if _, notes := to.Count(); notes != 2 {
+		t.Errorf("в целевом пространстве %d заметок, ожидалось 2", notes)
+	}
+}
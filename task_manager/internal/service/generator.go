@@ -46,7 +46,6 @@ func GenerateModels(ctx context.Context, modelChan chan<- interface{}, count int
 					fmt.Printf("Генератор: ошибка создания задачи: %v\n", err)
 					continue
 				}
-				task.SetID(i + 1)
 				task.SetStatus(randomStatus())
 				
 				// Отправляем задачу в канал с проверкой контекста
@@ -64,7 +63,6 @@ func GenerateModels(ctx context.Context, modelChan chan<- interface{}, count int
 					fmt.Sprintf("Содержимое заметки %d", i+1),
 					randomCategory(),
 				)
-				note.SetID(i + 1)
 				
 				// Отправляем заметку в канал с проверкой контекста
 				select {
@@ -83,7 +81,7 @@ func GenerateModels(ctx context.Context, modelChan chan<- interface{}, count int
 
 // Receiver получает модели из канала и сохраняет в репозиторий
 // Завершается при отмене контекста или закрытии канала
-func Receiver(ctx context.Context, modelChan <-chan interface{}, storage *repository.Storage) {
+func Receiver(ctx context.Context, modelChan <-chan interface{}, storage repository.Repository) {
 	fmt.Println("Приёмник: запущен")
 	
 	for {
@@ -4,84 +4,46 @@ import (
 	"context"
 	"log"
 	"task-manager/internal/repository"
-	"time"
 )
 
-// Logger отслеживает изменения в хранилище и логирует новые элементы
-// Завершается при отмене контекста
-func Logger(ctx context.Context, storage *repository.Storage, interval time.Duration) {
+// Logger подписывается на изменения хранилища и логирует каждое из них
+// Завершается при отмене контекста, дочитав уже полученные события
+func Logger(ctx context.Context, storage repository.Repository) {
 	log.Println("Логер: запущен")
-	
-	var lastTaskIndex, lastNoteIndex int
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Логер: получен сигнал отмены")
-			// Финальная проверка перед завершением
-			logFinalChanges(storage, lastTaskIndex, lastNoteIndex)
-			return
-		case <-ticker.C:
-			logChanges(storage, &lastTaskIndex, &lastNoteIndex)
-		}
+
+	var count int
+	for event := range storage.Subscribe(ctx, repository.SubscribeOptions{}) {
+		logChange(event)
+		count++
 	}
+
+	log.Println("Логер: получен сигнал отмены")
+	log.Printf("Логер: всего запротоколировано изменений: %d\n", count)
 }
 
-// logChanges проверяет и логирует изменения с момента последней проверки
-func logChanges(storage *repository.Storage, lastTaskIndex, lastNoteIndex *int) {
-	// Проверяем новые задачи
-	newTasks := storage.GetNewTasks(*lastTaskIndex)
-	if len(newTasks) > 0 {
-		log.Printf("Логер: найдено %d новых задач\n", len(newTasks))
-		for _, task := range newTasks {
-			log.Printf("  - Задача: %s (ID: %d, Статус: %v, Приоритет: %v)",
-				task.GetTitle(), task.GetID(), task.GetStatus(), task.GetPriority())
-		}
-		*lastTaskIndex += len(newTasks)
-	}
-	
-	// Проверяем новые заметки
-	newNotes := storage.GetNewNotes(*lastNoteIndex)
-	if len(newNotes) > 0 {
-		log.Printf("Логер: найдено %d новых заметок\n", len(newNotes))
-		for _, note := range newNotes {
-			log.Printf("  - Заметка: %s (ID: %d, Категория: %v)",
-				note.GetTitle(), note.GetID(), note.GetCategory())
-		}
-		*lastNoteIndex += len(newNotes)
+// logChange логирует одно изменение
+func logChange(event repository.ChangeEvent) {
+	if event.Missed > 0 {
+		log.Printf("Логер: пропущено %d изменений, логер не успевал их обработать\n", event.Missed)
 	}
-	
-	// Если не найдено новых элементов
-	if len(newTasks) == 0 && len(newNotes) == 0 {
-		log.Println("Логер: новых элементов не найдено")
+
+	source := ""
+	if event.External {
+		source = " (извне)"
 	}
-}
 
-// logFinalChanges выполняет финальную проверку изменений перед завершением
-func logFinalChanges(storage *repository.Storage, lastTaskIndex, lastNoteIndex int) {
-	log.Println("Логер: финальная проверка изменений...")
-	
-	newTasks := storage.GetNewTasks(lastTaskIndex)
-	newNotes := storage.GetNewNotes(lastNoteIndex)
-	
-	if len(newTasks) > 0 || len(newNotes) > 0 {
-		log.Printf("Логер: найдено непротоколированных изменений: %d задач, %d заметок\n", 
-			len(newTasks), len(newNotes))
-		
-		// Логируем непротоколированные задачи
-		for _, task := range newTasks {
-			log.Printf("  - Непротоколированная задача: %s (ID: %d)",
-				task.GetTitle(), task.GetID())
-		}
-		
-		// Логируем непротоколированные заметки
-		for _, note := range newNotes {
-			log.Printf("  - Непротоколированная заметка: %s (ID: %d)",
-				note.GetTitle(), note.GetID())
-		}
-	} else {
-		log.Println("Логер: непротоколированных изменений нет")
+	switch {
+	case event.AfterTask != nil:
+		task := event.AfterTask
+		log.Printf("Логер: задача %s%s: %s (ID: %d, Статус: %v, Приоритет: %v, Версия: %d)",
+			event.Op, source, task.GetTitle(), task.GetID(), task.GetStatus(), task.GetPriority(), task.GetVersion())
+	case event.BeforeTask != nil:
+		log.Printf("Логер: задача %s%s: %s (ID: %d)", event.Op, source, event.BeforeTask.GetTitle(), event.ID)
+	case event.AfterNote != nil:
+		note := event.AfterNote
+		log.Printf("Логер: заметка %s%s: %s (ID: %d, Категория: %v, Версия: %d)",
+			event.Op, source, note.GetTitle(), note.GetID(), note.GetCategory(), note.GetVersion())
+	case event.BeforeNote != nil:
+		log.Printf("Логер: заметка %s%s: %s (ID: %d)", event.Op, source, event.BeforeNote.GetTitle(), event.ID)
 	}
-}
\ No newline at end of file
+}